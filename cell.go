@@ -12,9 +12,12 @@
 package excelize
 
 import (
+	"encoding/json"
 	"encoding/xml"
 	"fmt"
+	"math/big"
 	"reflect"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
@@ -106,10 +109,16 @@ func (f *File) GetCellType(sheet, axis string) (CellType, error) {
 //    time.Duration
 //    time.Time
 //    bool
+//    *big.Int
+//    *big.Rat
+//    json.Number
 //    nil
 //
 // Note that default date format is m/d/yy h:mm of time.Time type value. You can
-// set numbers format by SetCellStyle() method.
+// set numbers format by SetCellStyle() method. The *big.Int and *big.Rat values
+// are stored as exact decimal numbers, see SetCellBigNumber. The json.Number
+// values are stored as numbers with the digits as is, such as decoded by a
+// json.Decoder with UseNumber, or as strings if they are not valid numbers.
 func (f *File) SetCellValue(sheet, axis string, value interface{}) error {
 	var err error
 	switch v := value.(type) {
@@ -134,6 +143,14 @@ func (f *File) SetCellValue(sheet, axis string, value interface{}) error {
 		err = f.setCellTimeFunc(sheet, axis, v)
 	case bool:
 		err = f.SetCellBool(sheet, axis, v)
+	case *big.Int, *big.Rat:
+		err = f.SetCellBigNumber(sheet, axis, v)
+	case json.Number:
+		if t, n, _ := setCellJSONNumber(v); t == "" {
+			err = f.SetCellDefault(sheet, axis, n)
+		} else {
+			err = f.SetCellStr(sheet, axis, string(v))
+		}
 	case nil:
 		err = f.SetCellDefault(sheet, axis, "")
 	default:
@@ -241,9 +258,11 @@ func setCellTime(value time.Time) (t string, b string, isNum bool, err error) {
 }
 
 // setCellDuration prepares cell type and value by given Go time.Duration type
-// time duration.
+// time duration. The duration is stored as a fraction of days, which is
+// Excel's convention for elapsed time (1 day = 1.0), and should be combined
+// with an elapsed time number format such as "[h]:mm:ss".
 func setCellDuration(value time.Duration) (t string, v string) {
-	v = strconv.FormatFloat(value.Seconds()/86400.0, 'f', -1, 32)
+	v = strconv.FormatFloat(value.Seconds()/86400.0, 'f', -1, 64)
 	return
 }
 
@@ -272,6 +291,99 @@ func setCellInt(value int) (t string, v string) {
 	return
 }
 
+// SetCellBigNumber provides a function to set the value of a cell by given
+// worksheet name, cell name and *big.Int or *big.Rat value, which is stored
+// as the exact decimal number, without the precision loss of a float64. A
+// fraction without terminating decimal representation, such as 1/3, is
+// rounded to 30 decimal places. Note that the applications display and
+// calculate the numbers with 15 significant digits, but the stored digits are
+// kept when the workbook is saved, and returned by GetCellValue.
+func (f *File) SetCellBigNumber(sheet, axis string, value interface{}) error {
+	var v string
+	switch value := value.(type) {
+	case *big.Int:
+		_, v = setCellBigInt(value)
+	case *big.Rat:
+		_, v = setCellBigRat(value)
+	default:
+		return ErrParameterInvalid
+	}
+	return f.SetCellDefault(sheet, axis, v)
+}
+
+// setCellBigInt prepares cell type and string type cell value by a given
+// big integer, a nil value is an empty cell.
+func setCellBigInt(value *big.Int) (t string, v string) {
+	if value != nil {
+		v = value.String()
+	}
+	return
+}
+
+// jsonNumberExp matches the number literals of JSON, which are valid numeric
+// cell values if they are in the range of float64.
+var jsonNumberExp = regexp.MustCompile(`^-?(0|[1-9]\d*)(\.\d+)?([eE][+-]?\d+)?$`)
+
+// setCellJSONNumber prepares cell type and string type cell value by a given
+// json.Number, which is written as is if it's a valid number literal in the
+// range of float64, otherwise as a string.
+func setCellJSONNumber(value json.Number) (t string, v string, ns xml.Attr) {
+	if jsonNumberExp.MatchString(string(value)) && isFloatInRange(string(value)) {
+		v = string(value)
+		return
+	}
+	return setCellStr(string(value))
+}
+
+// isFloatInRange reports whether the given number literal can be parsed as a
+// float64 without overflow, so that it's a valid numeric cell value.
+func isFloatInRange(s string) bool {
+	_, err := strconv.ParseFloat(s, 64)
+	return err == nil
+}
+
+// bigRatPrecision is the number of decimal places of the rational numbers
+// without terminating decimal representation.
+const bigRatPrecision = 30
+
+// setCellBigRat prepares cell type and string type cell value by a given
+// rational number, a nil value is an empty cell. The number is written with
+// the decimal places of its terminating decimal representation, which is the
+// larger power of 2 and 5 of the denominator, or rounded to bigRatPrecision
+// decimal places.
+func setCellBigRat(value *big.Rat) (t string, v string) {
+	if value == nil {
+		return
+	}
+	if value.IsInt() {
+		v = value.Num().String()
+		return
+	}
+	denom := new(big.Int).Set(value.Denom())
+	twos := int(denom.TrailingZeroBits())
+	denom.Rsh(denom, uint(twos))
+	fives, five, rem := 0, big.NewInt(5), new(big.Int)
+	for {
+		quo, _ := new(big.Int).QuoRem(denom, five, rem)
+		if rem.Sign() != 0 {
+			break
+		}
+		denom, fives = quo, fives+1
+	}
+	prec := twos
+	if fives > prec {
+		prec = fives
+	}
+	if denom.Cmp(big.NewInt(1)) != 0 {
+		prec = bigRatPrecision
+	}
+	v = strings.TrimRight(strings.TrimRight(value.FloatString(prec), "0"), ".")
+	if v == "-0" {
+		v = "0"
+	}
+	return
+}
+
 // SetCellBool provides a function to set bool type value of a cell by given
 // worksheet name, cell name and cell value.
 func (f *File) SetCellBool(sheet, axis string, value bool) error {
@@ -355,9 +467,7 @@ func (f *File) SetCellStr(sheet, axis, value string) error {
 // setCellString provides a function to set string type to shared string
 // table.
 func (f *File) setCellString(value string) (t string, v string) {
-	if len(value) > TotalCellChars {
-		value = value[:TotalCellChars]
-	}
+	value = truncateCellChars(value)
 	t = "s"
 	v = strconv.Itoa(f.setSharedString(value))
 	return
@@ -365,11 +475,23 @@ func (f *File) setCellString(value string) (t string, v string) {
 
 // setSharedString provides a function to add string to the share string table.
 func (f *File) setSharedString(val string) int {
+	i, _ := f.addSharedString(val, 0)
+	return i
+}
+
+// addSharedString provides a function to add string to the share string
+// table, unless the table already holds the given maximum number of strings,
+// and returns the index of the string and if it is in the table. A limit of 0
+// doesn't limit the table.
+func (f *File) addSharedString(val string, limit int) (int, bool) {
 	sst := f.sharedStringsReader()
 	f.Lock()
 	defer f.Unlock()
 	if i, ok := f.sharedStringsMap[val]; ok {
-		return i
+		return i, true
+	}
+	if limit > 0 && len(sst.SI) >= limit {
+		return 0, false
 	}
 	sst.Count++
 	sst.UniqueCount++
@@ -377,17 +499,31 @@ func (f *File) setSharedString(val string) int {
 	_, val, t.Space = setCellStr(val)
 	sst.SI = append(sst.SI, xlsxSI{T: &t})
 	f.sharedStringsMap[val] = sst.UniqueCount - 1
-	return sst.UniqueCount - 1
+	return sst.UniqueCount - 1, true
+}
+
+// truncateCellChars returns the given string truncated to the maximum number
+// of characters of a cell, without splitting a character.
+func truncateCellChars(value string) string {
+	if len(value) <= TotalCellChars {
+		return value
+	}
+	var chars int
+	for i := range value {
+		if chars == TotalCellChars {
+			return value[:i]
+		}
+		chars++
+	}
+	return value
 }
 
 // setCellStr provides a function to set string type to cell.
 func setCellStr(value string) (t string, v string, ns xml.Attr) {
-	if len(value) > TotalCellChars {
-		value = value[:TotalCellChars]
-	}
+	value = truncateCellChars(value)
 	if len(value) > 0 {
 		prefix, suffix := value[0], value[len(value)-1]
-		for _, ascii := range []byte{10, 13, 32} {
+		for _, ascii := range []byte{9, 10, 13, 32} {
 			if prefix == ascii || suffix == ascii {
 				ns = xml.Attr{
 					Name:  xml.Name{Space: NameSpaceXML, Local: "space"},
@@ -712,7 +848,8 @@ func (f *File) SetCellHyperLink(sheet, axis, link, linkType string, opts ...Hype
 }
 
 // GetCellRichText provides a function to get rich text of cell by given
-// worksheet.
+// worksheet. The rich text may be stored in the shared strings table, or
+// inline in the cell as written by the StreamWriter.
 func (f *File) GetCellRichText(sheet, cell string) (runs []RichTextRun, err error) {
 	ws, err := f.workSheetReader(sheet)
 	if err != nil {
@@ -722,6 +859,9 @@ func (f *File) GetCellRichText(sheet, cell string) (runs []RichTextRun, err erro
 	if err != nil {
 		return
 	}
+	if cellData.T == "inlineStr" && cellData.IS != nil {
+		return getRichTextRuns(cellData.IS), err
+	}
 	siIdx, err := strconv.Atoi(cellData.V)
 	if nil != err {
 		return
@@ -730,7 +870,12 @@ func (f *File) GetCellRichText(sheet, cell string) (runs []RichTextRun, err erro
 	if len(sst.SI) <= siIdx || siIdx < 0 {
 		return
 	}
-	si := sst.SI[siIdx]
+	return getRichTextRuns(&sst.SI[siIdx]), err
+}
+
+// getRichTextRuns provides a function to get the rich text runs of the given
+// string item.
+func getRichTextRuns(si *xlsxSI) (runs []RichTextRun) {
 	for _, v := range si.R {
 		run := RichTextRun{
 			Text: v.T.Val,
@@ -878,12 +1023,31 @@ func (f *File) SetCellRichText(sheet, cell string, runs []RichTextRun) error {
 	cellData.S = f.prepareCellStyle(ws, col, cellData.S)
 	si := xlsxSI{}
 	sst := f.sharedStringsReader()
+	if si.R, err = newRichTextRuns(runs); err != nil {
+		return err
+	}
+	for idx, strItem := range sst.SI {
+		if reflect.DeepEqual(strItem, si) {
+			cellData.T, cellData.V = "s", strconv.Itoa(idx)
+			return err
+		}
+	}
+	sst.SI = append(sst.SI, si)
+	sst.Count++
+	sst.UniqueCount++
+	cellData.T, cellData.V = "s", strconv.Itoa(len(sst.SI)-1)
+	return err
+}
+
+// newRichTextRuns provides a function to create the text runs of a string
+// item by given rich text runs.
+func newRichTextRuns(runs []RichTextRun) ([]xlsxR, error) {
 	textRuns := []xlsxR{}
 	totalCellChars := 0
 	for _, textRun := range runs {
 		totalCellChars += len(textRun.Text)
 		if totalCellChars > TotalCellChars {
-			return ErrCellCharsLength
+			return textRuns, ErrCellCharsLength
 		}
 		run := xlsxR{T: &xlsxT{}}
 		_, run.T.Val, run.T.Space = setCellStr(textRun.Text)
@@ -916,18 +1080,7 @@ func (f *File) SetCellRichText(sheet, cell string, runs []RichTextRun) error {
 		}
 		textRuns = append(textRuns, run)
 	}
-	si.R = textRuns
-	for idx, strItem := range sst.SI {
-		if reflect.DeepEqual(strItem, si) {
-			cellData.T, cellData.V = "s", strconv.Itoa(idx)
-			return err
-		}
-	}
-	sst.SI = append(sst.SI, si)
-	sst.Count++
-	sst.UniqueCount++
-	cellData.T, cellData.V = "s", strconv.Itoa(len(sst.SI)-1)
-	return err
+	return textRuns, nil
 }
 
 // SetSheetRow writes an array to row by given worksheet name, starting
@@ -992,7 +1145,7 @@ func (f *File) getCellStringFunc(sheet, axis string, fn func(x *xlsxWorksheet, c
 	if err != nil {
 		return "", err
 	}
-	_, row, err := CellNameToCoordinates(axis)
+	col, row, err := CellNameToCoordinates(axis)
 	if err != nil {
 		return "", err
 	}
@@ -1000,6 +1153,21 @@ func (f *File) getCellStringFunc(sheet, axis string, fn func(x *xlsxWorksheet, c
 	ws.Lock()
 	defer ws.Unlock()
 
+	// the rows and cells filled by checkSheet and checkRow, such as the cells
+	// without r attribute written by the DirectWriter, are usually at the
+	// index of their coordinates, so look up the cell there first
+	if row <= len(ws.SheetData.Row) && ws.SheetData.Row[row-1].R == row {
+		if cells := ws.SheetData.Row[row-1].C; col <= len(cells) && cells[col-1].R == axis {
+			val, ok, err := fn(ws, &cells[col-1])
+			if err != nil {
+				return "", err
+			}
+			if ok {
+				return val, nil
+			}
+		}
+	}
+
 	lastRowNum := 0
 	if l := len(ws.SheetData.Row); l > 0 {
 		lastRowNum = ws.SheetData.Row[l-1].R
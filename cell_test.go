@@ -1,7 +1,10 @@
 package excelize
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"math/big"
 	"path/filepath"
 	"reflect"
 	"strconv"
@@ -173,6 +176,78 @@ func TestSetCellValues(t *testing.T) {
 	assert.Equal(t, v, "1600-12-31T00:00:00Z")
 }
 
+func TestSetCellBigNumber(t *testing.T) {
+	f := NewFile()
+	n, ok := new(big.Int).SetString("123456789012345678901234567890", 10)
+	assert.True(t, ok)
+	assert.NoError(t, f.SetCellValue("Sheet1", "A1", n))
+	assert.NoError(t, f.SetCellValue("Sheet1", "A2", new(big.Int).Neg(n)))
+	assert.NoError(t, f.SetCellValue("Sheet1", "A3", new(big.Rat).SetFrac(n, big.NewInt(1024))))
+	assert.NoError(t, f.SetCellValue("Sheet1", "A4", big.NewRat(-1, 3)))
+	assert.NoError(t, f.SetCellValue("Sheet1", "A5", big.NewRat(10, 2)))
+	assert.NoError(t, f.SetCellValue("Sheet1", "A6", (*big.Int)(nil)))
+	assert.EqualError(t, f.SetCellBigNumber("Sheet1", "A7", 1), ErrParameterInvalid.Error())
+	assert.EqualError(t, f.SetCellBigNumber("Sheet1", "A", n), `cannot convert cell "A" to coordinates: invalid cell name "A"`)
+
+	var buf bytes.Buffer
+	assert.NoError(t, f.Write(&buf))
+	f, err := OpenReader(&buf)
+	assert.NoError(t, err)
+	// the stored digits are kept
+	for cell, expected := range map[string]string{
+		"A1": "123456789012345678901234567890",
+		"A2": "-123456789012345678901234567890",
+		"A3": "120563270519868827051986882.705078125",
+		"A4": "-0.333333333333333333333333333333",
+		"A5": "5",
+		"A6": "",
+	} {
+		v, err := f.GetCellValue("Sheet1", cell, Options{RawCellValue: true})
+		assert.NoError(t, err)
+		assert.Equal(t, expected, v, cell)
+	}
+	v, err := f.GetCellValue("Sheet1", "A1")
+	assert.NoError(t, err)
+	assert.Equal(t, "123456789012345678901234567890", v)
+	cellType, err := f.GetCellType("Sheet1", "A1")
+	assert.NoError(t, err)
+	assert.Equal(t, CellTypeUnset, cellType)
+	assert.NoError(t, f.Close())
+}
+
+func TestSetCellJSONNumber(t *testing.T) {
+	f := NewFile()
+	assert.NoError(t, f.SetCellValue("Sheet1", "A1", json.Number("12345678901234567890")))
+	assert.NoError(t, f.SetCellValue("Sheet1", "A2", json.Number("-0.1")))
+	assert.NoError(t, f.SetCellValue("Sheet1", "A3", json.Number("1E+3")))
+	assert.NoError(t, f.SetCellValue("Sheet1", "A4", json.Number("0x10")))
+	assert.NoError(t, f.SetCellValue("Sheet1", "A5", json.Number("1e400")))
+	assert.NoError(t, f.SetCellValue("Sheet1", "A6", json.Number("-1E-400")))
+
+	var buf bytes.Buffer
+	assert.NoError(t, f.Write(&buf))
+	f, err := OpenReader(&buf)
+	assert.NoError(t, err)
+	for cell, expected := range map[string]string{
+		"A1": "12345678901234567890",
+		"A2": "-0.1",
+		"A3": "1E+3",
+		"A4": "0x10",
+		"A5": "1e400",
+		"A6": "-1E-400",
+	} {
+		v, err := f.GetCellValue("Sheet1", cell, Options{RawCellValue: true})
+		assert.NoError(t, err)
+		assert.Equal(t, expected, v, cell)
+	}
+	for cell, expected := range map[string]CellType{"A1": CellTypeUnset, "A2": CellTypeUnset, "A3": CellTypeUnset, "A4": CellTypeString, "A5": CellTypeString, "A6": CellTypeUnset} {
+		cellType, err := f.GetCellType("Sheet1", cell)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, cellType, cell)
+	}
+	assert.NoError(t, f.Close())
+}
+
 func TestSetCellBool(t *testing.T) {
 	f := NewFile()
 	assert.EqualError(t, f.SetCellBool("Sheet1", "A", true), `cannot convert cell "A" to coordinates: invalid cell name "A"`)
@@ -398,6 +473,21 @@ func BenchmarkSetCellValue(b *testing.B) {
 	}
 }
 
+func BenchmarkGetCellValue(b *testing.B) {
+	f := NewFile()
+	for row := 1; row <= 1000; row++ {
+		if err := f.SetSheetRow("Sheet1", "A"+strconv.Itoa(row), &[]interface{}{"A", "B", "C", "D", "E", "F"}); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := f.GetCellValue("Sheet1", "F"+strconv.Itoa(i%1000+1)); err != nil {
+			b.Error(err)
+		}
+	}
+}
+
 func TestOverflowNumericCell(t *testing.T) {
 	f, err := OpenFile(filepath.Join("test", "OverflowNumericCell.xlsx"))
 	if !assert.NoError(t, err) {
@@ -668,6 +668,9 @@ func (f *File) GetColWidth(sheet, col string) (float64, error) {
 			return width, err
 		}
 	}
+	if ws.SheetFormatPr != nil && ws.SheetFormatPr.DefaultColWidth > 0 {
+		return ws.SheetFormatPr.DefaultColWidth, err
+	}
 	// Optimisation for when the column widths haven't changed.
 	return defaultColWidth, err
 }
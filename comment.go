@@ -99,6 +99,12 @@ func (f *File) AddComment(sheet, cell, format string) error {
 	if err != nil {
 		return err
 	}
+	return f.addSheetComment(sheet, cell, formatSet)
+}
+
+// addSheetComment provides a function to add a comment in a sheet by given
+// worksheet name, cell and parsed format set.
+func (f *File) addSheetComment(sheet, cell string, formatSet *formatComment) error {
 	// Read sheet data.
 	ws, err := f.workSheetReader(sheet)
 	if err != nil {
@@ -121,6 +127,13 @@ func (f *File) AddComment(sheet, cell, format string) error {
 		f.addSheetNameSpace(sheet, SourceRelationship)
 		f.addSheetLegacyDrawing(sheet, rID)
 	}
+	return f.addCommentParts(commentID, drawingVML, cell, formatSet)
+}
+
+// addCommentParts provides a function to add the comment shape to the VML
+// drawing and the comment to xl/comments%d.xml by given comment ID, drawing
+// path, cell and format sets.
+func (f *File) addCommentParts(commentID int, drawingVML, cell string, formatSet *formatComment) error {
 	commentsXML := "xl/comments" + strconv.Itoa(commentID) + ".xml"
 	var colCount int
 	for i, l := range strings.Split(formatSet.Text, "\n") {
@@ -131,7 +144,7 @@ func (f *File) AddComment(sheet, cell, format string) error {
 			colCount = ll
 		}
 	}
-	err = f.addDrawingVML(commentID, drawingVML, cell, strings.Count(formatSet.Text, "\n")+1, colCount)
+	err := f.addDrawingVML(commentID, drawingVML, cell, strings.Count(formatSet.Text, "\n")+1, colCount)
 	if err != nil {
 		return err
 	}
@@ -25,8 +25,11 @@ import (
 	"encoding/binary"
 	"encoding/xml"
 	"hash"
+	"io"
 	"reflect"
+	"sort"
 	"strings"
+	"unicode/utf16"
 
 	"github.com/richardlehane/mscfb"
 	"golang.org/x/crypto/md4"
@@ -46,12 +49,21 @@ var (
 	oleIdentifier              = []byte{
 		0xd0, 0xcf, 0x11, 0xe0, 0xa1, 0xb1, 0x1a, 0xe1,
 	}
+	hashFuncs = map[string]func() hash.Hash{
+		"md4":        md4.New,
+		"md5":        md5.New,
+		"ripemd-160": ripemd160.New,
+		"sha1":       sha1.New,
+		"sha256":     sha256.New,
+		"sha384":     sha512.New384,
+		"sha512":     sha512.New,
+	}
 )
 
 // Encryption specifies the encryption structure, streams, and storages are
 // required when encrypting ECMA-376 documents.
 type Encryption struct {
-	XMLName       xml.Name      `xml:"encryption"`
+	XMLName       xml.Name      `xml:"http://schemas.microsoft.com/office/2006/encryption encryption"`
 	KeyData       KeyData       `xml:"keyData"`
 	DataIntegrity DataIntegrity `xml:"dataIntegrity"`
 	KeyEncryptors KeyEncryptors `xml:"keyEncryptors"`
@@ -151,30 +163,51 @@ func Decrypt(raw []byte, opt *Options) (packageBuf []byte, err error) {
 	return
 }
 
-// Encrypt API encrypt data with the password.
+// Encrypt API encrypt data with the password by ECMA-376 agile encryption,
+// and returns the encrypted data in the CFB file format. The hash algorithm,
+// the AES key size and the spin count of the encryption are specified by the
+// EncryptionHashAlgorithm, EncryptionKeyBits and EncryptionSpinCount options.
 func Encrypt(raw []byte, opt *Options) (packageBuf []byte, err error) {
-	// Generate a random key to use to encrypt the document. Excel uses 32 bytes. We'll use the password to encrypt this key.
-	packageKey, _ := randomBytes(32)
-	keyDataSaltValue, _ := randomBytes(16)
-	keyEncryptors, _ := randomBytes(16)
+	hashAlgorithm, keyBits, spinCount, err := encryptionParameters(opt)
+	if err != nil {
+		return
+	}
+	hashSize := hashFuncs[strings.ToLower(hashAlgorithm)]().Size()
+	// Generate a random key to use to encrypt the document, the password is
+	// used to encrypt this key.
+	packageKey, err := randomBytes(keyBits / 8)
+	if err != nil {
+		return
+	}
+	keyDataSaltValue, err := randomBytes(16)
+	if err != nil {
+		return
+	}
+	keyEncryptors, err := randomBytes(16)
+	if err != nil {
+		return
+	}
 	encryptionInfo := Encryption{
 		KeyData: KeyData{
-			BlockSize:       16,
-			KeyBits:         len(packageKey) * 8,
-			HashSize:        64,
+			SaltSize:        len(keyDataSaltValue),
+			BlockSize:       aes.BlockSize,
+			KeyBits:         keyBits,
+			HashSize:        hashSize,
 			CipherAlgorithm: "AES",
 			CipherChaining:  "ChainingModeCBC",
-			HashAlgorithm:   "SHA512",
+			HashAlgorithm:   hashAlgorithm,
 			SaltValue:       base64.StdEncoding.EncodeToString(keyDataSaltValue),
 		},
 		KeyEncryptors: KeyEncryptors{KeyEncryptor: []KeyEncryptor{{
-			EncryptedKey: EncryptedKey{SpinCount: 100000, KeyData: KeyData{
+			URI: "http://schemas.microsoft.com/office/2006/keyEncryptor/password",
+			EncryptedKey: EncryptedKey{SpinCount: spinCount, KeyData: KeyData{
+				SaltSize:        len(keyEncryptors),
+				BlockSize:       aes.BlockSize,
+				KeyBits:         keyBits,
+				HashSize:        hashSize,
 				CipherAlgorithm: "AES",
 				CipherChaining:  "ChainingModeCBC",
-				HashAlgorithm:   "SHA512",
-				HashSize:        64,
-				BlockSize:       16,
-				KeyBits:         256,
+				HashAlgorithm:   hashAlgorithm,
 				SaltValue:       base64.StdEncoding.EncodeToString(keyEncryptors)},
 			}}},
 		},
@@ -191,8 +224,9 @@ func Encrypt(raw []byte, opt *Options) (packageBuf []byte, err error) {
 	// Data Integrity
 
 	// Create the data integrity fields used by clients for integrity checks.
-	// Generate a random array of bytes to use in HMAC. The docs say to use the same length as the key salt, but Excel seems to use 64.
-	hmacKey, _ := randomBytes(64)
+	// Generate a random array of bytes to use in HMAC, which has the same
+	// length as the hash.
+	hmacKey, err := randomBytes(hashSize)
 	if err != nil {
 		return
 	}
@@ -203,11 +237,9 @@ func Encrypt(raw []byte, opt *Options) (packageBuf []byte, err error) {
 	}
 	// Use the package key and the IV to encrypt the HMAC key.
 	encryptedHmacKey, _ := crypt(true, encryptionInfo.KeyData.CipherAlgorithm, encryptionInfo.KeyData.CipherChaining, packageKey, hmacKeyIV, hmacKey)
-	// Create the HMAC.
-	h := hmac.New(sha512.New, append(hmacKey, encryptedPackage...))
-	for _, buf := range [][]byte{hmacKey, encryptedPackage} {
-		_, _ = h.Write(buf)
-	}
+	// Create the HMAC of the encrypted package stream.
+	h := hmac.New(hashFuncs[strings.ToLower(hashAlgorithm)], hmacKey)
+	_, _ = h.Write(encryptedPackage)
 	hmacValue := h.Sum(nil)
 	// Generate an initialization vector for encrypting the resulting HMAC value.
 	hmacValueIV, err := createIV(blockKeyHmacValue, encryptionInfo)
@@ -228,13 +260,19 @@ func Encrypt(raw []byte, opt *Options) (packageBuf []byte, err error) {
 		return
 	}
 	// Encrypt the package key with the encryption key.
-	encryptedKeyValue, _ := crypt(true, encryptionInfo.KeyEncryptors.KeyEncryptor[0].EncryptedKey.CipherAlgorithm, encryptionInfo.KeyEncryptors.KeyEncryptor[0].EncryptedKey.CipherChaining, key, keyEncryptors, packageKey)
+	encryptedKeyValue, err := crypt(true, encryptionInfo.KeyEncryptors.KeyEncryptor[0].EncryptedKey.CipherAlgorithm, encryptionInfo.KeyEncryptors.KeyEncryptor[0].EncryptedKey.CipherChaining, key, keyEncryptors, packageKey)
+	if err != nil {
+		return
+	}
 	encryptionInfo.KeyEncryptors.KeyEncryptor[0].EncryptedKey.EncryptedKeyValue = base64.StdEncoding.EncodeToString(encryptedKeyValue)
 
 	// Verifier hash
 
 	// Create a random byte array for hashing.
-	verifierHashInput, _ := randomBytes(16)
+	verifierHashInput, err := randomBytes(16)
+	if err != nil {
+		return
+	}
 	// Create an encryption key from the password for the input.
 	verifierHashInputKey, err := convertPasswdToKey(opt.Password, blockKeyVerifierHashInput, encryptionInfo)
 	if err != nil {
@@ -264,9 +302,39 @@ func Encrypt(raw []byte, opt *Options) (packageBuf []byte, err error) {
 	if err != nil {
 		return
 	}
-	// TODO: Create a new CFB.
-	_, _ = encryptedPackage, encryptionInfoBuffer
-	err = ErrEncrypt
+	// The encryption info stream begins with the version 4.4 of the agile
+	// encryption and the reserved flags.
+	encryptionInfoBuffer = append(append([]byte{0x04, 0x00, 0x04, 0x00, 0x40, 0x00, 0x00, 0x00},
+		strings.ReplaceAll(xml.Header, "\n", "\r\n")...), encryptionInfoBuffer...)
+	return writeCFB([]cfbStream{
+		{name: "EncryptionInfo", data: encryptionInfoBuffer},
+		{name: "EncryptedPackage", data: encryptedPackage},
+	}), nil
+}
+
+// encryptionParameters returns the hash algorithm, the key size in bits and
+// the spin count of the agile encryption by given options, and fills the
+// default values.
+func encryptionParameters(opt *Options) (hashAlgorithm string, keyBits, spinCount int, err error) {
+	hashAlgorithm, keyBits, spinCount = "SHA512", 256, 100000
+	if opt.EncryptionHashAlgorithm != "" {
+		hashAlgorithm = strings.ToUpper(opt.EncryptionHashAlgorithm)
+	}
+	if opt.EncryptionKeyBits != 0 {
+		keyBits = opt.EncryptionKeyBits
+	}
+	if opt.EncryptionSpinCount != 0 {
+		spinCount = opt.EncryptionSpinCount
+	}
+	if _, ok := hashFuncs[strings.ToLower(hashAlgorithm)]; !ok {
+		err = ErrEncryptionOptions
+	}
+	if keyBits != 128 && keyBits != 192 && keyBits != 256 {
+		err = ErrEncryptionOptions
+	}
+	if spinCount < 0 || spinCount > 10000000 {
+		err = ErrEncryptionOptions
+	}
 	return
 }
 
@@ -276,13 +344,13 @@ func extractPart(doc *mscfb.Reader) (encryptionInfoBuf, encryptedPackageBuf []by
 		switch entry.Name {
 		case "EncryptionInfo":
 			buf := make([]byte, entry.Size)
-			i, _ := doc.Read(buf)
+			i, _ := io.ReadFull(doc, buf)
 			if i > 0 {
 				encryptionInfoBuf = buf
 			}
 		case "EncryptedPackage":
 			buf := make([]byte, entry.Size)
-			i, _ := doc.Read(buf)
+			i, _ := io.ReadFull(doc, buf)
 			if i > 0 {
 				encryptedPackageBuf = buf
 			}
@@ -439,6 +507,9 @@ func agileDecrypt(encryptionInfoBuf, encryptedPackageBuf []byte, opt *Options) (
 		return
 	}
 	packageKey, _ := crypt(false, encryptedKey.CipherAlgorithm, encryptedKey.CipherChaining, key, saltValue, encryptedKeyValue)
+	if keyBytes := encryptionInfo.KeyData.KeyBits / 8; keyBytes > 0 && keyBytes < len(packageKey) {
+		packageKey = packageKey[:keyBytes]
+	}
 	// Use the package key to decrypt the package.
 	return cryptPackage(false, packageKey, encryptedPackageBuf, encryptionInfo)
 }
@@ -469,8 +540,7 @@ func convertPasswdToKey(passwd string, blockKey []byte, encryption Encryption) (
 	// Truncate or pad as needed to get to length of keyBits.
 	keyBytes := encryption.KeyEncryptors.KeyEncryptor[0].EncryptedKey.KeyBits / 8
 	if len(key) < keyBytes {
-		tmp := make([]byte, 0x36)
-		key = append(key, tmp...)
+		key = append(key, bytes.Repeat([]byte{0x36}, keyBytes-len(key))...)
 	} else if len(key) > keyBytes {
 		key = key[:keyBytes]
 	}
@@ -479,19 +549,11 @@ func convertPasswdToKey(passwd string, blockKey []byte, encryption Encryption) (
 
 // hashing data by specified hash algorithm.
 func hashing(hashAlgorithm string, buffer ...[]byte) (key []byte) {
-	var hashMap = map[string]hash.Hash{
-		"md4":        md4.New(),
-		"md5":        md5.New(),
-		"ripemd-160": ripemd160.New(),
-		"sha1":       sha1.New(),
-		"sha256":     sha256.New(),
-		"sha384":     sha512.New384(),
-		"sha512":     sha512.New(),
-	}
-	handler, ok := hashMap[strings.ToLower(hashAlgorithm)]
+	newHash, ok := hashFuncs[strings.ToLower(hashAlgorithm)]
 	if !ok {
 		return key
 	}
+	handler := newHash()
 	for _, buf := range buffer {
 		_, _ = handler.Write(buf)
 	}
@@ -514,7 +576,8 @@ func parseEncryptionInfo(encryptionInfo []byte) (encryption Encryption, err erro
 }
 
 // crypt encrypt / decrypt input by given cipher algorithm, cipher chaining,
-// key and initialization vector.
+// key and initialization vector. The input is padded with zeros to an integer
+// multiple of the block size, and it is not modified.
 func crypt(encrypt bool, cipherAlgorithm, cipherChaining string, key, iv, input []byte) (packageKey []byte, err error) {
 	block, err := aes.NewCipher(key)
 	if err != nil {
@@ -526,55 +589,48 @@ func crypt(encrypt bool, cipherAlgorithm, cipherChaining string, key, iv, input
 	} else {
 		stream = cipher.NewCBCDecrypter(block, iv)
 	}
-	stream.CryptBlocks(input, input)
-	return input, nil
+	output := make([]byte, (len(input)+block.BlockSize()-1)/block.BlockSize()*block.BlockSize())
+	copy(output, input)
+	stream.CryptBlocks(output, output)
+	return output, nil
 }
 
 // cryptPackage encrypt / decrypt package by given packageKey and encryption
-// info.
+// info. The encrypted package begins with the size of the decrypted package,
+// followed by the chunks encrypted separately.
 func cryptPackage(encrypt bool, packageKey, input []byte, encryption Encryption) (outputChunks []byte, err error) {
 	encryptedKey := encryption.KeyData
-	var offset = packageOffset
-	if encrypt {
-		offset = 0
+	size := len(input)
+	if !encrypt {
+		if len(input) < packageOffset {
+			return nil, ErrUnknownEncryptMechanism
+		}
+		size = int(binary.LittleEndian.Uint64(input[:packageOffset]))
+		input = input[packageOffset:]
 	}
-	var i, start, end int
 	var iv, outputChunk []byte
-	for end < len(input) {
-		start = end
-		end = start + packageEncryptionChunkSize
-
+	for i, start := 0, 0; start < len(input); i, start = i+1, start+packageEncryptionChunkSize {
+		end := start + packageEncryptionChunkSize
 		if end > len(input) {
 			end = len(input)
 		}
-		// Grab the next chunk
-		var inputChunk []byte
-		if (end + offset) < len(input) {
-			inputChunk = input[start+offset : end+offset]
-		} else {
-			inputChunk = input[start+offset : end]
-		}
-
-		// Pad the chunk if it is not an integer multiple of the block size
-		remainder := len(inputChunk) % encryptedKey.BlockSize
-		if remainder != 0 {
-			inputChunk = append(inputChunk, make([]byte, encryptedKey.BlockSize-remainder)...)
-		}
 		// Create the initialization vector
 		iv, err = createIV(i, encryption)
 		if err != nil {
 			return
 		}
 		// Encrypt/decrypt the chunk and add it to the array
-		outputChunk, err = crypt(encrypt, encryptedKey.CipherAlgorithm, encryptedKey.CipherChaining, packageKey, iv, inputChunk)
+		outputChunk, err = crypt(encrypt, encryptedKey.CipherAlgorithm, encryptedKey.CipherChaining, packageKey, iv, input[start:end])
 		if err != nil {
 			return
 		}
 		outputChunks = append(outputChunks, outputChunk...)
-		i++
 	}
 	if encrypt {
-		outputChunks = append(createUInt32LEBuffer(len(input), 8), outputChunks...)
+		return append(createUInt32LEBuffer(size, packageOffset), outputChunks...), err
+	}
+	if size < len(outputChunks) {
+		outputChunks = outputChunks[:size]
 	}
 	return
 }
@@ -597,8 +653,7 @@ func createIV(blockKey interface{}, encryption Encryption) ([]byte, error) {
 	// Truncate or pad as needed to meet the block size.
 	iv := hashing(encryptedKey.HashAlgorithm, append(saltValue, blockKeyBuf...))
 	if len(iv) < encryptedKey.BlockSize {
-		tmp := make([]byte, 0x36)
-		iv = append(iv, tmp...)
+		iv = append(iv, bytes.Repeat([]byte{0x36}, encryptedKey.BlockSize-len(iv))...)
 	} else if len(iv) > encryptedKey.BlockSize {
 		iv = iv[:encryptedKey.BlockSize]
 	}
@@ -613,3 +668,221 @@ func randomBytes(n int) ([]byte, error) {
 	_, err := rand.Read(b)
 	return b, err
 }
+
+// Compound File Binary
+
+const (
+	cfbSectorSize       = 512
+	cfbMiniSectorSize   = 64
+	cfbMiniStreamCutoff = 4096
+	cfbDirEntrySize     = 128
+	cfbHeaderDIFATSize  = 109
+	cfbDIFATSect        = 0xFFFFFFFC
+	cfbFATSect          = 0xFFFFFFFD
+	cfbEndOfChain       = 0xFFFFFFFE
+	cfbFreeSect         = 0xFFFFFFFF
+	cfbNoStream         = 0xFFFFFFFF
+)
+
+// cfbStream specifies a stream in the root storage of the compound file.
+type cfbStream struct {
+	name   string
+	data   []byte
+	start  int
+	sector int
+}
+
+// writeCFB creates a compound file binary of the major version 3, which
+// contains the given streams in the root storage. The streams smaller than
+// the mini stream cutoff size are stored in the mini stream.
+func writeCFB(streams []cfbStream) []byte {
+	sort.Slice(streams, func(i, j int) bool { return cfbLessName(streams[i].name, streams[j].name) })
+	ceil := func(a, b int) int { return (a + b - 1) / b }
+	var miniSectors, dataSectors int
+	for i := range streams {
+		if len(streams[i].data) < cfbMiniStreamCutoff {
+			streams[i].start, miniSectors = miniSectors, miniSectors+ceil(len(streams[i].data), cfbMiniSectorSize)
+			continue
+		}
+		streams[i].start, dataSectors = dataSectors, dataSectors+ceil(len(streams[i].data), cfbSectorSize)
+	}
+	dirSectors := ceil((len(streams)+1)*cfbDirEntrySize, cfbSectorSize)
+	miniFATSectors := ceil(miniSectors*4, cfbSectorSize)
+	miniStreamSectors := ceil(miniSectors*cfbMiniSectorSize, cfbSectorSize)
+	sectors := dirSectors + miniFATSectors + miniStreamSectors + dataSectors
+	// The FAT sectors and the DIFAT sectors are allocated in the FAT too.
+	var fatSectors, difatSectors int
+	for {
+		fat := ceil((sectors+fatSectors+difatSectors)*4, cfbSectorSize)
+		var difat int
+		if fat > cfbHeaderDIFATSize {
+			difat = ceil(fat-cfbHeaderDIFATSize, cfbSectorSize/4-1)
+		}
+		if fat == fatSectors && difat == difatSectors {
+			break
+		}
+		fatSectors, difatSectors = fat, difat
+	}
+	miniFATStart, miniStreamStart := dirSectors, dirSectors+miniFATSectors
+	dataStart := miniStreamStart + miniStreamSectors
+	fatStart, difatStart := sectors, sectors+fatSectors
+	buf := make([]byte, cfbSectorSize*(1+difatStart+difatSectors))
+	sector := func(i int) []byte { return buf[cfbSectorSize*(1+i) : cfbSectorSize*(2+i)] }
+	putUint32 := func(b []byte, i int, v uint32) { binary.LittleEndian.PutUint32(b[i*4:], v) }
+	// Allocate the chains in the FAT and the mini FAT.
+	fat, miniFAT := make([]uint32, fatSectors*cfbSectorSize/4), make([]uint32, miniFATSectors*cfbSectorSize/4)
+	for _, table := range [][]uint32{fat, miniFAT} {
+		for i := range table {
+			table[i] = cfbFreeSect
+		}
+	}
+	chain := func(table []uint32, start, count int) {
+		for i := start; i < start+count; i++ {
+			table[i] = uint32(i + 1)
+		}
+		if count > 0 {
+			table[start+count-1] = cfbEndOfChain
+		}
+	}
+	chain(fat, 0, dirSectors)
+	chain(fat, miniFATStart, miniFATSectors)
+	chain(fat, miniStreamStart, miniStreamSectors)
+	for i := range streams {
+		n := len(streams[i].data)
+		if n < cfbMiniStreamCutoff {
+			streams[i].sector = streams[i].start
+			chain(miniFAT, streams[i].start, ceil(n, cfbMiniSectorSize))
+			copy(buf[cfbSectorSize*(1+miniStreamStart)+cfbMiniSectorSize*streams[i].start:], streams[i].data)
+			continue
+		}
+		streams[i].sector = dataStart + streams[i].start
+		chain(fat, streams[i].sector, ceil(n, cfbSectorSize))
+		copy(buf[cfbSectorSize*(1+streams[i].sector):], streams[i].data)
+	}
+	for i := 0; i < fatSectors; i++ {
+		fat[fatStart+i] = cfbFATSect
+	}
+	for i := 0; i < difatSectors; i++ {
+		fat[difatStart+i] = cfbDIFATSect
+	}
+	for i, v := range fat {
+		putUint32(buf[cfbSectorSize*(1+fatStart):], i, v)
+	}
+	for i, v := range miniFAT {
+		putUint32(buf[cfbSectorSize*(1+miniFATStart):], i, v)
+	}
+	// Write the directory entries, the streams are the children of the root
+	// storage in a red-black tree.
+	left, right, depth := make([]uint32, len(streams)), make([]uint32, len(streams)), make([]int, len(streams))
+	var maxDepth int
+	var tree func(lo, hi, d int) uint32
+	tree = func(lo, hi, d int) uint32 {
+		if lo > hi {
+			return cfbNoStream
+		}
+		mid := (lo + hi) / 2
+		left[mid], right[mid], depth[mid] = tree(lo, mid-1, d+1), tree(mid+1, hi, d+1), d
+		if d > maxDepth {
+			maxDepth = d
+		}
+		return uint32(mid + 1)
+	}
+	root := tree(0, len(streams)-1, 0)
+	rootStart := uint32(miniStreamStart)
+	if miniStreamSectors == 0 {
+		rootStart = cfbEndOfChain
+	}
+	for i := 0; i < dirSectors*cfbSectorSize/cfbDirEntrySize; i++ {
+		entry := buf[cfbSectorSize+cfbDirEntrySize*i : cfbSectorSize+cfbDirEntrySize*(i+1)]
+		putUint32(entry, 17, cfbNoStream)
+		putUint32(entry, 18, cfbNoStream)
+		putUint32(entry, 19, cfbNoStream)
+		switch {
+		case i == 0:
+			writeCFBDirEntry(entry, "Root Entry", 5, 1, rootStart, miniSectors*cfbMiniSectorSize)
+			putUint32(entry, 19, root)
+		case i <= len(streams):
+			s, color := streams[i-1], byte(1)
+			if maxDepth > 0 && depth[i-1] == maxDepth {
+				color = 0
+			}
+			start := uint32(s.sector)
+			if len(s.data) == 0 {
+				start = cfbEndOfChain
+			}
+			writeCFBDirEntry(entry, s.name, 2, color, start, len(s.data))
+			putUint32(entry, 17, left[i-1])
+			putUint32(entry, 18, right[i-1])
+		}
+	}
+	// Write the DIFAT in the header and the DIFAT sectors.
+	header := buf[:cfbSectorSize]
+	copy(header, oleIdentifier)
+	binary.LittleEndian.PutUint16(header[24:], 0x003E)
+	binary.LittleEndian.PutUint16(header[26:], 0x0003)
+	binary.LittleEndian.PutUint16(header[28:], 0xFFFE)
+	binary.LittleEndian.PutUint16(header[30:], 9)
+	binary.LittleEndian.PutUint16(header[32:], 6)
+	putUint32(header, 11, uint32(fatSectors))
+	putUint32(header, 12, 0)
+	putUint32(header, 14, cfbMiniStreamCutoff)
+	putUint32(header, 15, cfbEndOfChain)
+	if miniFATSectors > 0 {
+		putUint32(header, 15, uint32(miniFATStart))
+	}
+	putUint32(header, 16, uint32(miniFATSectors))
+	putUint32(header, 17, cfbEndOfChain)
+	if difatSectors > 0 {
+		putUint32(header, 17, uint32(difatStart))
+	}
+	putUint32(header, 18, uint32(difatSectors))
+	for i := 0; i < cfbHeaderDIFATSize; i++ {
+		putUint32(header, 19+i, cfbFreeSect)
+		if i < fatSectors {
+			putUint32(header, 19+i, uint32(fatStart+i))
+		}
+	}
+	for i := 0; i < difatSectors; i++ {
+		difat := sector(difatStart + i)
+		for j := 0; j < cfbSectorSize/4-1; j++ {
+			putUint32(difat, j, cfbFreeSect)
+			if k := cfbHeaderDIFATSize + i*(cfbSectorSize/4-1) + j; k < fatSectors {
+				putUint32(difat, j, uint32(fatStart+k))
+			}
+		}
+		putUint32(difat, cfbSectorSize/4-1, cfbEndOfChain)
+		if i < difatSectors-1 {
+			putUint32(difat, cfbSectorSize/4-1, uint32(difatStart+i+1))
+		}
+	}
+	return buf
+}
+
+// writeCFBDirEntry writes the name, object type, color, starting sector and
+// stream size of a directory entry in the compound file binary.
+func writeCFBDirEntry(entry []byte, name string, objectType, color byte, start uint32, size int) {
+	name16 := utf16.Encode([]rune(name))
+	for i, c := range name16 {
+		binary.LittleEndian.PutUint16(entry[i*2:], c)
+	}
+	binary.LittleEndian.PutUint16(entry[64:], uint16(len(name16)+1)*2)
+	entry[66], entry[67] = objectType, color
+	binary.LittleEndian.PutUint32(entry[116:], start)
+	binary.LittleEndian.PutUint64(entry[120:], uint64(size))
+}
+
+// cfbLessName reports whether the directory entry name a sorts before b in
+// the compound file binary, the shorter names are first and the names of the
+// same length are compared in upper case.
+func cfbLessName(a, b string) bool {
+	a16, b16 := utf16.Encode([]rune(strings.ToUpper(a))), utf16.Encode([]rune(strings.ToUpper(b)))
+	if len(a16) != len(b16) {
+		return len(a16) < len(b16)
+	}
+	for i := range a16 {
+		if a16[i] != b16[i] {
+			return a16[i] < b16[i]
+		}
+	}
+	return false
+}
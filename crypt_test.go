@@ -12,17 +12,115 @@
 package excelize
 
 import (
+	"bytes"
+	"io"
 	"path/filepath"
+	"strconv"
 	"testing"
 
+	"github.com/richardlehane/mscfb"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestEncrypt(t *testing.T) {
 	f, err := OpenFile(filepath.Join("test", "encryptSHA1.xlsx"), Options{Password: "password"})
 	assert.NoError(t, err)
-	assert.EqualError(t, f.SaveAs(filepath.Join("test", "BadEncrypt.xlsx"), Options{Password: "password"}), "not support encryption currently")
+	assert.NoError(t, f.SaveAs(filepath.Join("test", "TestEncrypt.xlsx"), Options{Password: "passwd"}))
 	assert.NoError(t, f.Close())
+	_, err = OpenFile(filepath.Join("test", "TestEncrypt.xlsx"), Options{Password: "password"})
+	assert.Error(t, err)
+	f, err = OpenFile(filepath.Join("test", "TestEncrypt.xlsx"), Options{Password: "passwd"})
+	assert.NoError(t, err)
+	cell, err := f.GetCellValue("Sheet1", "A1")
+	assert.NoError(t, err)
+	assert.Equal(t, "SECRET", cell)
+	assert.NoError(t, f.Close())
+
+	// Test encrypt with the cipher parameters.
+	for _, opts := range []Options{
+		{EncryptionHashAlgorithm: "SHA1", EncryptionKeyBits: 128, EncryptionSpinCount: 1000},
+		{EncryptionHashAlgorithm: "md5", EncryptionKeyBits: 192, EncryptionSpinCount: 1},
+		{EncryptionHashAlgorithm: "SHA384"},
+	} {
+		f = NewFile()
+		assert.NoError(t, f.SetCellValue("Sheet1", "A1", opts.EncryptionHashAlgorithm))
+		opts.Password = "passwd"
+		f.options = &opts
+		buf, err := f.WriteToBuffer()
+		assert.NoError(t, err)
+		f, err = OpenReader(buf, Options{Password: "passwd"})
+		assert.NoError(t, err)
+		cell, err := f.GetCellValue("Sheet1", "A1")
+		assert.NoError(t, err)
+		assert.Equal(t, opts.EncryptionHashAlgorithm, cell)
+	}
+	for _, opts := range []Options{
+		{EncryptionHashAlgorithm: "SHA3"},
+		{EncryptionKeyBits: 64},
+		{EncryptionSpinCount: -1},
+	} {
+		opts.Password = "passwd"
+		_, err = Encrypt([]byte{}, &opts)
+		assert.EqualError(t, err, ErrEncryptionOptions.Error())
+	}
+}
+
+func TestEncryptDirectWriter(t *testing.T) {
+	for _, streaming := range []bool{false, true} {
+		f := NewFile(Options{Password: "passwd", EncryptionSpinCount: 1})
+		dw, err := f.NewDirectWriter("Sheet1", 1)
+		assert.NoError(t, err)
+		var out bytes.Buffer
+		ch := make(chan error)
+		if streaming {
+			go func() {
+				_, err := f.WriteTo(&out)
+				ch <- err
+			}()
+			waitDirectWriterOut(dw)
+		}
+		for row := 1; row <= 1000; row++ {
+			_, err = dw.AddRow([]Cell{{Value: row}, {Value: "Row" + strconv.Itoa(row)}})
+			assert.NoError(t, err)
+		}
+		assert.NoError(t, dw.Close())
+		if streaming {
+			assert.NoError(t, <-ch)
+		} else {
+			_, err = f.WriteTo(&out)
+			assert.NoError(t, err)
+		}
+		assert.Equal(t, oleIdentifier, out.Bytes()[:len(oleIdentifier)])
+		f, err = OpenReader(&out, Options{Password: "passwd"})
+		assert.NoError(t, err)
+		rows, err := f.GetRows("Sheet1")
+		assert.NoError(t, err)
+		assert.Len(t, rows, 1000)
+		assert.Equal(t, []string{"1000", "Row1000"}, rows[999])
+	}
+}
+
+func TestWriteCFB(t *testing.T) {
+	// Test the streams in the mini stream, the regular sectors and the DIFAT
+	// sectors, which are required over 109 FAT sectors.
+	for _, size := range []int{0, 100, cfbMiniStreamCutoff, 8 << 20} {
+		streams := []cfbStream{
+			{name: "EncryptionInfo", data: bytes.Repeat([]byte{1}, size%1000)},
+			{name: "EncryptedPackage", data: bytes.Repeat([]byte{2}, size)},
+			{name: "A", data: []byte{3}},
+		}
+		doc, err := mscfb.New(bytes.NewReader(writeCFB(streams)))
+		assert.NoError(t, err)
+		entries := map[string][]byte{}
+		for entry, err := doc.Next(); err == nil; entry, err = doc.Next() {
+			buf := make([]byte, entry.Size)
+			_, _ = io.ReadFull(doc, buf)
+			entries[entry.Name] = buf
+		}
+		for _, stream := range streams {
+			assert.Equal(t, stream.data, entries[stream.name], stream.name)
+		}
+	}
 }
 
 func TestEncryptionMechanism(t *testing.T) {
@@ -0,0 +1,211 @@
+// Copyright 2016 - 2021 The excelize Authors. All rights reserved. Use of
+// this source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+//
+// Package excelize providing a set of functions that allow you to write to
+// and read from XLSX / XLSM / XLTM files. Supports reading and writing
+// spreadsheet documents generated by Microsoft Excel™ 2007 and later. Supports
+// complex components by high compatibility, and provided streaming API for
+// generating or reading data from a worksheet with huge amounts of data. This
+// library needs Go version 1.15 or later.
+
+package excelize
+
+import (
+	"encoding/csv"
+	"io"
+	"strings"
+	"time"
+)
+
+// defaultCSVImportBufferSize defined the default buffer size of the direct
+// writer used by ImportCSVStream.
+const defaultCSVImportBufferSize = 1 << 16
+
+// CSVImportOptions defined the options for importing CSV data by
+// ImportCSVStream.
+//
+// Comma specifies the field delimiter, the default is ','.
+//
+// InferTypes specifies if convert the fields to numeric, boolean and date
+// cells, otherwise all fields are imported as text. Decimal numbers are
+// written as they are, except for numbers with leading zeros such as "0123"
+// and numbers with more than 15 digits, which are usually identifiers and
+// kept as text. The fields "TRUE" and "FALSE" are imported as boolean in any
+// case. Dates in the format of "2006-01-02", "2006-01-02 15:04:05" or RFC 3339
+// are imported as date values with a date number format.
+//
+// Header specifies if the first record is a header row, which is imported as
+// text with the style HeaderStyleID.
+//
+// MaxBufferSize specifies the buffer size in bytes of the DirectWriter, the
+// default is 64KB.
+type CSVImportOptions struct {
+	Comma         rune
+	InferTypes    bool
+	Header        bool
+	HeaderStyleID int
+	MaxBufferSize int
+}
+
+// ImportCSVStream provides a function to import CSV data from the reader to
+// the given worksheet through a DirectWriter, record by record, so that large
+// CSV files can be converted without loading all the data. For example,
+// convert a CSV file with a bold header row:
+//
+//    f := excelize.NewFile()
+//    style, err := f.NewStyle(&excelize.Style{Font: &excelize.Font{Bold: true}})
+//    if err != nil {
+//        fmt.Println(err)
+//    }
+//    ch := make(chan error)
+//    go func() {
+//        ch <- f.ImportCSVStream("Sheet1", csvFile, excelize.CSVImportOptions{
+//            InferTypes: true, Header: true, HeaderStyleID: style,
+//        })
+//    }()
+//    if err := f.SaveAs("Book1.xlsx"); err != nil {
+//        fmt.Println(err)
+//    }
+//    if err := <-ch; err != nil {
+//        fmt.Println(err)
+//    }
+//
+// Only one record and the write buffer are held in memory at a time when the
+// workbook is saved concurrently as above, otherwise the whole worksheet is
+// buffered until the workbook is saved. The DirectWriter is closed even if
+// the CSV data is malformed, so the worksheet contains the records before the
+// error.
+func (f *File) ImportCSVStream(sheet string, r io.Reader, opts CSVImportOptions) error {
+	bufferSize := opts.MaxBufferSize
+	if bufferSize <= 0 {
+		bufferSize = defaultCSVImportBufferSize
+	}
+	dw, err := f.NewDirectWriter(sheet, bufferSize)
+	if err != nil {
+		return err
+	}
+	cr := csv.NewReader(r)
+	if opts.Comma != 0 {
+		cr.Comma = opts.Comma
+	}
+	cr.FieldsPerRecord = -1
+	cr.ReuseRecord = true
+	imp := csvImporter{f: f, opts: &opts}
+	var row []Cell
+	for header := opts.Header; ; header = false {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			_ = dw.Close()
+			return err
+		}
+		row = row[:0]
+		for _, field := range record {
+			cell, err := imp.cell(field, header)
+			if err != nil {
+				_ = dw.Close()
+				return err
+			}
+			row = append(row, cell)
+		}
+		if _, err = dw.AddRow(row); err != nil {
+			_ = dw.Close()
+			return err
+		}
+	}
+	return dw.Close()
+}
+
+// csvImporter converts the fields of CSV records to cells.
+type csvImporter struct {
+	f                        *File
+	opts                     *CSVImportOptions
+	dateStyle, dateTimeStyle int
+}
+
+// cell provides a function to convert the given field to a cell.
+func (imp *csvImporter) cell(field string, header bool) (Cell, error) {
+	if header {
+		return Cell{Value: field, StyleID: imp.opts.HeaderStyleID}, nil
+	}
+	if !imp.opts.InferTypes || field == "" {
+		return Cell{Value: field}, nil
+	}
+	if isCSVNumber(field) {
+		return Cell{RawValue: []byte(field)}, nil
+	}
+	if strings.EqualFold(field, "TRUE") || strings.EqualFold(field, "FALSE") {
+		return Cell{Value: strings.EqualFold(field, "TRUE")}, nil
+	}
+	if len(field) >= 10 && field[4] == '-' && field[7] == '-' {
+		return imp.dateCell(field)
+	}
+	return Cell{Value: field}, nil
+}
+
+// dateCell provides a function to convert the given field to a date cell if
+// it's a date, the date number formats are created on first use.
+func (imp *csvImporter) dateCell(field string) (Cell, error) {
+	var err error
+	if t, e := time.Parse("2006-01-02", field); e == nil {
+		if imp.dateStyle == 0 {
+			imp.dateStyle, err = imp.f.NewStyle(&Style{NumFmt: 14})
+		}
+		return Cell{Value: t, StyleID: imp.dateStyle}, err
+	}
+	for _, layout := range []string{"2006-01-02 15:04:05", time.RFC3339} {
+		if t, e := time.Parse(layout, field); e == nil {
+			if imp.dateTimeStyle == 0 {
+				imp.dateTimeStyle, err = imp.f.NewStyle(&Style{NumFmt: 22})
+			}
+			return Cell{Value: t, StyleID: imp.dateTimeStyle}, err
+		}
+	}
+	return Cell{Value: field}, nil
+}
+
+// isCSVNumber reports whether the field is a decimal number which can be
+// written as a numeric cell value as it is. Numbers with leading zeros or more
+// than 15 digits are not matched, since they are usually identifiers which
+// would lose leading zeros or precision as numbers, nor the numbers which
+// overflow float64.
+func isCSVNumber(s string) bool {
+	i, digits := 0, 0
+	if i < len(s) && s[i] == '-' {
+		i++
+	}
+	skipDigits := func() int {
+		start := i
+		for i < len(s) && '0' <= s[i] && s[i] <= '9' {
+			i++
+		}
+		digits += i - start
+		return i - start
+	}
+	start := i
+	if n := skipDigits(); n == 0 || n > 1 && s[start] == '0' {
+		return false
+	}
+	if i < len(s) && s[i] == '.' {
+		i++
+		if skipDigits() == 0 {
+			return false
+		}
+	}
+	if digits > 15 {
+		return false
+	}
+	if i < len(s) && (s[i] == 'e' || s[i] == 'E') {
+		i++
+		if i < len(s) && (s[i] == '+' || s[i] == '-') {
+			i++
+		}
+		if skipDigits() == 0 {
+			return false
+		}
+	}
+	return i == len(s) && isFloatInRange(s)
+}
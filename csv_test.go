@@ -0,0 +1,153 @@
+package excelize
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func benchmarkCSV() []byte {
+	var buf bytes.Buffer
+	for row := 0; row < 10000; row++ {
+		fmt.Fprintf(&buf, "%d,%s,%.2f,%d,true\n", row, "This is test data", float64(row)/3, row*row)
+	}
+	return buf.Bytes()
+}
+
+func BenchmarkImportCSVStream(b *testing.B) {
+	data := benchmarkCSV()
+	for i := 0; i < b.N; i++ {
+		f := NewFile()
+		ch := make(chan error)
+		go func() {
+			ch <- f.ImportCSVStream("Sheet1", bytes.NewReader(data), CSVImportOptions{InferTypes: true})
+		}()
+		_, err := f.WriteTo(io.Discard)
+		require.NoError(b, err)
+		require.NoError(b, <-ch)
+	}
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+}
+
+func BenchmarkImportCSVManual(b *testing.B) {
+	data := benchmarkCSV()
+	for i := 0; i < b.N; i++ {
+		f := NewFile()
+		dw, err := f.NewDirectWriter("Sheet1", defaultCSVImportBufferSize)
+		require.NoError(b, err)
+		ch := make(chan error)
+		go func() {
+			cr := csv.NewReader(bytes.NewReader(data))
+			for {
+				record, err := cr.Read()
+				if err == io.EOF {
+					break
+				}
+				require.NoError(b, err)
+				row := make([]Cell, len(record))
+				for i, field := range record {
+					if v, err := strconv.ParseFloat(field, 64); err == nil {
+						row[i].Value = v
+					} else if v, err := strconv.ParseBool(field); err == nil {
+						row[i].Value = v
+					} else {
+						row[i].Value = field
+					}
+				}
+				_, err = dw.AddRow(row)
+				require.NoError(b, err)
+			}
+			ch <- dw.Close()
+		}()
+		_, err = f.WriteTo(io.Discard)
+		require.NoError(b, err)
+		require.NoError(b, <-ch)
+	}
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+}
+
+func TestImportCSVStream(t *testing.T) {
+	const data = "id;name;amount;active;date;time\n" +
+		"1;foo;-12.5;TRUE;2021-11-29;2021-11-29 10:30:00\n" +
+		"0123;\"bar; baz\";1e3;false;2021-13-01;2021-11-29T10:30:00Z\n" +
+		"1234567890123456;;;\n"
+	f := NewFile()
+	header, err := f.NewStyle(&Style{Font: &Font{Bold: true}})
+	require.NoError(t, err)
+	var out bytes.Buffer
+	ch := make(chan error)
+	go func() {
+		_, err := f.WriteTo(&out)
+		ch <- err
+	}()
+	assert.NoError(t, f.ImportCSVStream("Sheet1", strings.NewReader(data), CSVImportOptions{
+		Comma: ';', InferTypes: true, Header: true, HeaderStyleID: header, MaxBufferSize: 1,
+	}))
+	require.NoError(t, <-ch)
+
+	f2, err := OpenReader(&out)
+	require.NoError(t, err)
+	for cell, expected := range map[string]string{
+		"A1": "id", "A2": "1", "A3": "0123", "A4": "1234567890123456",
+		"B2": "foo", "B3": "bar; baz", "C2": "-12.5", "C3": "1000",
+		"D2": "TRUE", "D3": "FALSE", "E2": "11-29-21", "E3": "2021-13-01",
+		"F2": "11/29/21 10:30", "F3": "11/29/21 10:30",
+	} {
+		val, err := f2.GetCellValue("Sheet1", cell)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, val, cell)
+	}
+	ws, err := f2.workSheetReader("Sheet1")
+	require.NoError(t, err)
+	for cell, expected := range map[string]string{"A1": "str", "A2": "", "A3": "str", "A4": "str", "C3": "", "D2": "b", "E2": "", "E3": "str"} {
+		col, row, _ := CellNameToCoordinates(cell)
+		assert.Equal(t, expected, ws.SheetData.Row[row-1].C[col-1].T, cell)
+	}
+	style, err := f2.GetCellStyle("Sheet1", "B1")
+	assert.NoError(t, err)
+	assert.Equal(t, header, style)
+
+	// test import without type inference
+	f = NewFile()
+	assert.NoError(t, f.ImportCSVStream("Sheet1", strings.NewReader("1,TRUE\n"), CSVImportOptions{}))
+	buf, err := f.WriteToBuffer()
+	require.NoError(t, err)
+	f2, err = OpenReader(buf)
+	require.NoError(t, err)
+	ws, err = f2.workSheetReader("Sheet1")
+	require.NoError(t, err)
+	assert.Equal(t, "str", ws.SheetData.Row[0].C[0].T)
+	assert.Equal(t, "str", ws.SheetData.Row[0].C[1].T)
+
+	// test import malformed CSV data, the records before the error are kept
+	f = NewFile()
+	err = f.ImportCSVStream("Sheet1", strings.NewReader("a,b\nc,d\"e\n"), CSVImportOptions{})
+	assert.Error(t, err)
+	buf, err = f.WriteToBuffer()
+	require.NoError(t, err)
+	f2, err = OpenReader(buf)
+	require.NoError(t, err)
+	rows, err := f2.GetRows("Sheet1")
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{{"a", "b"}}, rows)
+}
+
+func TestIsCSVNumber(t *testing.T) {
+	for s, expected := range map[string]bool{
+		"0": true, "-1": true, "12.50": true, "0.5": true, "1e3": true, "-1.5E-10": true, "123456789012345": true,
+		"": false, "-": false, "0123": false, "1.": false, ".5": false, "1e": false, "+1": false, "1,5": false,
+		"1234567890123456": false, "0.1234567890123456": false, "NaN": false, "Inf": false, "0x1F": false, " 1": false,
+		"1e400": false, "-1.5E+999": false, "1e-400": true,
+	} {
+		assert.Equal(t, expected, isCSVNumber(s), s)
+	}
+}
@@ -267,6 +267,18 @@ func (f *File) AddDataValidation(sheet string, dv *DataValidation) error {
 	return err
 }
 
+// GetDataValidations returns data validations list by given worksheet name.
+func (f *File) GetDataValidations(sheet string) ([]*DataValidation, error) {
+	ws, err := f.workSheetReader(sheet)
+	if err != nil {
+		return nil, err
+	}
+	if ws.DataValidations == nil {
+		return nil, err
+	}
+	return ws.DataValidations.DataValidation, err
+}
+
 // DeleteDataValidation delete data validation by given worksheet name and
 // reference sequence.
 func (f *File) DeleteDataValidation(sheet, sqref string) error {
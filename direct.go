@@ -1,12 +1,26 @@
 package excelize
 
 import (
+	"bufio"
 	"bytes"
+	"context"
+	"encoding/xml"
 	"errors"
 	"fmt"
+	"image"
 	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
 	"strconv"
+	"strings"
 	"sync"
+	"syscall"
+	"time"
+	"unicode/utf8"
 )
 
 // DirectWriter is a simpler and optimized version of the StreamWriter. Its primary use is sending large amount of sheet data row by row directly
@@ -21,12 +35,99 @@ type DirectWriter struct {
 	sheetPath     string
 	maxBufferSize int
 	bytesWritten  int64
+	maxBytes      int64
 	buf           []byte
 	out           io.Writer
+	bufOut        *bufio.Writer
+	writeErr      error
+	outBufSize    int
+	ctx           context.Context
 	done          chan bool
+	doneOnce      sync.Once
 	rowCount      int
+	rowOffset     int
 	maxColLengths []int
 	waitMode      bool
+	mergeCells    string
+	mergeRects    [][]int
+	inlineStrings bool
+	emitSpans     bool
+	rawFormulas   bool
+	columnStats   []ColumnStat
+	statsEnabled  bool
+	rowKinds      []cellKind
+	prevColLens   [][2]int
+	longStrings   LongStringMode
+	controlChars  ControlCharMode
+	sharedStrings bool
+	autoDateStyle int
+	defaultStyle  int
+	autoFilter    *directAutoFilter
+	closed        bool
+	dimensionSet  bool
+	sheetState    string
+	printArea     string
+	filterRef     string
+	rotatedBytes  int64
+	rootAttrs     string
+	preserveSheet bool
+	comments      []Comment
+	commentID     int
+	pictures      []directPicture
+	hyperlinks    []directHyperlink
+	outlineLevel  uint8
+	flushStop     chan struct{}
+	onFlush       func(chunk []byte, totalWritten int64)
+	flushDone     chan struct{}
+	asyncDepth    int
+	asyncChunks   chan []byte
+	asyncFree     chan []byte
+	asyncDone     chan struct{}
+	asyncPending  int
+	asyncCond     *sync.Cond
+	queueMu       sync.Mutex
+}
+
+// maxPooledBufferSize is the capacity of the largest write buffer which is returned to the pool, so that the pool
+// doesn't retain the buffers of the writers in wait mode.
+const maxPooledBufferSize = 4 << 20
+
+// directWriterBufPool is the pool of the write buffers of the direct writers, a buffer is returned to the pool when
+// the writer is closed, if all data has been flushed to the underlying writer.
+var directWriterBufPool = new(sync.Pool)
+
+// getDirectWriterBuf returns an empty write buffer from the pool, or nil if the pool is empty.
+func getDirectWriterBuf() []byte {
+	if buf, ok := directWriterBufPool.Get().(*[]byte); ok {
+		return (*buf)[:0]
+	}
+	return nil
+}
+
+// releaseBuf returns the write buffer to the pool once all data has been flushed. Since an io.Writer must not retain
+// the written bytes, including the compressed entries of File.WriteTo, the buffer is no longer referenced then.
+// Otherwise the buffer is kept, as the buffered data is written by WriteTo after Close.
+func (dw *DirectWriter) releaseBuf() {
+	dw.Lock()
+	defer dw.Unlock()
+	if dw.out == nil || len(dw.buf) > 0 || cap(dw.buf) == 0 || cap(dw.buf) > maxPooledBufferSize {
+		return
+	}
+	buf := dw.buf[:0]
+	dw.buf = nil
+	directWriterBufPool.Put(&buf)
+}
+
+// directPicture is a picture of a cell added by the DirectWriter, which is placed when the writer is closed.
+type directPicture struct {
+	cell, format, name, ext string
+	file                    []byte
+}
+
+// directHyperlink is the hyperlink of a cell added by the DirectWriter, which is set when the writer is closed.
+type directHyperlink struct {
+	cell string
+	link CellHyperlink
 }
 
 // NewDirectWriter return a new DirectWriter for the given sheet name. If the sheet doesn't yet exists it is created.
@@ -43,155 +144,2749 @@ type DirectWriter struct {
 //
 // - wait for the goroutine to return
 func (f *File) NewDirectWriter(sheet string, maxBufferSize int) (*DirectWriter, error) {
+	return f.NewDirectWriterContext(context.Background(), sheet, maxBufferSize)
+}
+
+// NewDirectWriterContext is like NewDirectWriter but with a context. When the context is done, AddRow and WriteTo
+// return the context error, and WriteTo no longer blocks waiting for the DirectWriter to be closed.
+func (f *File) NewDirectWriterContext(ctx context.Context, sheet string, maxBufferSize int) (*DirectWriter, error) {
 	_ = f.NewSheet(sheet)
 	sheetID := f.getSheetID(sheet)
 	if sheetID == -1 {
 		return nil, errors.New("bug: sheetID not found after call to NewSheet")
 	}
-	dw := &DirectWriter{
-		File:          f,
-		Sheet:         sheet,
-		SheetID:       sheetID,
-		maxBufferSize: maxBufferSize,
-		done:          make(chan bool),
+	dw := &DirectWriter{
+		File:          f,
+		Sheet:         sheet,
+		SheetID:       sheetID,
+		maxBufferSize: maxBufferSize,
+		buf:           getDirectWriterBuf(),
+		outBufSize:    defaultOutputBufferSize,
+		ctx:           ctx,
+		done:          make(chan bool),
+	}
+	var err error
+	dw.worksheet, err = f.workSheetReader(sheet)
+	if err != nil {
+		return nil, err
+	}
+
+	dw.sheetPath = f.sheetMap[trimSheetName(sheet)]
+	f.directWriters = append(f.directWriters, dw)
+
+	return dw, err
+}
+
+// NewDirectWriterByIndex is like NewDirectWriter but for the existing sheet of the given index, as returned by
+// GetSheetName, so that a sheet of a template can be written without knowing its possibly localized name. It returns
+// ErrSheetIdx if there is no sheet with the given index.
+func (f *File) NewDirectWriterByIndex(index int, maxBufferSize int) (*DirectWriter, error) {
+	sheet := f.GetSheetName(index)
+	if sheet == "" {
+		return nil, ErrSheetIdx
+	}
+	return f.NewDirectWriter(sheet, maxBufferSize)
+}
+
+// removeDirectWriter unregisters the given direct writer, which is not yet attached to the writer of File.WriteTo, so
+// that its worksheet is written like the other worksheets. The File must be locked by the caller, and File.WriteTo
+// must not be running, since it looks the direct writers up by index.
+func (f *File) removeDirectWriter(dw *DirectWriter) {
+	for i, w := range f.directWriters {
+		if w == dw {
+			f.directWriters = append(f.directWriters[:i], f.directWriters[i+1:]...)
+			return
+		}
+	}
+}
+
+// AddRawWorksheet provides a function to add the given complete worksheet XML, such as returned by
+// DirectWriter.Finalize, as the worksheet of the given name, so that a workbook can be assembled from worksheets
+// generated separately, such as on different machines. The worksheet is created if it doesn't yet exist, otherwise its
+// content is replaced. The worksheet XML is stored as is, so it must not refer to relationships, such as hyperlinks,
+// comments or pictures, and the styles it refers to must exist in the workbook. It returns ErrParameterInvalid if the
+// root element of the XML is not a worksheet.
+func (f *File) AddRawWorksheet(name string, xml []byte) error {
+	if !isWorksheetXML(xml) {
+		return ErrParameterInvalid
+	}
+	f.NewSheet(name)
+	f.Lock()
+	defer f.Unlock()
+	sheetPath := f.sheetMap[trimSheetName(name)]
+	for _, dw := range f.directWriters {
+		if dw.sheetPath == sheetPath {
+			return ErrDirectWriterSheet
+		}
+	}
+	f.Sheet.Delete(sheetPath)
+	delete(f.checked, sheetPath)
+	f.Pkg.Store(sheetPath, xml)
+	return nil
+}
+
+// isWorksheetXML returns if the root element of the given XML is a worksheet.
+func isWorksheetXML(b []byte) bool {
+	decoder := xml.NewDecoder(bytes.NewReader(b))
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return false
+		}
+		if start, ok := token.(xml.StartElement); ok {
+			return start.Name.Local == "worksheet" && start.Name.Space == NameSpaceSpreadSheet.Value
+		}
+	}
+}
+
+// directWriterPath returns the worksheet path of the direct writer of the given index, which doesn't change when
+// the writer moves on to the next sheet, and false if there is no such direct writer.
+func (f *File) directWriterPath(i int) (string, bool) {
+	f.Lock()
+	defer f.Unlock()
+	if i >= len(f.directWriters) {
+		return "", false
+	}
+	return f.directWriters[i].sheetPath, true
+}
+
+// writeDirectWriter writes the output of the direct writer of the given index to w, like DirectWriter.WriteTo. The
+// writer is looked up and attached to w at once, so that it can't move on to the next sheet in between.
+func (f *File) writeDirectWriter(i int, w io.Writer) error {
+	f.Lock()
+	dw := f.directWriters[i]
+	if err := dw.ctx.Err(); err != nil {
+		f.Unlock()
+		dw.closeDone()
+		return err
+	}
+	select {
+	case <-dw.done:
+		// a closed writer is not modified anymore
+		f.Unlock()
+		_, err := dw.WriteTo(w)
+		return err
+	default:
+	}
+	dw.Lock()
+	dw.attach(w)
+	done, ctx := dw.done, dw.ctx
+	dw.Unlock()
+	f.Unlock()
+	select {
+	case <-done:
+		// the writer may have moved on to the next sheet, the error of the
+		// worksheet is kept by the closed writer of the given index
+		f.Lock()
+		dw = f.directWriters[i]
+		f.Unlock()
+		dw.RLock()
+		defer dw.RUnlock()
+		return dw.writeErr
+	case <-ctx.Done():
+		dw.closeDone()
+		return ctx.Err()
+	}
+}
+
+// isDirectWriter reports whether the given DirectWriter is registered by the File.
+func (f *File) isDirectWriter(dw *DirectWriter) bool {
+	f.Lock()
+	defer f.Unlock()
+	for _, w := range f.directWriters {
+		if w == dw {
+			return true
+		}
+	}
+	return false
+}
+
+// hasDirectWriter reports whether the worksheet of the given path is written by a DirectWriter.
+func (f *File) hasDirectWriter(path string) bool {
+	for _, dw := range f.directWriters {
+		if dw.sheetPath == path {
+			return true
+		}
+	}
+	return false
+}
+
+// SetWait enables or disables the wait mode. In wait mode nothing is flushed to writer (if any), even if the buffer grows beyond maxBufferSize.
+func (dw *DirectWriter) SetWait(b bool) error {
+	if dw.closed {
+		return ErrDirectWriterClosed
+	}
+	dw.Lock()
+	defer dw.Unlock()
+	if b {
+		if dw.bytesWritten > 0 {
+			return errors.New("Can't enable wait mode since first data already written.")
+		}
+		dw.waitMode = true
+		return nil
+	}
+	dw.waitMode = false
+	return nil
+}
+
+// SetOutputBuffer sets the size of the output buffer of the DirectWriter, which is 64 KiB by default. When the writer
+// given to WriteTo is backed by a file descriptor, such as an *os.File or a net.Conn, it is wrapped in a bufio.Writer
+// of the given size, so that the flushed chunks of the write buffer are written with fewer system calls, and chunks
+// larger than the output buffer are passed through without copying. The output buffer is flushed by Flush, by the
+// background flushing of SetFlushInterval and by Close. A size of 0 disables the output buffer, for callers which
+// already buffer the writer. Since the writer is wrapped when it is registered, it must be called before WriteTo.
+func (dw *DirectWriter) SetOutputBuffer(size int) error {
+	if dw.closed {
+		return ErrDirectWriterClosed
+	}
+	if size < 0 {
+		return ErrParameterInvalid
+	}
+	dw.Lock()
+	defer dw.Unlock()
+	if dw.out != nil {
+		return errors.New("Can't set output buffer since the writer is already registered.")
+	}
+	dw.outBufSize = size
+	return nil
+}
+
+// defaultOutputBufferSize is the default size of the output buffer of the writers backed by a file descriptor.
+const defaultOutputBufferSize = 64 << 10
+
+// SetAsyncFlush enables or disables the asynchronous flushing. When enabled with a depth greater than 0, the flushed
+// chunks of the write buffer are queued for a dedicated goroutine which writes them to the writer of WriteTo, so that
+// AddRow isn't blocked by a slow writer, such as an io.PipeWriter with a slow reader, until depth chunks are waiting
+// in the queue. The chunks are written in order, and the errors of the writer are returned by the next flush of
+// AddRow, Flush or Close. Flush and Close wait until the queued chunks are written. While the chunks are queued, the
+// bytes flushed returned by Stats include the queued bytes. Since the goroutine is started when the writer is
+// registered, it must be called before WriteTo.
+func (dw *DirectWriter) SetAsyncFlush(depth int) error {
+	if dw.closed {
+		return ErrDirectWriterClosed
+	}
+	if depth < 0 {
+		return ErrParameterInvalid
+	}
+	dw.Lock()
+	defer dw.Unlock()
+	if dw.out != nil {
+		return errors.New("Can't set async flush since the writer is already registered.")
+	}
+	dw.asyncDepth = depth
+	return nil
+}
+
+// attach registers w as the underlying writer, wrapped in the output buffer if it is backed by a file descriptor, and
+// starts the goroutine of the asynchronous flushing if enabled. The caller must hold the lock.
+func (dw *DirectWriter) attach(w io.Writer) {
+	dw.out, dw.bufOut = w, nil
+	if _, ok := w.(syscall.Conn); ok && dw.outBufSize > 0 {
+		dw.bufOut = bufio.NewWriterSize(w, dw.outBufSize)
+		dw.out = dw.bufOut
+	}
+	if dw.asyncDepth > 0 {
+		if dw.asyncCond == nil {
+			dw.asyncCond = sync.NewCond(&dw.RWMutex)
+		}
+		dw.asyncChunks, dw.asyncFree = make(chan []byte, dw.asyncDepth), make(chan []byte, dw.asyncDepth+1)
+		dw.asyncDone = make(chan struct{})
+		go dw.writeChunks(dw.out, dw.bufOut, dw.asyncChunks, dw.asyncFree, dw.asyncDone)
+	}
+}
+
+// writeChunks writes the queued chunks to the given writer until the queue is closed, and flushes the output buffer,
+// if any, whenever the queue is empty. Once the writer fails, the remaining chunks are discarded, and the bytes which
+// are not written are subtracted from the bytes written. The chunks of the size of the write buffer are handed back
+// to be reused as write buffer.
+func (dw *DirectWriter) writeChunks(out io.Writer, bufOut *bufio.Writer, chunks <-chan []byte, free chan<- []byte, done chan<- struct{}) {
+	defer close(done)
+	var written int64
+	var failed bool
+	for chunk := range chunks {
+		var n int
+		var err error
+		if !failed {
+			if n, err = out.Write(chunk); err == nil && bufOut != nil && len(chunks) == 0 {
+				err = bufOut.Flush()
+			}
+		}
+		dw.Lock()
+		if failed || err != nil {
+			failed = true
+			_ = dw.setWriteErr(err)
+			dw.bytesWritten -= int64(len(chunk) - n)
+		} else if written += int64(n); dw.onFlush != nil && n > 0 {
+			dw.onFlush(chunk, written)
+		}
+		dw.asyncPending--
+		dw.asyncCond.Broadcast()
+		dw.Unlock()
+		if cap(chunk) >= dw.maxBufferSize {
+			select {
+			case free <- chunk[:0]:
+			default:
+			}
+		}
+	}
+}
+
+// queueFlush queues the header, if not flushed yet, and the write buffer for the goroutine of the asynchronous
+// flushing, and blocks while the queue is full. The write buffer is replaced by a reused chunk or a new buffer. The
+// queuing is serialized, so that the chunks are queued in order.
+func (dw *DirectWriter) queueFlush() error {
+	dw.queueMu.Lock()
+	defer dw.queueMu.Unlock()
+	dw.Lock()
+	if dw.writeErr != nil || dw.asyncChunks == nil {
+		defer dw.Unlock()
+		return dw.writeErr
+	}
+	var chunks [][]byte
+	if dw.bytesWritten == 0 {
+		chunks = append(chunks, dw.buildHeader())
+	}
+	if len(dw.buf) > 0 {
+		chunks = append(chunks, dw.buf)
+		select {
+		case buf := <-dw.asyncFree:
+			dw.buf = buf
+		default:
+			dw.buf = make([]byte, 0, cap(dw.buf))
+		}
+	}
+	for _, chunk := range chunks {
+		dw.bytesWritten += int64(len(chunk))
+	}
+	dw.asyncPending += len(chunks)
+	queue := dw.asyncChunks
+	dw.Unlock()
+	for _, chunk := range chunks {
+		queue <- chunk
+	}
+	return nil
+}
+
+// waitFlushed waits until the queued chunks of the asynchronous flushing are written, and returns the error of the
+// writer, if any.
+func (dw *DirectWriter) waitFlushed() error {
+	dw.Lock()
+	defer dw.Unlock()
+	if dw.asyncCond == nil {
+		return nil
+	}
+	for dw.asyncPending > 0 {
+		dw.asyncCond.Wait()
+	}
+	return dw.writeErr
+}
+
+// stopAsyncFlush closes the queue of the asynchronous flushing, if any, and waits for the queued chunks to be
+// written, and returns the error of the writer, if any.
+func (dw *DirectWriter) stopAsyncFlush() error {
+	dw.Lock()
+	queue, done := dw.asyncChunks, dw.asyncDone
+	dw.asyncChunks, dw.asyncFree, dw.asyncDone = nil, nil, nil
+	dw.Unlock()
+	if queue == nil {
+		return nil
+	}
+	// the queuing of a concurrent flush is completed first
+	dw.queueMu.Lock()
+	close(queue)
+	dw.queueMu.Unlock()
+	<-done
+	dw.RLock()
+	defer dw.RUnlock()
+	return dw.writeErr
+}
+
+// flushOut writes the data of the output buffer, if any, to the underlying writer. The output buffer of the
+// asynchronous flushing is flushed by its goroutine.
+func (dw *DirectWriter) flushOut() error {
+	dw.Lock()
+	defer dw.Unlock()
+	if dw.bufOut == nil || dw.asyncChunks != nil {
+		return nil
+	}
+	return dw.setWriteErr(dw.bufOut.Flush())
+}
+
+// setWriteErr records the first error of the underlying writer, which is returned by File.WriteTo for the worksheet
+// of the DirectWriter, and returns the given error. The caller must hold the lock.
+func (dw *DirectWriter) setWriteErr(err error) error {
+	if err != nil && dw.writeErr == nil {
+		dw.writeErr = err
+	}
+	return err
+}
+
+// SetFlushInterval starts flushing the buffered rows to the writer registered by WriteTo in the background every
+// interval, even if the buffer doesn't grow beyond maxBufferSize, so that the rows of a slow producer reach the
+// writer in time. Nothing is flushed in wait mode. A zero or negative interval stops the background flushing, which is
+// also stopped by Close. Since the header may be flushed at any time once rows are added, the properties written
+// before the sheet data must be set before the first row. The errors of background flushes are returned by the next
+// flush of AddRow, Flush or Close.
+func (dw *DirectWriter) SetFlushInterval(d time.Duration) error {
+	if dw.closed {
+		return ErrDirectWriterClosed
+	}
+	dw.stopFlushInterval()
+	if d <= 0 {
+		return nil
+	}
+	dw.flushStop, dw.flushDone = make(chan struct{}), make(chan struct{})
+	go dw.flushEvery(d, dw.flushStop, dw.flushDone)
+	return nil
+}
+
+// flushEvery flushes the buffered rows every interval until stop is closed or the DirectWriter is done.
+func (dw *DirectWriter) flushEvery(d time.Duration, stop, done chan struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(d)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-dw.done:
+			return
+		case <-dw.ctx.Done():
+			return
+		case <-ticker.C:
+			dw.RLock()
+			pending := len(dw.buf) > 0 && !dw.waitMode
+			dw.RUnlock()
+			if pending && dw.tryFlush() == nil {
+				_ = dw.flushOut()
+			}
+		}
+	}
+}
+
+// stopFlushInterval stops the background flushing, if any, and waits for it to return.
+func (dw *DirectWriter) stopFlushInterval() {
+	if dw.flushStop == nil {
+		return
+	}
+	close(dw.flushStop)
+	<-dw.flushDone
+	dw.flushStop, dw.flushDone = nil, nil
+}
+
+// OnFlush registers a callback which is invoked after each successful write of the DirectWriter to the underlying
+// writer, with the written chunk and the total number of bytes written for the worksheet including the chunk, so that
+// the output can be observed chunk by chunk, such as for a resumable upload. The concatenation of the chunks is the
+// output of the worksheet. The chunk is a view of the internal buffer which is only valid during the call and must
+// not be modified. The callback runs under the lock of the DirectWriter, so it must not call the DirectWriter and
+// must not block long. A nil callback removes it.
+func (dw *DirectWriter) OnFlush(fn func(chunk []byte, totalWritten int64)) {
+	dw.Lock()
+	dw.onFlush = fn
+	dw.Unlock()
+}
+
+// SetAutoDateStyle sets the built-in date or time number format, such as 14 (mm-dd-yy) or 22 (m/d/yy hh:mm), which
+// is applied to the cells of time.Time values without StyleID and NumFmt, so that the dates are not displayed as
+// serial numbers. It is disabled by default, and a zero number format disables it. The style is created when it is
+// set, and the style of the row isn't taken into account.
+func (dw *DirectWriter) SetAutoDateStyle(numFmt int) error {
+	if dw.closed {
+		return ErrDirectWriterClosed
+	}
+	if numFmt == 0 {
+		dw.autoDateStyle = 0
+		return nil
+	}
+	if !(numFmt >= 14 && numFmt <= 22) && !(numFmt >= 27 && numFmt <= 36) &&
+		!(numFmt >= 45 && numFmt <= 47) && !(numFmt >= 50 && numFmt <= 58) {
+		return ErrParameterInvalid
+	}
+	styleID, err := dw.File.NewStyle(&Style{NumFmt: numFmt})
+	if err != nil {
+		return err
+	}
+	dw.autoDateStyle = styleID
+	return nil
+}
+
+// SetDefaultCellStyle sets the style which is applied to the cells without StyleID and NumFmt added by AddRow and
+// AddNumericRow, so that the cells of a uniformly formatted export don't need to repeat the style. The cells of
+// time.Time values get the style of SetAutoDateStyle instead, if any. A zero style ID disables it.
+func (dw *DirectWriter) SetDefaultCellStyle(styleID int) error {
+	if dw.closed {
+		return ErrDirectWriterClosed
+	}
+	if styleID < 0 {
+		return newInvalidStyleID(styleID)
+	}
+	dw.Lock()
+	defer dw.Unlock()
+	dw.defaultStyle = styleID
+	return nil
+}
+
+// SetStartRow sets the number of the first row added by AddRow and AddNumericRow, so that a resumed export which
+// restarts after a failure continues the numbering of the rows where the previous attempt left off. It must be called
+// before the first row is added.
+func (dw *DirectWriter) SetStartRow(n int) error {
+	if dw.closed {
+		return ErrDirectWriterClosed
+	}
+	if n < 1 || n > TotalRows {
+		return newInvalidRowNumberError(n)
+	}
+	dw.Lock()
+	defer dw.Unlock()
+	if dw.rowCount > dw.rowOffset || dw.bytesWritten > 0 {
+		return errors.New("Can't set start row since rows already added.")
+	}
+	dw.rowCount, dw.rowOffset = n-1, n-1
+	return nil
+}
+
+// SetInlineStrings enables or disables the inline strings mode. In inline strings mode string values are written as
+// inline rich strings (t="inlineStr") instead of formula strings (t="str"), for compatibility with importers which
+// don't support the latter.
+func (dw *DirectWriter) SetInlineStrings(b bool) {
+	dw.inlineStrings = b
+}
+
+// SetMaxBytes caps the size of the worksheet at the given number of bytes, so that an export of untrusted data can't
+// grow without bounds. Once the bytes flushed to the underlying writer plus the bytes in the write buffer would exceed
+// the limit, the row is discarded and ErrSheetTooLarge is returned by AddRow, AddRowAt, AddRows, AddNumericRow and
+// AddRawRow, while the rows before are kept, so that the writer can still be closed to a valid worksheet. The end of
+// the worksheet written by Close isn't counted. A zero or negative limit removes the cap.
+func (dw *DirectWriter) SetMaxBytes(limit int64) {
+	dw.Lock()
+	defer dw.Unlock()
+	dw.maxBytes = limit
+}
+
+// checkMaxBytes discards the row appended to the write buffer at the given buffer length, row count, number of columns
+// and number of hyperlinks and returns ErrSheetTooLarge, if the worksheet exceeds the maximum size. The caller must
+// hold the lock.
+func (dw *DirectWriter) checkMaxBytes(n, rowCount, cols, hyperlinks int) error {
+	if dw.maxBytes <= 0 || dw.bytesWritten+int64(len(dw.buf)) <= dw.maxBytes {
+		return nil
+	}
+	dw.buf, dw.rowCount, dw.hyperlinks = dw.buf[:n], rowCount, dw.hyperlinks[:hyperlinks]
+	for i := len(dw.prevColLens) - 1; i >= 0; i-- {
+		if col := dw.prevColLens[i][0]; col < cols {
+			dw.maxColLengths[col] = dw.prevColLens[i][1]
+		}
+	}
+	if cols < len(dw.maxColLengths) {
+		dw.maxColLengths = dw.maxColLengths[:cols]
+	}
+	return ErrSheetTooLarge
+}
+
+// raiseColLength raises the maximum length of the column of the given index to the given length, and records the
+// previous length, so that checkMaxBytes can restore it if the row is discarded. The caller must hold the lock.
+func (dw *DirectWriter) raiseColLength(col, l int) {
+	if l > dw.maxColLengths[col] {
+		dw.prevColLens = append(dw.prevColLens, [2]int{col, dw.maxColLengths[col]})
+		dw.maxColLengths[col] = l
+	}
+}
+
+// ColumnStat directly maps the number of cells of each type of a column written by the DirectWriter, see
+// EnableColumnStats. The types are inferred from the values like AddRow does, and the formula cells are counted by the
+// type of their cached result.
+type ColumnStat struct {
+	Numbers int
+	Strings int
+	Dates   int
+	Bools   int
+	Errors  int
+	Empty   int
+}
+
+// cellKind is the type of a cell counted by the column statistics of the DirectWriter.
+type cellKind byte
+
+// The cell kinds of the column statistics.
+const (
+	cellKindEmpty cellKind = iota
+	cellKindNumber
+	cellKindString
+	cellKindDate
+	cellKindBool
+	cellKindError
+)
+
+// inferCellKind returns the kind of the given encoded cell of the given value.
+func inferCellKind(c xlsxC, value interface{}) cellKind {
+	switch c.T {
+	case "":
+		if c.V == "" {
+			return cellKindEmpty
+		}
+		if _, ok := value.(time.Time); ok {
+			return cellKindDate
+		}
+		return cellKindNumber
+	case "b":
+		return cellKindBool
+	case "e":
+		return cellKindError
+	}
+	if c.V == "" && c.IS == nil {
+		return cellKindEmpty
+	}
+	return cellKindString
+}
+
+// EnableColumnStats enables the column statistics of the DirectWriter, which count the cells of each type per column
+// as the rows are added, for data profiling without a separate pass over the data. The cells added before it is
+// called and the rows added by AddRawRow are not counted.
+func (dw *DirectWriter) EnableColumnStats() {
+	dw.Lock()
+	defer dw.Unlock()
+	dw.statsEnabled = true
+}
+
+// ColumnStats returns a copy of the column statistics of the rows added so far, one per column, see EnableColumnStats.
+// It is safe to be called from another goroutine while rows are being added.
+func (dw *DirectWriter) ColumnStats() []ColumnStat {
+	dw.RLock()
+	defer dw.RUnlock()
+	return append([]ColumnStat(nil), dw.columnStats...)
+}
+
+// addColumnStats counts the cells of the given kinds of a row added to the write buffer, the caller must hold the lock.
+func (dw *DirectWriter) addColumnStats(kinds []cellKind) {
+	if !dw.statsEnabled {
+		return
+	}
+	for len(dw.columnStats) < len(kinds) {
+		dw.columnStats = append(dw.columnStats, ColumnStat{})
+	}
+	for i, kind := range kinds {
+		stat := &dw.columnStats[i]
+		switch kind {
+		case cellKindNumber:
+			stat.Numbers++
+		case cellKindString:
+			stat.Strings++
+		case cellKindDate:
+			stat.Dates++
+		case cellKindBool:
+			stat.Bools++
+		case cellKindError:
+			stat.Errors++
+		default:
+			stat.Empty++
+		}
+	}
+}
+
+// SetEmitSpans enables or disables the spans attribute of the rows. When enabled, each row with cells is written with
+// the spans attribute "1:N", where N is the number of cells of the row, as Excel does, so that readers can pre-size the
+// cells of the row. The rows added by AddRawRow are written as is.
+func (dw *DirectWriter) SetEmitSpans(b bool) {
+	dw.Lock()
+	defer dw.Unlock()
+	dw.emitSpans = b
+}
+
+// SetRawFormulas enables or disables the raw formulas mode. By default the formulas of the cells are escaped, like the
+// values. In raw formulas mode they are written verbatim, for formulas which are already escaped for XML, such as
+// formulas taken from captured worksheet XML, and would be corrupted by escaping them twice. The caller is responsible
+// for the formulas being valid XML character data.
+func (dw *DirectWriter) SetRawFormulas(b bool) {
+	dw.Lock()
+	defer dw.Unlock()
+	dw.rawFormulas = b
+}
+
+// LongStringMode defined the handling of the string values which exceed the maximum number of characters of a cell,
+// TotalCellChars, by the DirectWriter.
+type LongStringMode byte
+
+// The long string modes of the DirectWriter.
+const (
+	// LongStringTruncate truncates the string values to TotalCellChars characters, as File.SetCellValue does.
+	LongStringTruncate LongStringMode = iota
+	// LongStringError rejects the rows with a string value which exceeds TotalCellChars characters with
+	// ErrCellCharsLength.
+	LongStringError
+)
+
+// SetLongStringMode sets the handling of the string values exceeding TotalCellChars characters, which are truncated by
+// default, since Excel can't open a cell with a longer value.
+func (dw *DirectWriter) SetLongStringMode(mode LongStringMode) error {
+	if mode != LongStringTruncate && mode != LongStringError {
+		return ErrParameterInvalid
+	}
+	dw.longStrings = mode
+	return nil
+}
+
+// ControlCharMode defined the handling of the characters which are not allowed in XML, such as the control characters
+// below 0x20 other than tab, line feed and carriage return, in the values written by the DirectWriter.
+type ControlCharMode byte
+
+// The control character modes of the DirectWriter.
+const (
+	// ControlCharReplace replaces the characters which are not allowed in XML with the Unicode replacement character
+	// U+FFFD, as encoding/xml does.
+	ControlCharReplace ControlCharMode = iota
+	// ControlCharStrip removes the characters which are not allowed in XML.
+	ControlCharStrip
+	// ControlCharEscape writes the control characters as _xHHHH_ escapes, such as _x0000_ for NUL, which are decoded
+	// by the applications, and replaces the other characters which are not allowed in XML like ControlCharReplace.
+	ControlCharEscape
+)
+
+// SetControlCharMode sets the handling of the characters which are not allowed in XML, such as NUL, in the values of
+// the cells written by the writer, which are replaced by U+FFFD by default, since a file with such a character can't be
+// opened. The strings of the shared string table, see SetSharedStrings, are always replaced.
+func (dw *DirectWriter) SetControlCharMode(mode ControlCharMode) error {
+	if mode != ControlCharReplace && mode != ControlCharStrip && mode != ControlCharEscape {
+		return ErrParameterInvalid
+	}
+	dw.controlChars = mode
+	return nil
+}
+
+// longStringValues returns the given values with the string values exceeding TotalCellChars characters truncated, and
+// whether a value is truncated, or ErrCellCharsLength in the LongStringError mode. The values are copied only if a
+// value is truncated.
+func (dw *DirectWriter) longStringValues(values []Cell) ([]Cell, bool, error) {
+	var truncated bool
+	for i, c := range values {
+		var chars int
+		switch v := c.Value.(type) {
+		case string:
+			if len(v) <= TotalCellChars {
+				continue
+			}
+			chars = utf8.RuneCountInString(v)
+		case []byte:
+			if len(v) <= TotalCellChars {
+				continue
+			}
+			chars = utf8.RuneCount(v)
+		default:
+			continue
+		}
+		if chars <= TotalCellChars {
+			continue
+		}
+		if dw.longStrings == LongStringError {
+			return values, truncated, ErrCellCharsLength
+		}
+		if !truncated {
+			values, truncated = append([]Cell(nil), values...), true
+		}
+		switch v := c.Value.(type) {
+		case string:
+			values[i].Value = truncateCellChars(v)
+		case []byte:
+			values[i].Value = truncateCellChars(string(v))
+		}
+	}
+	return values, truncated, nil
+}
+
+// longStringRows is like longStringValues for the given rows, and returns the rows before the first row with an error
+// with the error.
+func (dw *DirectWriter) longStringRows(rows [][]Cell) ([][]Cell, error) {
+	checked := rows
+	for i, values := range rows {
+		values, truncated, err := dw.longStringValues(values)
+		if err != nil {
+			return checked[:i], err
+		}
+		if truncated {
+			if &checked[0] == &rows[0] {
+				checked = append([][]Cell(nil), rows...)
+			}
+			checked[i] = values
+		}
+	}
+	return checked, nil
+}
+
+// SetSharedStrings enables or disables the shared strings mode. In shared strings mode string values are added to the
+// shared string table of the workbook and written as indexes into it (t="s"), so that repeated strings are stored only
+// once, for consumers which require shared strings. The shared string table is shared by the direct writers of the
+// workbook, and written after all of them are closed. The string values of formula cells are not shared. The size of
+// the shared string table can be bounded by the SharedStringCacheSize option, the strings which don't fit are written
+// as inline strings.
+func (dw *DirectWriter) SetSharedStrings(b bool) {
+	if b {
+		dw.File.sharedStringsReader()
+	}
+	dw.sharedStrings = b
+}
+
+// sharedStringIndex is the index of a string value in the shared string table, which is written as a shared string
+// cell by the DirectWriter.
+type sharedStringIndex int
+
+// sharedStringOverflow is a string value which doesn't fit in the shared string table bounded by the
+// SharedStringCacheSize option, which is written as an inline string cell by the DirectWriter.
+type sharedStringOverflow string
+
+// sharedStringValues returns a copy of the given values with the string values replaced by their indexes in the shared
+// string table, in shared strings mode. The shared string table is guarded by the lock of the File, so the strings must
+// be added before the lock of the DirectWriter is taken.
+func (dw *DirectWriter) sharedStringValues(values []Cell) []Cell {
+	if !dw.sharedStrings {
+		return values
+	}
+	var limit int
+	if dw.File.options != nil {
+		limit = dw.File.options.SharedStringCacheSize
+	}
+	shared := make([]Cell, len(values))
+	for i, c := range values {
+		if c.Formula == "" && c.RawValue == nil {
+			var s string
+			switch v := c.Hyperlink.value(c).(type) {
+			case string:
+				s = v
+			case []byte:
+				s = string(v)
+			default:
+				shared[i] = c
+				continue
+			}
+			if idx, ok := dw.File.addSharedString(s, limit); ok {
+				c.Value = sharedStringIndex(idx)
+			} else {
+				c.Value = sharedStringOverflow(s)
+			}
+		}
+		shared[i] = c
+	}
+	return shared
+}
+
+// SetPreserveSheet enables or disables the preserve mode, for streaming into an existing, already formatted worksheet.
+// The sheet properties (sheetPr), the sheet views, the sheet format properties and the elements after the sheet data,
+// such as conditional formats and page setup, of an existing worksheet are always kept. In preserve mode the existing
+// column definitions and merged cells are kept as well, and the columns and merged cells of the DirectWriter are
+// added after them, otherwise they are replaced. The existing rows are always replaced by the rows of the
+// DirectWriter. Since the columns need to be written before sheet data, it must be called before the first data is
+// flushed.
+func (dw *DirectWriter) SetPreserveSheet(b bool) error {
+	if dw.closed {
+		return ErrDirectWriterClosed
+	}
+	dw.Lock()
+	defer dw.Unlock()
+	if dw.bytesWritten > 0 {
+		return errors.New("Can't set preserve mode since first data already written.")
+	}
+	dw.preserveSheet = b
+	return nil
+}
+
+// AddRow is used for streaming a large data file row by row, without any gaps.
+// It omits  cell reference values and only accept []Cell to reduce interface{} related allocations.
+// The optional RowOpts set the height, visibility, style and outline level of the row. The maximum outline level of
+// the rows is declared in the sheet format properties when the writer is closed, if no data is flushed yet.
+// It returns the number of bytes currently in the write buffer.
+func (dw *DirectWriter) AddRow(values []Cell, opts ...RowOpts) (buffered int, err error) {
+	return dw.addRow(0, values, opts)
+}
+
+// AddRowAt is like AddRow, but writes the row at the given row number, so that empty rows can be skipped in sparse
+// data. Since the rows are streamed in order, the row number must be greater than the number of the last row added,
+// otherwise ErrDirectWriterRowOrder is returned. The next rows added by AddRow continue after the given row.
+func (dw *DirectWriter) AddRowAt(row int, values []Cell, opts ...RowOpts) (buffered int, err error) {
+	if row < 1 {
+		return len(dw.buf), newInvalidRowNumberError(row)
+	}
+	return dw.addRow(row, values, opts)
+}
+
+// addRow adds a row of the given values at the given row number, or after the last row if the row number is 0, and
+// flushes the write buffer if it exceeds maxBufferSize.
+func (dw *DirectWriter) addRow(row int, values []Cell, opts []RowOpts) (buffered int, err error) {
+	if dw.closed {
+		return len(dw.buf), ErrDirectWriterClosed
+	}
+	if err = dw.ctx.Err(); err != nil {
+		dw.closeDone()
+		return len(dw.buf), err
+	}
+	var attrs string
+	if len(opts) > 0 {
+		if attrs, err = marshalRowAttrs(opts...); err != nil {
+			return len(dw.buf), err
+		}
+		dw.setOutlineLevel(opts[len(opts)-1].OutlineLevel)
+	}
+	if values, _, err = dw.longStringValues(values); err != nil {
+		return len(dw.buf), err
+	}
+	if row == 0 {
+		attrs = dw.filterRowAttrs(dw.rowCount+1, attrs, cellValue(values))
+	} else {
+		attrs = dw.filterRowAttrs(row, attrs, cellValue(values))
+	}
+	values = dw.sharedStringValues(values)
+	dw.Lock()
+	err = dw.appendRow(row, values, attrs)
+	buffered = len(dw.buf)
+	dw.Unlock()
+	if err != nil {
+		return buffered, err
+	}
+	if buffered > dw.maxBufferSize && !dw.waitMode {
+		err = dw.tryFlush()
+		return len(dw.buf), err
+	}
+	return buffered, nil
+}
+
+// AddRows is like AddRow, but adds a batch of rows at once, and checks the buffer size against maxBufferSize only
+// after all rows are added, so the buffer may grow beyond maxBufferSize by the size of the batch. The optional
+// RowOpts apply to all rows of the batch. If an error occurs, the rows before the failed row are kept.
+func (dw *DirectWriter) AddRows(rows [][]Cell, opts ...RowOpts) (buffered int, err error) {
+	if dw.closed {
+		return len(dw.buf), ErrDirectWriterClosed
+	}
+	if err = dw.ctx.Err(); err != nil {
+		dw.closeDone()
+		return len(dw.buf), err
+	}
+	var attrs string
+	if len(opts) > 0 {
+		if attrs, err = marshalRowAttrs(opts...); err != nil {
+			return len(dw.buf), err
+		}
+		dw.setOutlineLevel(opts[len(opts)-1].OutlineLevel)
+	}
+	rows, longErr := dw.longStringRows(rows)
+	rowAttrs := make([]string, len(rows))
+	for i, values := range rows {
+		rowAttrs[i] = dw.filterRowAttrs(dw.rowCount+i+1, attrs, cellValue(values))
+	}
+	if dw.sharedStrings {
+		shared := make([][]Cell, len(rows))
+		for i, values := range rows {
+			shared[i] = dw.sharedStringValues(values)
+		}
+		rows = shared
+	}
+	dw.Lock()
+	for i, values := range rows {
+		if err = dw.appendRow(0, values, rowAttrs[i]); err != nil {
+			break
+		}
+	}
+	buffered = len(dw.buf)
+	dw.Unlock()
+	if err == nil {
+		err = longErr
+	}
+	if err != nil {
+		return buffered, err
+	}
+	if buffered > dw.maxBufferSize && !dw.waitMode {
+		err = dw.tryFlush()
+		return len(dw.buf), err
+	}
+	return buffered, nil
+}
+
+// AddNumericRow is like AddRow, but adds a row of numbers only, which are written directly to the write buffer without
+// boxing each value in a Cell, for the common all-numeric case of throughput-critical exports. The style of the i-th
+// value is given by styleIDs[i], styleIDs may be shorter than vals or nil for unstyled cells. Like AddRow, a NaN or
+// infinite number is written as the #NUM! error, unless it is rejected by the RejectNonFinite option.
+func (dw *DirectWriter) AddNumericRow(vals []float64, styleIDs []int) (buffered int, err error) {
+	if dw.closed {
+		return len(dw.buf), ErrDirectWriterClosed
+	}
+	if err = dw.ctx.Err(); err != nil {
+		dw.closeDone()
+		return len(dw.buf), err
+	}
+	attrs := dw.filterRowAttrs(dw.rowCount+1, "", func(col int) interface{} {
+		if col > len(vals) {
+			return nil
+		}
+		return vals[col-1]
+	})
+	dw.Lock()
+	err = dw.appendNumericRow(vals, styleIDs, attrs)
+	buffered = len(dw.buf)
+	dw.Unlock()
+	if err != nil {
+		return buffered, err
+	}
+	if buffered > dw.maxBufferSize && !dw.waitMode {
+		err = dw.tryFlush()
+		return len(dw.buf), err
+	}
+	return buffered, nil
+}
+
+// appendNumericRow appends a row of the given numbers, styles and row attributes to the write buffer after the last
+// row, the caller must hold the lock. The buffer is left unchanged if the row exceeds the maximum number of columns or rows, or the
+// maximum size of the worksheet, or if a non-finite number is rejected.
+func (dw *DirectWriter) appendNumericRow(vals []float64, styleIDs []int, attrs string) error {
+	if len(vals) > TotalColumns {
+		return ErrColumnNumber
+	}
+	if dw.rowCount >= TotalRows {
+		return ErrMaxRows
+	}
+	reject := dw.File.options != nil && dw.File.options.RejectNonFinite
+	for _, v := range vals {
+		if reject && (math.IsNaN(v) || math.IsInf(v, 0)) {
+			return ErrNonFiniteNumber
+		}
+	}
+	n, rowCount, cols := len(dw.buf), dw.rowCount, len(dw.maxColLengths)
+	dw.rowCount++
+	dw.buf = append(dw.buf, `<row r="`...)
+	dw.buf = strconv.AppendInt(dw.buf, int64(dw.rowCount), 10)
+	dw.buf = append(dw.buf, '"')
+	dw.buf = dw.appendSpans(dw.buf, len(vals))
+	dw.buf = append(dw.buf, attrs...)
+	dw.buf = append(dw.buf, '>')
+	if len(vals) > len(dw.maxColLengths) {
+		l := make([]int, len(vals))
+		copy(l, dw.maxColLengths)
+		dw.maxColLengths = l
+	}
+	dw.rowKinds, dw.prevColLens = dw.rowKinds[:0], dw.prevColLens[:0]
+	for i, v := range vals {
+		var s int
+		if i < len(styleIDs) {
+			s = styleIDs[i]
+		}
+		if s == 0 {
+			s = dw.defaultStyle
+		}
+		var l int
+		dw.buf, l = appendNumericCell(dw.buf, v, s)
+		dw.raiseColLength(i, l)
+		if dw.statsEnabled {
+			kind := cellKindNumber
+			if math.IsNaN(v) || math.IsInf(v, 0) {
+				kind = cellKindError
+			}
+			dw.rowKinds = append(dw.rowKinds, kind)
+		}
+	}
+	dw.buf = append(dw.buf, "</row>"...)
+	if err := dw.checkMaxBytes(n, rowCount, cols, len(dw.hyperlinks)); err != nil {
+		return err
+	}
+	dw.addColumnStats(dw.rowKinds)
+	return nil
+}
+
+// AddRawRow appends a pre-rendered row element, such as a cached `<row r="2"><c><v>1</v></c></row>` fragment, to the
+// sheet data of the DirectWriter, and triggers the same flush check as AddRow. The fragment is written as is, so the
+// caller is responsible for a well-formed row element with escaped values, and for its row number attribute, which
+// must be the next row number, that is the number of rows returned by Stats plus one. The row counts as one row, but
+// its cells are not taken into account by MaxColumnLengths. It returns ErrDirectWriterRawRow if the fragment doesn't
+// begin with "<row".
+func (dw *DirectWriter) AddRawRow(fragment []byte) (buffered int, err error) {
+	if dw.closed {
+		return len(dw.buf), ErrDirectWriterClosed
+	}
+	if err = dw.ctx.Err(); err != nil {
+		dw.closeDone()
+		return len(dw.buf), err
+	}
+	if !bytes.HasPrefix(fragment, []byte("<row")) {
+		return len(dw.buf), ErrDirectWriterRawRow
+	}
+	dw.Lock()
+	if dw.rowCount >= TotalRows {
+		err = ErrMaxRows
+	} else {
+		n := len(dw.buf)
+		dw.rowCount, dw.prevColLens = dw.rowCount+1, dw.prevColLens[:0]
+		dw.buf = append(dw.buf, fragment...)
+		err = dw.checkMaxBytes(n, dw.rowCount-1, len(dw.maxColLengths), len(dw.hyperlinks))
+	}
+	buffered = len(dw.buf)
+	dw.Unlock()
+	if err != nil {
+		return buffered, err
+	}
+	if buffered > dw.maxBufferSize && !dw.waitMode {
+		err = dw.tryFlush()
+		return len(dw.buf), err
+	}
+	return buffered, nil
+}
+
+// setOutlineLevel records the given row outline level, the maximum outline level of the rows is written to the sheet
+// format properties when the writer is closed.
+func (dw *DirectWriter) setOutlineLevel(level uint8) {
+	if level > dw.outlineLevel {
+		dw.outlineLevel = level
+	}
+}
+
+// appendRow appends a row of the given values and row attributes to the write buffer at the given row number, or
+// after the last row if the row number is 0, the caller must hold the lock. The buffer is left unchanged if the row
+// exceeds the maximum number of columns or rows, or the maximum size of the worksheet, or if the row number is not
+// after the last row.
+func (dw *DirectWriter) appendRow(row int, values []Cell, attrs string) error {
+	if len(values) > TotalColumns {
+		return ErrColumnNumber
+	}
+	if row == 0 {
+		row = dw.rowCount + 1
+	}
+	if row > TotalRows {
+		return ErrMaxRows
+	}
+	if row <= dw.rowCount {
+		return ErrDirectWriterRowOrder
+	}
+	n, rowCount, cols, hyperlinks := len(dw.buf), dw.rowCount, len(dw.maxColLengths), len(dw.hyperlinks)
+	dw.rowCount = row
+	dw.rowKinds, dw.prevColLens = dw.rowKinds[:0], dw.prevColLens[:0]
+	dw.buf = append(dw.buf, `<row r="`...)
+	dw.buf = strconv.AppendInt(dw.buf, int64(dw.rowCount), 10)
+	dw.buf = append(dw.buf, '"')
+	dw.buf = dw.appendSpans(dw.buf, len(values))
+	dw.buf = append(dw.buf, attrs...)
+	dw.buf = append(dw.buf, '>')
+	if len(values) > len(dw.maxColLengths) {
+		l := make([]int, len(values))
+		copy(l, dw.maxColLengths)
+		dw.maxColLengths = l
+	}
+	for i, val := range values {
+		c := xlsxC{
+			S: val.StyleID,
+		}
+		if val.NumFmt != "" {
+			var err error
+			if c.S, err = dw.File.numFmtStyleID(val.NumFmt); err != nil {
+				dw.buf = append(dw.buf, "</row>"...)
+				return err
+			}
+		}
+		if val.Hyperlink != nil {
+			if _, _, err := val.Hyperlink.options(); err != nil {
+				dw.buf = append(dw.buf, "</row>"...)
+				return err
+			}
+			cell, _ := CoordinatesToCellName(i+1, row)
+			dw.hyperlinks = append(dw.hyperlinks, directHyperlink{cell: cell, link: *val.Hyperlink})
+			val.Value = val.Hyperlink.value(val)
+		}
+		if val.Formula != "" {
+			c.F = &xlsxF{Content: val.Formula}
+		}
+		if c.S == 0 {
+			if _, ok := val.Value.(time.Time); !ok || dw.autoDateStyle == 0 {
+				c.S = dw.defaultStyle
+			}
+		}
+		if val.RawValue != nil {
+			dw.raiseColLength(i, len(val.RawValue))
+			dw.buf = appendRawCellNoRef(dw.buf, c, val.RawValue, dw.rawFormulas)
+			if dw.statsEnabled {
+				dw.rowKinds = append(dw.rowKinds, cellKindNumber)
+			}
+			continue
+		}
+		if i, ok := val.Value.(sharedStringIndex); ok {
+			c.T, c.V = "s", strconv.Itoa(int(i))
+		} else if s, ok := val.Value.(sharedStringOverflow); ok {
+			_, c.V, c.XMLSpace = setCellStr(string(s))
+			c.T = "inlineStr"
+		} else if err := dw.File.setStreamCellValFunc(&c, val.Value); err != nil {
+			dw.buf = append(dw.buf, "</row>"...)
+			return err
+		}
+		if _, ok := val.Value.(time.Time); ok && c.S == 0 {
+			c.S = dw.autoDateStyle
+		}
+		if dw.inlineStrings && c.T == "str" && c.F == nil {
+			c.T = "inlineStr"
+		}
+		dw.raiseColLength(i, len(c.V))
+		dw.buf = appendCellNoRef(dw.buf, c, dw.rawFormulas, dw.controlChars)
+		if dw.statsEnabled {
+			dw.rowKinds = append(dw.rowKinds, inferCellKind(c, val.Value))
+		}
+	}
+	dw.buf = append(dw.buf, "</row>"...)
+	if err := dw.checkMaxBytes(n, rowCount, cols, hyperlinks); err != nil {
+		return err
+	}
+	dw.addColumnStats(dw.rowKinds)
+	return nil
+}
+
+// appendSpans appends the spans attribute of a row of the given number of cells to dst, if the spans are enabled and
+// the row has cells.
+func (dw *DirectWriter) appendSpans(dst []byte, cells int) []byte {
+	if !dw.emitSpans || cells == 0 {
+		return dst
+	}
+	dst = append(dst, ` spans="1:`...)
+	dst = strconv.AppendInt(dst, int64(cells), 10)
+	return append(dst, '"')
+}
+
+// Stats returns the number of rows written so far, which is the number of the last row if rows are skipped by
+// AddRowAt, the number of bytes already flushed to the underlying writer, or queued for it by the asynchronous
+// flushing, and the number of bytes currently in the write buffer. It is safe to be called from another goroutine
+// while rows are being added.
+func (dw *DirectWriter) Stats() (rows int, bytesFlushed int64, buffered int) {
+	dw.RLock()
+	defer dw.RUnlock()
+	return dw.rowCount, dw.bytesWritten, len(dw.buf)
+}
+
+// IsConcurrent reports whether the writer is attached to the writer of WriteTo or File.WriteTo, so that the rows are
+// flushed to it while they are being added, or whether the worksheet is still buffered until it is written. It is
+// safe to be called from another goroutine.
+func (dw *DirectWriter) IsConcurrent() bool {
+	dw.RLock()
+	defer dw.RUnlock()
+	return dw.out != nil
+}
+
+// MaxColumnLengths returns the max lengths (in bytes as written to XML) for each column written so far.
+func (dw *DirectWriter) MaxColumnLengths() []int {
+	return dw.maxColLengths
+}
+
+// RegisterStyles provides a function to create the given styles for the DirectWriter at once, and returns their style
+// IDs in the same order, to be used as Cell.StyleID or RowOpts.StyleID. Like File.NewStyle, identical styles are
+// created only once, so they get the same style ID. If a style is invalid, the style IDs of the previous styles are
+// returned with the error.
+func (dw *DirectWriter) RegisterStyles(styles []*Style) ([]int, error) {
+	if dw.closed {
+		return nil, ErrDirectWriterClosed
+	}
+	ids := make([]int, 0, len(styles))
+	for _, style := range styles {
+		if style == nil {
+			return ids, ErrParameterInvalid
+		}
+		id, err := dw.File.NewStyle(style)
+		if err != nil {
+			return ids, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// SetColWidth provides a function to set the width of a single column or
+// multiple columns for the DirectWriter. Since column definitions need to be written before sheet data, either use this
+// function before the first call to AddRow, or set the writer in wait mode using SetWait.
+func (dw *DirectWriter) SetColWidth(min, max int, width float64) error {
+	if dw.closed {
+		return ErrDirectWriterClosed
+	}
+	dw.Lock()
+	defer dw.Unlock()
+	if dw.bytesWritten > 0 {
+		return errors.New("Can't set col width since first data already written.")
+	}
+	return dw.setColWidth(min, max, width)
+}
+
+// setColWidth sets the width of the columns min to max of the DirectWriter. The caller must hold the lock.
+func (dw *DirectWriter) setColWidth(min, max int, width float64) error {
+	if min > TotalColumns || max > TotalColumns {
+		return ErrColumnNumber
+	}
+	if min < 1 || max < 1 {
+		return ErrColumnNumber
+	}
+	if width > MaxColumnWidth {
+		return ErrColumnWidth
+	}
+	if min > max {
+		min, max = max, min
+	}
+	dw.cols += fmt.Sprintf(`<col min="%d" max="%d" width="%s" customWidth="1"/>`, min, max, strconv.FormatFloat(width, 'f', -1, 64))
+	return nil
+}
+
+// AutoFitColumns provides a function to set the width of the columns for the DirectWriter by the maximum length of
+// the cell values of each column, see MaxColumnLengths. The width is approximated by the number of bytes plus the
+// cell padding, and multiplied by the given scale, which can be less than 1 for multibyte content, or greater than 1
+// for wide fonts. The columns without values keep the default width. Since column definitions need to be written
+// before sheet data, it only works in wait mode or before WriteTo is called, and returns an error once the first data
+// is flushed. Note that the columns must not overlap with the columns set by SetColWidth.
+func (dw *DirectWriter) AutoFitColumns(scale float64) error {
+	if dw.closed {
+		return ErrDirectWriterClosed
+	}
+	dw.Lock()
+	defer dw.Unlock()
+	if dw.bytesWritten > 0 {
+		return errors.New("Can't auto fit columns since first data already written.")
+	}
+	if scale <= 0 {
+		return ErrParameterInvalid
+	}
+	widths := make([]float64, len(dw.maxColLengths))
+	for i, l := range dw.maxColLengths {
+		if l > 0 {
+			// the padding is 5 pixels of the maximum digit width of 7 pixels
+			widths[i] = math.Min(math.Round((float64(l)+5.0/7.0)*scale*256)/256, MaxColumnWidth)
+		}
+	}
+	for min := 0; min < len(widths); {
+		max := min
+		for max+1 < len(widths) && widths[max+1] == widths[min] {
+			max++
+		}
+		if widths[min] > 0 {
+			if err := dw.setColWidth(min+1, max+1, widths[min]); err != nil {
+				return err
+			}
+		}
+		min = max + 1
+	}
+	return nil
+}
+
+// SetColOutlineLevel provides a function to set the outline level of a single column or multiple columns for the
+// DirectWriter, the value of parameter 'level' is 0-7. Like SetColWidth, it must be called before the first data is
+// flushed. Note that the columns must not overlap with the columns set by SetColWidth.
+func (dw *DirectWriter) SetColOutlineLevel(min, max, level int, collapsed bool) error {
+	if dw.closed {
+		return ErrDirectWriterClosed
+	}
+	dw.Lock()
+	defer dw.Unlock()
+	if dw.bytesWritten > 0 {
+		return errors.New("Can't set col outline level since first data already written.")
+	}
+	if min > TotalColumns || max > TotalColumns {
+		return ErrColumnNumber
+	}
+	if min < 1 || max < 1 {
+		return ErrColumnNumber
+	}
+	if level < 0 || level > 7 {
+		return ErrOutlineLevel
+	}
+	if min > max {
+		min, max = max, min
+	}
+	dw.cols += fmt.Sprintf(`<col min="%d" max="%d"`, min, max)
+	if level > 0 {
+		dw.cols += fmt.Sprintf(` outlineLevel="%d"`, level)
+	}
+	if collapsed {
+		dw.cols += ` collapsed="1"`
+	}
+	dw.cols += `/>`
+	return nil
+}
+
+// SetDefaultRowHeight provides a function to set the default height of all rows
+// for the DirectWriter, which avoids repeating the height on each row. Like SetColWidth, it must be called before
+// the first data is flushed, either before the first call to AddRow or by setting the writer in wait mode.
+func (dw *DirectWriter) SetDefaultRowHeight(height float64) error {
+	if dw.closed {
+		return ErrDirectWriterClosed
+	}
+	dw.Lock()
+	defer dw.Unlock()
+	if dw.bytesWritten > 0 {
+		return errors.New("Can't set default row height since first data already written.")
+	}
+	if height > MaxRowHeight {
+		return ErrMaxRowHeight
+	}
+	if dw.worksheet.SheetFormatPr == nil {
+		dw.worksheet.SheetFormatPr = &xlsxSheetFormatPr{}
+	}
+	dw.worksheet.SheetFormatPr.DefaultRowHeight = height
+	dw.worksheet.SheetFormatPr.CustomHeight = true
+	return nil
+}
+
+// SetDefaultColWidth provides a function to set the default width of the columns for the DirectWriter, which applies
+// to all columns without a width set by SetColWidth. Like SetColWidth, it must be called before the first data is
+// flushed, either before the first call to AddRow or by setting the writer in wait mode.
+func (dw *DirectWriter) SetDefaultColWidth(width float64) error {
+	if dw.closed {
+		return ErrDirectWriterClosed
+	}
+	dw.Lock()
+	defer dw.Unlock()
+	if dw.bytesWritten > 0 {
+		return errors.New("Can't set default col width since first data already written.")
+	}
+	if width < 0 {
+		return ErrParameterInvalid
+	}
+	if width > MaxColumnWidth {
+		return ErrColumnWidth
+	}
+	if dw.worksheet.SheetFormatPr == nil {
+		dw.worksheet.SheetFormatPr = &xlsxSheetFormatPr{DefaultRowHeight: defaultRowHeight}
+	}
+	dw.worksheet.SheetFormatPr.DefaultColWidth = width
+	return nil
+}
+
+// SetDimension provides a function to declare the used range of the worksheet for the DirectWriter by given
+// reference, such as "A1:D10". Since the dimension needs to be written before sheet data, it must be called before the
+// first data is flushed. If no dimension is declared, it is computed from the written rows when the writer is closed,
+// but only if the header hasn't been written yet, i.e. in wait mode or when WriteTo is called after Close.
+func (dw *DirectWriter) SetDimension(ref string) error {
+	if dw.closed {
+		return ErrDirectWriterClosed
+	}
+	dw.Lock()
+	defer dw.Unlock()
+	if dw.bytesWritten > 0 {
+		return errors.New("Can't set dimension since first data already written.")
+	}
+	cells := strings.Split(ref, ":")
+	if len(cells) > 2 {
+		return ErrParameterInvalid
+	}
+	for _, cell := range cells {
+		if _, _, err := CellNameToCoordinates(cell); err != nil {
+			return err
+		}
+	}
+	dw.worksheet.Dimension = &xlsxDimension{Ref: ref}
+	dw.dimensionSet = true
+	return nil
+}
+
+// SetPanes provides a function to create and remove freeze panes and split panes for the DirectWriter by given panes
+// format set, see File.SetPanes for details on the format. Since the sheet views need to be written before sheet data,
+// it must be called before the first data is flushed. For example, freeze the first row:
+//
+//    err := dw.SetPanes(`{"freeze":true,"split":false,"x_split":0,"y_split":1,"top_left_cell":"A2","active_pane":"bottomLeft"}`)
+//
+func (dw *DirectWriter) SetPanes(panes string) error {
+	if dw.closed {
+		return ErrDirectWriterClosed
+	}
+	dw.Lock()
+	defer dw.Unlock()
+	if dw.bytesWritten > 0 {
+		return errors.New("Can't set panes since first data already written.")
+	}
+	dw.worksheet.setPanes(panes)
+	return nil
+}
+
+// SetActiveCell provides a function to set the active cell of the DirectWriter by given cell reference, such as "A1",
+// which is selected when the worksheet is opened. If the worksheet has panes, the cell is selected in the active pane,
+// so it must be called after SetPanes. Since the sheet views need to be written before sheet data, it must be called
+// before the first data is flushed.
+func (dw *DirectWriter) SetActiveCell(cell string) error {
+	if dw.closed {
+		return ErrDirectWriterClosed
+	}
+	dw.Lock()
+	defer dw.Unlock()
+	if dw.bytesWritten > 0 {
+		return errors.New("Can't set active cell since first data already written.")
+	}
+	if _, _, err := CellNameToCoordinates(cell); err != nil {
+		return err
+	}
+	ws := dw.worksheet
+	if ws.SheetViews == nil || len(ws.SheetViews.SheetView) == 0 {
+		ws.SheetViews = &xlsxSheetViews{SheetView: []xlsxSheetView{{WorkbookViewID: 0}}}
+	}
+	view := &ws.SheetViews.SheetView[len(ws.SheetViews.SheetView)-1]
+	selection := &xlsxSelection{ActiveCell: cell, SQRef: cell}
+	if view.Pane == nil {
+		view.Selection = []*xlsxSelection{selection}
+		return nil
+	}
+	selection.Pane = view.Pane.ActivePane
+	for i, s := range view.Selection {
+		if s.Pane == selection.Pane {
+			view.Selection[i] = selection
+			return nil
+		}
+	}
+	view.Selection = append(view.Selection, selection)
+	return nil
+}
+
+// SheetViewType is the type of the view of a worksheet, such as the page break preview.
+type SheetViewType string
+
+// The sheet view types.
+const (
+	SheetViewNormal           SheetViewType = "normal"
+	SheetViewPageBreakPreview SheetViewType = "pageBreakPreview"
+	SheetViewPageLayout       SheetViewType = "pageLayout"
+)
+
+// SetView provides a function to set the zoom level in percent and the view type of the DirectWriter, such as 80 and
+// SheetViewPageBreakPreview for an export meant for presentation. The zoom level must be within Excel's range of 10
+// to 400. Since the sheet views need to be written before sheet data, it must be called before the first data is
+// flushed.
+func (dw *DirectWriter) SetView(zoom int, view SheetViewType) error {
+	if dw.closed {
+		return ErrDirectWriterClosed
+	}
+	dw.Lock()
+	defer dw.Unlock()
+	if dw.bytesWritten > 0 {
+		return errors.New("Can't set view since first data already written.")
+	}
+	if zoom < 10 || zoom > 400 {
+		return ErrParameterInvalid
+	}
+	switch view {
+	case SheetViewNormal, SheetViewPageBreakPreview, SheetViewPageLayout:
+	default:
+		return ErrParameterInvalid
+	}
+	ws := dw.worksheet
+	if ws.SheetViews == nil || len(ws.SheetViews.SheetView) == 0 {
+		ws.SheetViews = &xlsxSheetViews{SheetView: []xlsxSheetView{{WorkbookViewID: 0}}}
+	}
+	sv := &ws.SheetViews.SheetView[len(ws.SheetViews.SheetView)-1]
+	sv.ZoomScale, sv.View = float64(zoom), string(view)
+	return nil
+}
+
+// SetSheetViewOptions provides a function to set the sheet view options of the DirectWriter by given view index and
+// options, see File.SetSheetViewOptions for details, such as hiding the gridlines and the row and column headings.
+// Since the sheet views need to be written before sheet data, it must be called before the first data is flushed.
+func (dw *DirectWriter) SetSheetViewOptions(viewIndex int, opts ...SheetViewOption) error {
+	if dw.closed {
+		return ErrDirectWriterClosed
+	}
+	dw.Lock()
+	defer dw.Unlock()
+	if dw.bytesWritten > 0 {
+		return errors.New("Can't set sheet view options since first data already written.")
+	}
+	view, err := dw.worksheet.getSheetView(viewIndex)
+	if err != nil {
+		return err
+	}
+	for _, opt := range opts {
+		opt.setSheetViewOption(view)
+	}
+	return nil
+}
+
+// SetWorksheetAttrs provides a function to add the given attributes to the root element of the worksheet of the
+// DirectWriter, such as `xmlns:foo="urn:foo" mc:Ignorable="x14ac foo"`, for the extension namespaces expected by other
+// tools. The attributes replace the default attributes of the same name, and the others are added after them. It
+// returns an error if the attributes are not well-formed, and since the root element is written first, it must be
+// called before the first data is flushed.
+func (dw *DirectWriter) SetWorksheetAttrs(attrs string) error {
+	if dw.closed {
+		return ErrDirectWriterClosed
+	}
+	dw.Lock()
+	defer dw.Unlock()
+	if dw.bytesWritten > 0 {
+		return errors.New("Can't set worksheet attributes since first data already written.")
+	}
+	custom, err := parseRootAttrs(attrs)
+	if err != nil {
+		return err
+	}
+	root, _ := parseRootAttrs(strings.TrimSuffix(templateNamespaceIDMap, ">"))
+	for _, attr := range custom {
+		replaced := false
+		for i := range root {
+			if root[i].Name == attr.Name {
+				root[i].Value, replaced = attr.Value, true
+			}
+		}
+		if !replaced {
+			root = append(root, attr)
+		}
+	}
+	var buf []byte
+	for _, attr := range root {
+		buf = append(buf, ' ')
+		if attr.Name.Space != "" {
+			buf = append(append(buf, attr.Name.Space...), ':')
+		}
+		buf = append(append(buf, attr.Name.Local...), `="`...)
+		buf = append(appendEscapedString(buf, attr.Value, true), '"')
+	}
+	dw.rootAttrs = string(buf)
+	return nil
+}
+
+// parseRootAttrs parses the given attributes of a root element, without resolving the namespace prefixes.
+func parseRootAttrs(attrs string) ([]xml.Attr, error) {
+	decoder := xml.NewDecoder(strings.NewReader("<worksheet " + attrs + "/>"))
+	token, err := decoder.RawToken()
+	if err != nil {
+		return nil, err
+	}
+	start, ok := token.(xml.StartElement)
+	if token, err = decoder.RawToken(); err != nil {
+		return nil, err
+	}
+	if _, end := token.(xml.EndElement); !ok || !end {
+		return nil, ErrParameterInvalid
+	}
+	if _, err = decoder.RawToken(); err != io.EOF {
+		return nil, ErrParameterInvalid
+	}
+	return start.Attr, nil
+}
+
+// SetTabColor provides a function to set the tab color of the worksheet for the DirectWriter by given hex color, such
+// as "#FF0000". Since the sheet properties need to be written before sheet data, it must be called before the first
+// data is flushed.
+func (dw *DirectWriter) SetTabColor(hex string) error {
+	if dw.closed {
+		return ErrDirectWriterClosed
+	}
+	dw.Lock()
+	defer dw.Unlock()
+	if dw.bytesWritten > 0 {
+		return errors.New("Can't set tab color since first data already written.")
+	}
+	dw.worksheet.setSheetPrOptions(TabColor(hex))
+	return nil
+}
+
+// SetOutlineProps sets the position of the summary rows and columns of the row and column groups of the worksheet for
+// the DirectWriter, below or above the detail rows and right or left of the detail columns, which Excel writes to the
+// outline properties of the sheet properties, so that the expand and collapse controls of the groups appear next to
+// the summaries. Since the sheet properties need to be written before sheet data, it must be called before the first
+// data is flushed.
+func (dw *DirectWriter) SetOutlineProps(summaryBelow, summaryRight bool) error {
+	if dw.closed {
+		return ErrDirectWriterClosed
+	}
+	dw.Lock()
+	defer dw.Unlock()
+	if dw.bytesWritten > 0 {
+		return errors.New("Can't set outline properties since first data already written.")
+	}
+	if dw.worksheet.SheetPr == nil {
+		dw.worksheet.SheetPr = new(xlsxSheetPr)
+	}
+	if dw.worksheet.SheetPr.OutlinePr == nil {
+		dw.worksheet.SheetPr.OutlinePr = &xlsxOutlinePr{ShowOutlineSymbols: true}
+	}
+	dw.worksheet.SheetPr.OutlinePr.SummaryBelow = summaryBelow
+	dw.worksheet.SheetPr.OutlinePr.SummaryRight = summaryRight
+	return nil
+}
+
+// SetSheetVisible provides a function to set the worksheet of the DirectWriter visible or hidden. The state is stored
+// in the workbook, and applied when the workbook is written by File.WriteTo after the DirectWriter is closed. Like
+// File.SetSheetVisible, the active worksheet can't be hidden.
+func (dw *DirectWriter) SetSheetVisible(visible bool) error {
+	if dw.closed {
+		return ErrDirectWriterClosed
+	}
+	dw.sheetState = ""
+	if !visible {
+		dw.sheetState = "hidden"
+	}
+	return nil
+}
+
+// SheetStateType is the type of the visibility state of a worksheet.
+type SheetStateType byte
+
+// Worksheet visibility states enumeration.
+const (
+	SheetStateVisible SheetStateType = iota
+	SheetStateHidden
+	SheetStateVeryHidden
+)
+
+// SetSheetState provides a function to set the visibility state of the worksheet of the DirectWriter, a very hidden
+// worksheet can't be made visible from the user interface of Excel. The state is applied like SetSheetVisible. A
+// workbook must contain at least one visible worksheet, so it returns an error on hiding the last visible worksheet,
+// including the states set on the other direct writers of the workbook.
+func (dw *DirectWriter) SetSheetState(state SheetStateType) error {
+	if dw.closed {
+		return ErrDirectWriterClosed
+	}
+	sheetState, ok := map[SheetStateType]string{
+		SheetStateVisible:    "",
+		SheetStateHidden:     "hidden",
+		SheetStateVeryHidden: "veryHidden",
+	}[state]
+	if !ok {
+		return ErrSheetState
+	}
+	f := dw.File
+	f.Lock()
+	defer f.Unlock()
+	if sheetState != "" {
+		var visible int
+		for _, sheet := range f.workbookReader().Sheets.Sheet {
+			if sheet.SheetID == dw.SheetID {
+				continue
+			}
+			state := sheet.State
+			for _, d := range f.directWriters {
+				if d.SheetID == sheet.SheetID {
+					state = d.sheetState
+				}
+			}
+			if state == "" {
+				visible++
+			}
+		}
+		if visible == 0 {
+			return ErrSheetVisible
+		}
+	}
+	dw.sheetState = sheetState
+	return nil
+}
+
+// setSheetState applies the sheet state of the DirectWriter to the workbook.
+func (dw *DirectWriter) setSheetState() {
+	if dw.sheetState != "" && dw.worksheet.SheetViews != nil && len(dw.worksheet.SheetViews.SheetView) > 0 && dw.worksheet.SheetViews.SheetView[0].TabSelected {
+		return
+	}
+	wb := dw.File.workbookReader()
+	for k, v := range wb.Sheets.Sheet {
+		if v.SheetID == dw.SheetID {
+			wb.Sheets.Sheet[k].State = dw.sheetState
+		}
+	}
+}
+
+// SetPageLayout provides a function to set the page layout of the worksheet of the DirectWriter by given options, see
+// File.SetPageLayout for the available options. The page setup is buffered and written when the writer is closed,
+// so it may be called at any time before Close, except for the FitToWidth and FitToHeight options: these also
+// enable the fit to page property of the sheet, which needs to be written before sheet data, so they must be set
+// before the first data is flushed.
+func (dw *DirectWriter) SetPageLayout(opts ...PageLayoutOption) error {
+	if dw.closed {
+		return ErrDirectWriterClosed
+	}
+	var fitToPage bool
+	for _, opt := range opts {
+		switch opt.(type) {
+		case FitToWidth, FitToHeight:
+			fitToPage = true
+		}
+	}
+	if fitToPage {
+		dw.Lock()
+		if dw.bytesWritten > 0 {
+			dw.Unlock()
+			return errors.New("Can't set fit to page since first data already written.")
+		}
+		dw.worksheet.setSheetPrOptions(FitToPage(true))
+		dw.Unlock()
+	}
+	return dw.File.SetPageLayout(dw.Sheet, opts...)
+}
+
+// SetPageMargins provides a function to set the page margins of the worksheet of the DirectWriter by given options,
+// see File.SetPageMargins for the available options. The margins are buffered and written when the writer is
+// closed, so it may be called at any time before Close.
+func (dw *DirectWriter) SetPageMargins(opts ...PageMarginsOptions) error {
+	if dw.closed {
+		return ErrDirectWriterClosed
+	}
+	return dw.File.SetPageMargins(dw.Sheet, opts...)
+}
+
+// AddPageBreakRow provides a function to insert a manual page break before the given row number of the DirectWriter,
+// such as at the boundary of a section of a print-paginated export. The page breaks are written after the merged
+// cells when the writer is closed, so it may be called at any time before Close.
+func (dw *DirectWriter) AddPageBreakRow(row int) error {
+	if dw.closed {
+		return ErrDirectWriterClosed
+	}
+	if row < 2 || row > TotalRows {
+		return newInvalidRowNumberError(row)
+	}
+	dw.worksheet.RowBreaks = addPageBreak(dw.worksheet.RowBreaks, row-1, TotalColumns-1)
+	return nil
+}
+
+// AddPageBreakCol provides a function to insert a manual page break before the given column number of the
+// DirectWriter, like AddPageBreakRow.
+func (dw *DirectWriter) AddPageBreakCol(col int) error {
+	if dw.closed {
+		return ErrDirectWriterClosed
+	}
+	if col < 2 || col > TotalColumns {
+		return ErrColumnNumber
+	}
+	dw.worksheet.ColBreaks = addPageBreak(dw.worksheet.ColBreaks, col-1, TotalRows-1)
+	return nil
+}
+
+// addPageBreak adds a manual page break after the given zero-based row or column number to the given page breaks,
+// unless it already exists, and keeps the counts of the page breaks in sync.
+func addPageBreak(breaks *xlsxBreaks, id, max int) *xlsxBreaks {
+	if breaks == nil {
+		breaks = &xlsxBreaks{}
+	}
+	for _, brk := range breaks.Brk {
+		if brk.ID == id {
+			return breaks
+		}
+	}
+	breaks.Brk = append(breaks.Brk, &xlsxBrk{ID: id, Max: max, Man: true})
+	breaks.Count = len(breaks.Brk)
+	breaks.ManualBreakCount++
+	return breaks
+}
+
+// SetDefinedPrintArea provides a function to set the print area of the worksheet of the DirectWriter by given
+// area reference, such as "A1:D20". The print area is stored as the _xlnm.Print_Area defined name of the worksheet,
+// which is registered when the workbook is written by File.WriteTo after the DirectWriter is closed. Passing an
+// empty reference removes a print area previously set on the DirectWriter.
+func (dw *DirectWriter) SetDefinedPrintArea(ref string) error {
+	if dw.closed {
+		return ErrDirectWriterClosed
+	}
+	if ref == "" {
+		dw.printArea = ""
+		return nil
+	}
+	coordinates, err := areaRefToCoordinates(ref)
+	if err != nil {
+		return err
+	}
+	_ = sortCoordinates(coordinates)
+	hcell, _ := CoordinatesToCellName(coordinates[0], coordinates[1], true)
+	vcell, _ := CoordinatesToCellName(coordinates[2], coordinates[3], true)
+	dw.printArea = fmt.Sprintf("'%s'!%s:%s", strings.ReplaceAll(dw.Sheet, "'", "''"), hcell, vcell)
+	return nil
+}
+
+// setPrintArea applies the print area of the DirectWriter to the defined names of the workbook.
+func (dw *DirectWriter) setPrintArea() {
+	if dw.printArea == "" {
+		return
+	}
+	name, sheetIndex := "_xlnm.Print_Area", dw.File.GetSheetIndex(dw.Sheet)
+	wb := dw.File.workbookReader()
+	if wb.DefinedNames == nil {
+		wb.DefinedNames = &xlsxDefinedNames{}
+	}
+	for idx, dn := range wb.DefinedNames.DefinedName {
+		if dn.Name == name && dn.LocalSheetID != nil && *dn.LocalSheetID == sheetIndex {
+			wb.DefinedNames.DefinedName[idx].Data = dw.printArea
+			return
+		}
+	}
+	wb.DefinedNames.DefinedName = append(wb.DefinedNames.DefinedName, xlsxDefinedName{
+		Name:         name,
+		LocalSheetID: intPtr(sheetIndex),
+		Data:         dw.printArea,
+	})
+}
+
+// MergeCell provides a function to merge cells by a given coordinate area for
+// the DirectWriter. The merged cells are buffered and written when the writer
+// is closed, so it may be called at any time before Close. An error is
+// returned if the area overlaps with a previously merged area.
+func (dw *DirectWriter) MergeCell(hcell, vcell string) error {
+	if dw.closed {
+		return ErrDirectWriterClosed
+	}
+	rect, err := areaRangeToCoordinates(hcell, vcell)
+	if err != nil {
+		return err
+	}
+	_ = sortCoordinates(rect)
+	for _, r := range dw.mergeRects {
+		if isOverlap(rect, r) {
+			return ErrMergeCellOverlap
+		}
+	}
+	for _, mc := range dw.preservedMergeCells() {
+		if r, err := areaRefToCoordinates(mc.Ref); err == nil && isOverlap(rect, r) {
+			return ErrMergeCellOverlap
+		}
+	}
+	hcell, _ = CoordinatesToCellName(rect[0], rect[1])
+	vcell, _ = CoordinatesToCellName(rect[2], rect[3])
+	dw.mergeRects = append(dw.mergeRects, rect)
+	dw.mergeCells += `<mergeCell ref="` + hcell + `:` + vcell + `"/>`
+	return nil
+}
+
+// AddDataValidation provides a function to add a data validation, such as a drop-down list, to the worksheet of the
+// DirectWriter. The data validations are buffered and written after the merged cells when the writer is closed, so
+// it may be called at any time before Close.
+func (dw *DirectWriter) AddDataValidation(dv *DataValidation) error {
+	if dw.closed {
+		return ErrDirectWriterClosed
+	}
+	return dw.File.AddDataValidation(dw.Sheet, dv)
+}
+
+// SetConditionalFormat provides a function to create conditional formatting rules for the given area of the worksheet
+// of the DirectWriter, the format set is the same as for File.SetConditionalFormat. The rules are buffered and written
+// after the merged cells when the writer is closed, so it may be called at any time before Close. The differential
+// styles used by the rules are created by File.NewConditionalStyle, and are written to the styles part when the
+// workbook is finalized by File.WriteTo.
+func (dw *DirectWriter) SetConditionalFormat(area, formatSet string) error {
+	if dw.closed {
+		return ErrDirectWriterClosed
+	}
+	return dw.File.SetConditionalFormat(dw.Sheet, area, formatSet)
+}
+
+// SetCellHyperLink provides a function to set a hyperlink on the cell at the
+// given row and column number of the DirectWriter. If external is true the link is a URL address, otherwise it is a
+// location in this workbook such as "Sheet1!A40". The hyperlinks are buffered and written after the merged cells when
+// the writer is closed, so links may be registered for rows which have already been flushed. Since merged cells of
+// the DirectWriter are not resolved, a hyperlink on a merged area must be set on its top-left cell.
+func (dw *DirectWriter) SetCellHyperLink(row, col int, link string, external bool) error {
+	if dw.closed {
+		return ErrDirectWriterClosed
+	}
+	axis, err := CoordinatesToCellName(col, row)
+	if err != nil {
+		return err
+	}
+	linkType := "Location"
+	if external {
+		linkType = "External"
+	}
+	return dw.File.SetCellHyperLink(dw.Sheet, axis, link, linkType)
+}
+
+// SetHeaderFooter provides a function to set the headers and footers of the worksheet of the DirectWriter by given
+// settings, see File.SetHeaderFooter for the format codes. The settings are buffered and written after the merged
+// cells and hyperlinks when the writer is closed, so it may be called at any time before Close. Passing nil removes
+// the headers and footers.
+func (dw *DirectWriter) SetHeaderFooter(settings *FormatHeaderFooter) error {
+	if dw.closed {
+		return ErrDirectWriterClosed
+	}
+	return dw.File.SetHeaderFooter(dw.Sheet, settings)
+}
+
+// ProtectSheet provides a function to protect the worksheet of the DirectWriter by given settings, see
+// File.ProtectSheet for the settings and the password. The protection is buffered and written after the sheet data
+// when the writer is closed, so it may be called at any time before Close.
+func (dw *DirectWriter) ProtectSheet(settings *FormatSheetProtection) error {
+	if dw.closed {
+		return ErrDirectWriterClosed
+	}
+	return dw.File.ProtectSheet(dw.Sheet, settings)
+}
+
+// AddAutofilter provides a function to add an autofilter on the given range of the worksheet of the DirectWriter, such
+// as "A1:D100", with the header in the first row, and the given filter criteria of its columns, see File.AutoFilter
+// for the filter expressions. For example, to show only the rows where the column C is "active":
+//
+//    err := dw.AddAutofilter("A1:D100", []AutoFilterOptions{{Column: "C", Expression: "x == active"}})
+//
+// Unlike File.AutoFilter, the rows of the range which don't match the criteria are hidden by the writer as they are
+// added, so the autofilter must be added before the first row of the data of the range is added. The autofilter is
+// written after the sheet data when the writer is closed, and the _xlnm._FilterDatabase defined name of the range is
+// registered when the workbook is written by File.WriteTo.
+func (dw *DirectWriter) AddAutofilter(rangeRef string, criteria []AutoFilterOptions) error {
+	if dw.closed {
+		return ErrDirectWriterClosed
+	}
+	coordinates, err := areaRefToCoordinates(rangeRef)
+	if err != nil {
+		return err
+	}
+	_ = sortCoordinates(coordinates)
+	hcol, hrow, vcol, vrow := coordinates[0], coordinates[1], coordinates[2], coordinates[3]
+	cellStart, _ := CoordinatesToCellName(hcol, hrow, true)
+	cellEnd, _ := CoordinatesToCellName(vcol, vrow, true)
+	ref := cellStart + ":" + cellEnd
+	filter := &xlsxAutoFilter{Ref: ref}
+	autoFilter := &directAutoFilter{firstRow: hrow + 1, lastRow: vrow}
+	for _, opts := range criteria {
+		col, err := ColumnNameToNumber(opts.Column)
+		if err != nil {
+			return err
+		}
+		offset := col - hcol
+		if col < hcol || col > vcol {
+			return fmt.Errorf("incorrect index of column '%s'", opts.Column)
+		}
+		filterColumn, err := dw.File.parseFilterColumn(offset, opts.Expression)
+		if err != nil {
+			return err
+		}
+		filter.FilterColumn = append(filter.FilterColumn, filterColumn)
+		autoFilter.columns = append(autoFilter.columns, newDirectFilterColumn(col, filterColumn))
+	}
+	dw.Lock()
+	defer dw.Unlock()
+	if dw.rowCount >= autoFilter.firstRow {
+		return errors.New("Can't add autofilter since rows of the range already added.")
+	}
+	if dw.bytesWritten == 0 && len(filter.FilterColumn) > 0 {
+		if dw.worksheet.SheetPr == nil {
+			dw.worksheet.SheetPr = &xlsxSheetPr{}
+		}
+		dw.worksheet.SheetPr.FilterMode = true
+	}
+	dw.worksheet.AutoFilter = filter
+	dw.autoFilter, dw.filterRef = autoFilter, ref
+	return nil
+}
+
+// setFilterDatabase applies the autofilter range of the DirectWriter to the _xlnm._FilterDatabase defined name of the
+// workbook.
+func (dw *DirectWriter) setFilterDatabase() {
+	if dw.filterRef == "" {
+		return
+	}
+	dw.File.setFilterDatabase(dw.Sheet, dw.filterRef)
+}
+
+// directAutoFilter is the autofilter of the DirectWriter, with the criteria of the columns to hide the rows which
+// don't match them.
+type directAutoFilter struct {
+	firstRow, lastRow int
+	columns           []directFilterColumn
+}
+
+// directFilterColumn is the filter criteria of a column of the autofilter, the row matches if one of the values
+// matches, and if one or all of the custom filters match.
+type directFilterColumn struct {
+	col    int
+	values []directFilterValue
+	custom []directFilterValue
+	and    bool
+}
+
+// directFilterValue is a filter value or a custom filter of a column of the autofilter.
+type directFilterValue struct {
+	operator string
+	val      string
+	num      float64
+	isNum    bool
+	pattern  *regexp.Regexp
+}
+
+// newDirectFilterColumn returns the filter criteria of the given column from the given filter column of the
+// autofilter.
+func newDirectFilterColumn(col int, filterColumn *xlsxFilterColumn) directFilterColumn {
+	fc := directFilterColumn{col: col}
+	if filterColumn.Filters != nil {
+		for _, filter := range filterColumn.Filters.Filter {
+			fc.values = append(fc.values, newDirectFilterValue("equal", filter.Val))
+		}
+	}
+	if filterColumn.CustomFilters != nil {
+		fc.and = filterColumn.CustomFilters.And
+		for _, filter := range filterColumn.CustomFilters.CustomFilter {
+			fc.custom = append(fc.custom, newDirectFilterValue(filter.Operator, filter.Val))
+		}
+	}
+	return fc
+}
+
+// newDirectFilterValue returns the filter value of the given operator and value, the wildcards '*' and '?' of the
+// equal and notEqual operators are compiled to a pattern, with '~' escaping the next character.
+func newDirectFilterValue(operator, val string) directFilterValue {
+	v := directFilterValue{operator: operator, val: val}
+	if num, err := strconv.ParseFloat(val, 64); err == nil {
+		v.num, v.isNum = num, true
+	}
+	if (operator == "equal" || operator == "notEqual") && strings.ContainsAny(val, "*?") {
+		var expr strings.Builder
+		expr.WriteString("(?is)^")
+		for i := 0; i < len(val); i++ {
+			switch val[i] {
+			case '*':
+				expr.WriteString(".*")
+			case '?':
+				expr.WriteString(".")
+			case '~':
+				if i+1 < len(val) {
+					i++
+				}
+				expr.WriteString(regexp.QuoteMeta(val[i : i+1]))
+			default:
+				expr.WriteString(regexp.QuoteMeta(val[i : i+1]))
+			}
+		}
+		expr.WriteString("$")
+		v.pattern = regexp.MustCompile(expr.String())
+	}
+	return v
+}
+
+// match reports whether the given cell value, as returned by filterCellValue, matches the filter value. The special
+// values "blanks" and " " are the blank and non-blank criteria written by the filter expressions.
+func (v *directFilterValue) match(s string, num float64, isNum bool) bool {
+	blank := !isNum && s == ""
+	switch v.val {
+	case "blanks":
+		return blank == (v.operator == "equal")
+	case " ":
+		return blank == (v.operator == "equal")
+	}
+	if blank {
+		return v.operator == "notEqual"
+	}
+	if isNum != v.isNum && v.operator != "equal" && v.operator != "notEqual" {
+		// the numbers and the text are not ordered against each other
+		return false
+	}
+	var cmp int
+	if isNum && v.isNum {
+		if num < v.num {
+			cmp = -1
+		} else if num > v.num {
+			cmp = 1
+		}
+	} else if v.pattern != nil {
+		return v.pattern.MatchString(s) == (v.operator == "equal")
+	} else {
+		cmp = strings.Compare(strings.ToLower(s), strings.ToLower(v.val))
+	}
+	switch v.operator {
+	case "lessThan":
+		return cmp < 0
+	case "lessThanOrEqual":
+		return cmp <= 0
+	case "greaterThan":
+		return cmp > 0
+	case "greaterThanOrEqual":
+		return cmp >= 0
+	case "notEqual":
+		return cmp != 0
+	default:
+		return cmp == 0
+	}
+}
+
+// match reports whether the given cell value, as returned by filterCellValue, matches the filter criteria of the
+// column.
+func (fc *directFilterColumn) match(s string, num float64, isNum bool) bool {
+	if len(fc.values) > 0 {
+		var matched bool
+		for i := range fc.values {
+			if matched = fc.values[i].match(s, num, isNum); matched {
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	for i := range fc.custom {
+		if matched := fc.custom[i].match(s, num, isNum); matched != fc.and {
+			return matched
+		}
+	}
+	return len(fc.custom) == 0 || fc.and
+}
+
+// filterCellValue returns the given cell value as text, and as number for the numeric values, to be matched by the
+// filter criteria of the autofilter.
+func filterCellValue(val interface{}) (s string, num float64, isNum bool) {
+	switch val := val.(type) {
+	case nil:
+		return "", 0, false
+	case string:
+		return val, 0, false
+	case []byte:
+		return string(val), 0, false
+	case bool:
+		if val {
+			return "TRUE", 0, false
+		}
+		return "FALSE", 0, false
+	}
+	var c xlsxC
+	_ = setCellValFunc(&c, val)
+	if c.IS != nil {
+		return c.IS.String(), 0, false
+	}
+	if c.T == "" || c.T == "n" {
+		if num, err := strconv.ParseFloat(c.V, 64); err == nil {
+			return c.V, num, true
+		}
+	}
+	return c.V, 0, false
+}
+
+// filterRowAttrs returns the given attributes of the given row, with the hidden attribute if the row is in the range
+// of the autofilter and doesn't match its criteria. The value of a column is returned by the given function.
+func (dw *DirectWriter) filterRowAttrs(row int, attrs string, value func(col int) interface{}) string {
+	if dw.autoFilter == nil || row < dw.autoFilter.firstRow || row > dw.autoFilter.lastRow ||
+		strings.Contains(attrs, ` hidden="true"`) {
+		return attrs
+	}
+	for i := range dw.autoFilter.columns {
+		fc := &dw.autoFilter.columns[i]
+		if s, num, isNum := filterCellValue(value(fc.col)); !fc.match(s, num, isNum) {
+			return attrs + ` hidden="true"`
+		}
+	}
+	return attrs
+}
+
+// cellValue returns a function which returns the value of the given column of the given cells, to be passed to
+// filterRowAttrs.
+func cellValue(values []Cell) func(col int) interface{} {
+	return func(col int) interface{} {
+		if col > len(values) {
+			return nil
+		}
+		return values[col-1].Value
+	}
+}
+
+// AddComment provides a function to add a comment to the given cell of the DirectWriter. The author and text of the
+// comment default to the same values as for File.AddComment. The comments are buffered and the legacy drawing of the
+// worksheet is written when the writer is closed, so comments may be added to rows which have already been flushed.
+// The comments and VML drawing parts are generated when the workbook is finalized by File.WriteTo.
+func (dw *DirectWriter) AddComment(cell string, comment Comment) error {
+	if dw.closed {
+		return ErrDirectWriterClosed
+	}
+	if _, _, err := CellNameToCoordinates(cell); err != nil {
+		return err
+	}
+	if comment.Author == "" {
+		comment.Author = "Author:"
+	}
+	if comment.Text == "" {
+		comment.Text = " "
+	}
+	comment.Ref = cell
+	dw.comments = append(dw.comments, comment)
+	return nil
+}
+
+// AddPicture provides a function to add a picture anchored to the given cell of the DirectWriter by given picture file
+// path and format set, see File.AddPicture for the supported format set, such as `{"x_scale": 0.5, "y_scale": 0.5}`.
+func (dw *DirectWriter) AddPicture(cell, picture, format string) error {
+	if dw.closed {
+		return ErrDirectWriterClosed
+	}
+	if _, err := os.Stat(picture); os.IsNotExist(err) {
+		return err
 	}
-	var err error
-	dw.worksheet, err = f.workSheetReader(sheet)
+	ext, ok := supportImageTypes[path.Ext(picture)]
+	if !ok {
+		return ErrImgExt
+	}
+	file, err := ioutil.ReadFile(filepath.Clean(picture))
 	if err != nil {
-		return nil, err
+		return err
 	}
+	_, name := filepath.Split(picture)
+	return dw.AddPictureFromBytes(cell, format, name, ext, file)
+}
 
-	dw.sheetPath = f.sheetMap[trimSheetName(sheet)]
-	f.directWriters = append(f.directWriters, dw)
-
-	return dw, err
+// AddPictureFromBytes provides a function to add a picture anchored to the given cell of the DirectWriter by given
+// format set, file base name, extension name and file bytes, like File.AddPictureFromBytes, such as a thumbnail per
+// row of a product catalog. The pictures are buffered and the drawing of the worksheet is written when the writer is
+// closed, so pictures may be added to rows which have already been flushed. The drawing and media parts are generated
+// when the workbook is finalized by File.WriteTo. Since the rows are not kept, the size of a picture in cells is
+// computed with the default column width and row height of the worksheet.
+func (dw *DirectWriter) AddPictureFromBytes(cell, format, name, extension string, file []byte) error {
+	if dw.closed {
+		return ErrDirectWriterClosed
+	}
+	if _, _, err := CellNameToCoordinates(cell); err != nil {
+		return err
+	}
+	if _, ok := supportImageTypes[extension]; !ok {
+		return ErrImgExt
+	}
+	if _, err := parseFormatPictureSet(format); err != nil {
+		return err
+	}
+	if _, _, err := image.DecodeConfig(bytes.NewReader(file)); err != nil {
+		return err
+	}
+	dw.pictures = append(dw.pictures, directPicture{cell: cell, format: format, name: name, ext: extension, file: file})
+	return nil
 }
 
-// SetWait enables or disables the wait mode. In wait mode nothing is flushed to writer (if any), even if the buffer grows beyond maxBufferSize.
-func (dw *DirectWriter) SetWait(b bool) error {
-	if b {
-		if dw.bytesWritten > 0 {
-			return errors.New("Can't enable wait mode since first data already written.")
-		}
-		dw.waitMode = true
+// addPictures adds the buffered pictures to the drawing of the worksheet, and sets the drawing of the worksheet. The
+// pictures of the direct writers are added one writer at a time, since the drawing parts are shared by the workbook.
+func (dw *DirectWriter) addPictures() error {
+	if len(dw.pictures) == 0 {
 		return nil
 	}
-	dw.waitMode = false
+	dw.File.directDrawings.Lock()
+	defer dw.File.directDrawings.Unlock()
+	for _, p := range dw.pictures {
+		if err := dw.File.AddPictureFromBytes(dw.Sheet, p.cell, p.format, p.name, p.ext, p.file); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
-// AddRow is used for streaming a large data file row by row, without any gaps.
-// It omits  cell reference values and only accept []Cell to reduce interface{} related allocations.
-// It returns the number of bytes currently in the write buffer.
-func (dw *DirectWriter) AddRow(values []Cell, opts ...RowOpts) (buffered int, err error) {
-	dw.rowCount++
-	dw.buf = append(dw.buf, `<row r="`...)
-	dw.buf = strconv.AppendInt(dw.buf, int64(dw.rowCount), 10)
-	dw.buf = append(dw.buf, '"')
-	if len(opts) > 0 {
-		attrs, err := marshalRowAttrs(opts...)
-		if err != nil {
-			return len(dw.buf), err
-		}
-		dw.buf = append(dw.buf, attrs...)
+// addLegacyDrawing reserves the comments and VML drawing parts of the buffered comments, and sets the legacy drawing
+// of the worksheet. As File.AddComment, the parts of existing comments of the worksheet are reused.
+func (dw *DirectWriter) addLegacyDrawing() {
+	if len(dw.comments) == 0 {
+		return
 	}
-	dw.buf = append(dw.buf, '>')
-	if len(values) > len(dw.maxColLengths) {
-		l := make([]int, len(values))
-		copy(l, dw.maxColLengths)
-		dw.maxColLengths = l
+	f := dw.File
+	f.Lock()
+	defer f.Unlock()
+	if dw.worksheet.LegacyDrawing != nil {
+		target := f.getSheetRelationshipsTargetByID(dw.Sheet, dw.worksheet.LegacyDrawing.RID)
+		dw.commentID, _ = strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(target, "../drawings/vmlDrawing"), ".vml"))
+		return
 	}
-	for i, val := range values {
-		c := xlsxC{
-			S: val.StyleID,
-		}
-		if val.Formula != "" {
-			c.F = &xlsxF{Content: val.Formula}
-		}
-		if err := setCellValFunc(&c, val.Value); err != nil {
-			dw.buf = append(dw.buf, "</row>"...)
-			return len(dw.buf), err
-		}
-		if l := len(c.V); l > dw.maxColLengths[i] {
-			dw.maxColLengths[i] = l
+	dw.commentID = f.countComments() + 1
+	for _, d := range f.directWriters {
+		if d.commentID >= dw.commentID && d != dw {
+			dw.commentID = d.commentID + 1
 		}
-		dw.buf = appendCellNoRef(dw.buf, c)
 	}
-	dw.buf = append(dw.buf, "</row>"...)
-	if len(dw.buf) > dw.maxBufferSize && !dw.waitMode {
-		err := dw.tryFlush()
-		return len(dw.buf), err
-	}
-	return len(dw.buf), nil
+	sheetRels := "xl/worksheets/_rels/" + strings.TrimPrefix(dw.sheetPath, "xl/worksheets/") + ".rels"
+	rID := f.addRels(sheetRels, SourceRelationshipDrawingVML, "../drawings/vmlDrawing"+strconv.Itoa(dw.commentID)+".vml", "")
+	f.addRels(sheetRels, SourceRelationshipComments, "../comments"+strconv.Itoa(dw.commentID)+".xml", "")
+	dw.worksheet.LegacyDrawing = &xlsxLegacyDrawing{RID: "rId" + strconv.Itoa(rID)}
 }
 
-// MaxColumnLengths returns the max lengths (in bytes as written to XML) for each column written so far.
-func (dw *DirectWriter) MaxColumnLengths() []int {
-	return dw.maxColLengths
+// writeComments adds the buffered comments to the comments and VML drawing parts reserved when the writer was closed.
+func (dw *DirectWriter) writeComments() {
+	if dw.commentID == 0 {
+		return
+	}
+	drawingVML := "xl/drawings/vmlDrawing" + strconv.Itoa(dw.commentID) + ".vml"
+	for _, c := range dw.comments {
+		_ = dw.File.addCommentParts(dw.commentID, drawingVML, c.Ref, &formatComment{Author: c.Author, Text: c.Text})
+	}
 }
 
-// SetColWidth provides a function to set the width of a single column or
-// multiple columns for the DirectWriter. Since column definitions need to be written before sheet data, either use this
-// function before the first call to AddRow, or set the writer in wait mode using SetWait.
-func (dw *DirectWriter) SetColWidth(min, max int, width float64) error {
-	if dw.bytesWritten > 0 {
-		return errors.New("Can't set col width since first data already written.")
+// Close ends the streaming writing process. After Close, the other methods of the DirectWriter return
+// ErrDirectWriterClosed.
+func (dw *DirectWriter) Close() error {
+	if dw.closed {
+		return ErrDirectWriterClosed
 	}
-	if min > TotalColumns || max > TotalColumns {
-		return ErrColumnNumber
+	dw.Lock()
+	dw.closed = true
+	dw.Unlock()
+	dw.stopFlushInterval()
+	if err := dw.finish(); err != nil {
+		return err
 	}
-	if min < 1 || max < 1 {
-		return ErrColumnNumber
+	dw.releaseBuf()
+	dw.closeDone()
+	return nil
+}
+
+// finish writes the end of the worksheet, flushes it and removes the worksheet from the File. If the context of the
+// DirectWriter is done or the underlying writer fails, the done channel is closed and the error is returned.
+func (dw *DirectWriter) finish() error {
+	dw.prepareHeader()
+	if err := dw.ctx.Err(); err != nil {
+		_ = dw.stopAsyncFlush()
+		dw.closeDone()
+		return err
 	}
-	if width > MaxColumnWidth {
-		return ErrColumnWidth
+	for _, h := range dw.hyperlinks {
+		linkType, opts, _ := h.link.options()
+		if err := dw.File.SetCellHyperLink(dw.Sheet, h.cell, h.link.Link, linkType, opts); err != nil {
+			_ = dw.stopAsyncFlush()
+			dw.closeDone()
+			return err
+		}
 	}
-	if min > max {
-		min, max = max, min
+	if err := dw.addPictures(); err != nil {
+		_ = dw.stopAsyncFlush()
+		dw.closeDone()
+		return err
+	}
+	dw.addLegacyDrawing()
+	dw.Lock()
+	dw.appendFooter()
+	dw.Unlock()
+
+	if err := dw.flushFinished(); err != nil {
+		return err
 	}
-	dw.cols += fmt.Sprintf(`<col min="%d" max="%d" width="%f" customWidth="1"/>`, min, max, width)
+
+	dw.File.Lock()
+	dw.File.Sheet.Delete(dw.sheetPath)
+	delete(dw.File.checked, dw.sheetPath)
+	dw.File.Pkg.Delete(dw.sheetPath)
+	dw.File.Unlock()
 	return nil
 }
 
-// Close ends the streaming writing process.
-func (dw *DirectWriter) Close() error {
+// flushFinished writes the rest of the finished worksheet to the attached writer, if any, and waits until the
+// asynchronous flushing is done. The waiting File.WriteTo is released if it fails.
+func (dw *DirectWriter) flushFinished() error {
+	err := dw.tryFlush()
+	if stopErr := dw.stopAsyncFlush(); err == nil {
+		err = stopErr
+	}
+	if err == nil {
+		err = dw.flushOut()
+	}
+	if err != nil {
+		dw.closeDone()
+	}
+	return err
+}
+
+// prepareHeader sets the dimension and the row outline level of the worksheet, if the header is not written yet.
+func (dw *DirectWriter) prepareHeader() {
+	dw.Lock()
+	defer dw.Unlock()
+	if !dw.dimensionSet && dw.bytesWritten == 0 && dw.rowCount > 0 && len(dw.maxColLengths) > 0 {
+		cell, _ := CoordinatesToCellName(len(dw.maxColLengths), dw.rowCount)
+		dw.worksheet.Dimension = &xlsxDimension{Ref: "A1:" + cell}
+	}
+	if dw.bytesWritten == 0 && dw.outlineLevel > 0 {
+		if dw.worksheet.SheetFormatPr == nil {
+			dw.worksheet.SheetFormatPr = &xlsxSheetFormatPr{DefaultRowHeight: defaultRowHeight}
+		}
+		if dw.worksheet.SheetFormatPr.OutlineLevelRow < dw.outlineLevel {
+			dw.worksheet.SheetFormatPr.OutlineLevelRow = dw.outlineLevel
+		}
+	}
+}
+
+// appendFooter appends the end of the sheet data, the merged cells and the other elements after the sheet data to the
+// buffer. The caller must hold the lock of the DirectWriter.
+func (dw *DirectWriter) appendFooter() {
 	dw.buf = append(dw.buf, `</sheetData>`...)
 	bulkAppendFields(dw, dw.worksheet, 8, 15)
+	if preserved := dw.preservedMergeCells(); len(dw.mergeRects)+len(preserved) > 0 {
+		dw.buf = append(dw.buf, `<mergeCells count="`...)
+		dw.buf = strconv.AppendInt(dw.buf, int64(len(dw.mergeRects)+len(preserved)), 10)
+		dw.buf = append(dw.buf, `">`...)
+		for _, mc := range preserved {
+			dw.buf = append(dw.buf, `<mergeCell ref="`...)
+			dw.buf = appendEscapedString(dw.buf, mc.Ref, false)
+			dw.buf = append(dw.buf, `"/>`...)
+		}
+		dw.buf = append(dw.buf, dw.mergeCells...)
+		dw.buf = append(dw.buf, `</mergeCells>`...)
+	}
 	bulkAppendFields(dw, dw.worksheet, 17, 38)
 	bulkAppendFields(dw, dw.worksheet, 40, 40)
 	dw.buf = append(dw.buf, `</worksheet>`...)
+}
 
-	if err := dw.tryFlush(); err != nil {
+// EstimateWorksheetSize returns an approximate size in bytes of the worksheet XML written by the DirectWriter for the
+// given number of rows and columns, where avgCellBytes is the average length of the escaped cell values. The cells
+// are counted with the same overhead as written by AddRow, without style, type or formula, so add their attributes to
+// avgCellBytes when most cells have them, such as 8 bytes for the ` t="str"` of the string cells. It is a heuristic
+// for buffer sizes or transfer hints: the size is of the uncompressed XML, before the compression in the xlsx
+// archive, and doesn't include the other parts of the workbook.
+func EstimateWorksheetSize(rows, cols int, avgCellBytes int) int64 {
+	size := int64(len(XMLHeader) + len(`<worksheet`+templateNamespaceIDMap) + directEstimateFixedBytes)
+	if rows <= 0 || cols <= 0 {
+		return size
+	}
+	// the row numbers, with the number of digits of each row
+	for digits, first := 1, 1; first <= rows; digits, first = digits+1, first*10 {
+		last := first*10 - 1
+		if last > rows {
+			last = rows
+		}
+		size += int64(last-first+1) * int64(digits+len(`<row r=""></row>`))
+	}
+	size += int64(rows) * int64(cols) * int64(len(`<c><v></v></c>`)+avgCellBytes)
+	return size
+}
+
+// directEstimateFixedBytes is the size of the default elements of a new worksheet around the sheet data, such as the
+// dimension, the sheet view and the sheet format properties.
+const directEstimateFixedBytes = 210
+
+// Rotate finalizes the current worksheet stream and begins a fresh worksheet stream, so that a huge export can be split
+// into several outputs at row boundaries. Each stream is a standalone worksheet XML part, with the settings of the
+// worksheet, such as the column widths and the page layout, which are kept for the next stream. The rows, merged cells
+// and dimension of the next stream start over from the first row. The writers given to WriteTo and Rotate receive the
+// streams in order: if the writer is not attached to the writer of WriteTo or File.WriteTo, the rows added since the
+// writer was created or last rotated are written to w, and the next stream is written by the next call to Rotate, or
+// by WriteTo or File.WriteTo after the writer is closed. Otherwise the current stream is finished on the attached
+// writer, which is released, and the next stream is streamed to w until the next call to Rotate or Close. The streams
+// are independent parts which don't share any relationships, so the writer can't be rotated after adding comments or
+// hyperlinks.
+func (dw *DirectWriter) Rotate(w io.Writer) error {
+	if dw.closed {
+		return ErrDirectWriterClosed
+	}
+	if len(dw.comments) > 0 || len(dw.hyperlinks) > 0 || len(dw.pictures) > 0 {
+		return errors.New("Can't rotate since comments, hyperlinks or pictures already added.")
+	}
+	dw.prepareHeader()
+	dw.Lock()
+	if dw.out != nil {
+		dw.Unlock()
+		return dw.rotateAttached(w)
+	}
+	defer dw.Unlock()
+	n := len(dw.buf)
+	dw.appendFooter()
+	if _, err := w.Write(dw.buildHeader()); err != nil {
+		dw.buf = dw.buf[:n]
+		return err
+	}
+	if _, err := w.Write(dw.buf); err != nil {
+		dw.buf = dw.buf[:n]
 		return err
 	}
+	dw.buf = dw.buf[:0]
+	dw.resetStream()
+	return nil
+}
 
-	dw.File.Sheet.Delete(dw.sheetPath)
-	delete(dw.File.checked, dw.sheetPath)
-	dw.File.Pkg.Delete(dw.sheetPath)
+// rotateAttached finishes the current worksheet stream on the attached writer, releases the waiting WriteTo or
+// File.WriteTo, and attaches w for the next stream.
+func (dw *DirectWriter) rotateAttached(w io.Writer) error {
+	dw.Lock()
+	dw.appendFooter()
+	dw.Unlock()
+	if err := dw.flushFinished(); err != nil {
+		return err
+	}
+	dw.Lock()
+	defer dw.Unlock()
+	dw.rotatedBytes = dw.bytesWritten
+	dw.closeDone()
+	dw.done, dw.doneOnce = make(chan bool), sync.Once{}
+	dw.bytesWritten = 0
+	dw.resetStream()
+	dw.attach(w)
+	return nil
+}
+
+// resetStream starts over the rows, merged cells and dimension of the worksheet for the next stream of Rotate. The
+// caller must hold the lock.
+func (dw *DirectWriter) resetStream() {
+	dw.rowCount, dw.rowOffset, dw.maxColLengths, dw.mergeCells, dw.mergeRects = 0, 0, dw.maxColLengths[:0], "", nil
+	if !dw.dimensionSet {
+		dw.worksheet.Dimension = nil
+	}
+}
+
+// Finalize closes the DirectWriter and returns the complete standalone worksheet XML, with the header, the rows and
+// the footer, instead of writing it to the workbook of the File, so that it can be transported and added to another
+// workbook by File.AddRawWorksheet, such as for generating the worksheets of a workbook on different machines. The
+// writer is unregistered from the File, which writes the worksheet as it was before the writer was created. Like
+// Rotate, the worksheet XML doesn't have relationships, so it can't be finalized after adding comments, hyperlinks or
+// pictures, nor once the writer is attached to the writer of WriteTo or File.WriteTo, nor while File.WriteTo is
+// running.
+func (dw *DirectWriter) Finalize() ([]byte, error) {
+	if dw.closed {
+		return nil, ErrDirectWriterClosed
+	}
+	if len(dw.comments) > 0 || len(dw.hyperlinks) > 0 || len(dw.pictures) > 0 {
+		return nil, errors.New("Can't finalize since comments, hyperlinks or pictures already added.")
+	}
+	dw.prepareHeader()
+	f := dw.File
+	f.Lock()
+	dw.Lock()
+	if f.directWriting {
+		dw.Unlock()
+		f.Unlock()
+		return nil, errors.New("Can't finalize since the workbook is being written.")
+	}
+	if dw.out != nil || dw.bytesWritten > 0 {
+		dw.Unlock()
+		f.Unlock()
+		return nil, errors.New("Can't finalize since the writer is already registered.")
+	}
+	dw.appendFooter()
+	part := append(dw.buildHeader(), dw.buf...)
+	dw.buf, dw.closed = nil, true
+	dw.Unlock()
+	f.removeDirectWriter(dw)
+	dw.setFilterDatabase()
+	f.Unlock()
+	dw.stopFlushInterval()
+	dw.closeDone()
+	return part, nil
+}
+
+// NextSheet ends the writing of the current worksheet like Close, and continues the writing with the given sheet. If
+// the sheet doesn't yet exists it is created. The settings of the writer, such as the wait mode, the inline strings
+// and the flush interval, are kept, and once the current worksheet is flushed its buffer is reused for the next one,
+// so that many small worksheets can be written without the allocations of a new DirectWriter for each sheet. The
+// settings of the current worksheet, such as the column widths, the merged cells and the comments, are not carried
+// over. The worksheets are written in order by File.WriteTo, which may be running while the writer moves on.
+func (dw *DirectWriter) NextSheet(sheet string) error {
+	if dw.closed {
+		return ErrDirectWriterClosed
+	}
+	f := dw.File
+	// the sheet is created under the lock of the File, since File.WriteTo may be marshaling the worksheets meanwhile
+	f.Lock()
+	if path, ok := f.sheetMap[trimSheetName(sheet)]; ok && f.hasDirectWriter(path) {
+		f.Unlock()
+		return ErrDirectWriterSheet
+	}
+	_ = f.NewSheet(sheet)
+	sheetID := f.getSheetID(sheet)
+	f.Unlock()
+	if sheetID == -1 {
+		return errors.New("bug: sheetID not found after call to NewSheet")
+	}
+	ws, err := f.workSheetReader(sheet)
+	if err != nil {
+		return err
+	}
+	if err = dw.finish(); err == nil {
+		err = dw.switchSheet(sheet, sheetID, ws)
+	}
+	if err != nil {
+		dw.Lock()
+		dw.closed = true
+		dw.Unlock()
+		dw.stopFlushInterval()
+	}
+	return err
+}
+
+// switchSheet replaces the finished worksheet of the DirectWriter by a closed writer, which releases the waiting
+// File.WriteTo, and continues the writing with the given sheet.
+func (dw *DirectWriter) switchSheet(sheet string, sheetID int, ws *xlsxWorksheet) error {
+	f := dw.File
+	f.Lock()
+	dw.Lock()
+	if dw.out != nil && dw.bytesWritten == 0 {
+		// File.WriteTo attached after the worksheet was finished, so the buffered worksheet is written before the
+		// writer moves on
+		dw.Unlock()
+		f.Unlock()
+		if err := dw.flushFinished(); err != nil {
+			return err
+		}
+		f.Lock()
+		dw.Lock()
+	}
+	prev := &DirectWriter{
+		File:          f,
+		Sheet:         dw.Sheet,
+		SheetID:       dw.SheetID,
+		cols:          dw.cols,
+		worksheet:     dw.worksheet,
+		sheetPath:     dw.sheetPath,
+		maxBufferSize: dw.maxBufferSize,
+		bytesWritten:  dw.bytesWritten,
+		out:           dw.out,
+		ctx:           dw.ctx,
+		done:          dw.done,
+		closed:        true,
+		sheetState:    dw.sheetState,
+		printArea:     dw.printArea,
+		filterRef:     dw.filterRef,
+		rootAttrs:     dw.rootAttrs,
+		preserveSheet: dw.preserveSheet,
+		comments:      dw.comments,
+		commentID:     dw.commentID,
+		onFlush:       dw.onFlush,
+		writeErr:      dw.writeErr,
+	}
+	if dw.out == nil {
+		// the worksheet is buffered until it is written by File.WriteTo
+		prev.buf, dw.buf = dw.buf, nil
+	}
+	for i, d := range f.directWriters {
+		if d == dw {
+			f.directWriters[i] = prev
+		}
+	}
+	f.directWriters = append(f.directWriters, dw)
+	dw.Sheet, dw.SheetID, dw.sheetPath, dw.worksheet = sheet, sheetID, f.sheetMap[trimSheetName(sheet)], ws
+	dw.cols, dw.out, dw.bufOut, dw.bytesWritten, dw.buf = "", nil, nil, 0, dw.buf[:0]
+	dw.writeErr = nil
+	dw.done, dw.doneOnce = make(chan bool), sync.Once{}
+	dw.rowCount, dw.rowOffset, dw.maxColLengths, dw.outlineLevel = 0, 0, dw.maxColLengths[:0], 0
+	dw.mergeCells, dw.mergeRects, dw.dimensionSet, dw.autoFilter = "", nil, false, nil
+	dw.sheetState, dw.printArea, dw.filterRef, dw.preserveSheet, dw.rootAttrs = "", "", "", false, ""
+	dw.comments, dw.commentID, dw.hyperlinks, dw.pictures = nil, 0, nil, nil
+	dw.Unlock()
+	f.Unlock()
 
-	close(dw.done)
+	prev.closeDone()
 	return nil
 }
 
-// WriteTo writes the output of the DirectWriter to w. The call will block until the DirectWriter is closed by a call to Close.
+// preservedMergeCells returns the merged cells of the existing worksheet in preserve mode.
+func (dw *DirectWriter) preservedMergeCells() []*xlsxMergeCell {
+	if !dw.preserveSheet || dw.worksheet.MergeCells == nil {
+		return nil
+	}
+	return dw.worksheet.MergeCells.Cells
+}
+
+// closeDone closes the done channel, it is safe to be called multiple times.
+func (dw *DirectWriter) closeDone() {
+	dw.doneOnce.Do(func() { close(dw.done) })
+}
+
+// WriteTo writes the output of the DirectWriter to w. The call will block until the DirectWriter is closed by a call to
+// Close, or until the context of the DirectWriter is done.
 func (dw *DirectWriter) WriteTo(w io.Writer) (int64, error) {
+	if err := dw.ctx.Err(); err != nil {
+		dw.closeDone()
+		return 0, err
+	}
 	select {
 	case <-dw.done:
 		if dw.bytesWritten > 0 {
 			return 0, errors.New("Cant't write to new writer w since part of the data already been written and flushed.")
 		}
-		n, err := w.Write(dw.buildHeader())
+		header := dw.buildHeader()
+		n, err := w.Write(header)
 		if err != nil {
 			return int64(n), err
 		}
+		if dw.onFlush != nil {
+			dw.onFlush(header, int64(n))
+		}
 		n2, err := w.Write(dw.buf)
+		if err == nil && dw.onFlush != nil && n2 > 0 {
+			dw.onFlush(dw.buf, int64(n+n2))
+		}
 		return int64(n + n2), err
 	default:
 		dw.Lock()
-		dw.out = w
+		dw.attach(w)
+		done := dw.done
 		dw.Unlock()
-		<-dw.done
-		return dw.bytesWritten, nil
+		var err error
+		select {
+		case <-done:
+		case <-dw.ctx.Done():
+			dw.closeDone()
+			err = dw.ctx.Err()
+		}
+		dw.RLock()
+		defer dw.RUnlock()
+		if err == nil {
+			err = dw.writeErr
+		}
+		if done != dw.done {
+			// the writer is rotated to the next stream
+			return dw.rotatedBytes, err
+		}
+		return dw.bytesWritten, err
 	}
 }
 
@@ -202,41 +2897,147 @@ func (dw *DirectWriter) Write(p []byte) (n int, err error) {
 
 func (dw *DirectWriter) buildHeader() []byte {
 	var header bytes.Buffer
-	header.WriteString(XMLHeader + `<worksheet` + templateNamespaceIDMap)
+	if dw.rootAttrs != "" {
+		header.WriteString(XMLHeader + `<worksheet` + dw.rootAttrs + `>`)
+	} else {
+		header.WriteString(XMLHeader + `<worksheet` + templateNamespaceIDMap)
+	}
 	bulkAppendFields(&header, dw.worksheet, 2, 5)
-	if len(dw.cols) > 0 {
-		header.WriteString("<cols>" + dw.cols + "</cols>")
+	var preserved []xlsxCol
+	if dw.preserveSheet && dw.worksheet.Cols != nil {
+		preserved = dw.worksheet.Cols.Col
+	}
+	if len(dw.cols) > 0 || len(preserved) > 0 {
+		header.WriteString("<cols>")
+		enc := xml.NewEncoder(&header)
+		for _, col := range preserved {
+			_ = enc.EncodeElement(col, xml.StartElement{Name: xml.Name{Local: "col"}})
+		}
+		_ = enc.Flush()
+		header.WriteString(dw.cols + "</cols>")
 	}
 	header.WriteString(`<sheetData>`)
 	return header.Bytes()
 }
 
+// Flush writes the buffered data to the underlying writer regardless of maxBufferSize and wait mode. It is a no-op
+// if no writer is registered yet by WriteTo.
+func (dw *DirectWriter) Flush() error {
+	if dw.closed {
+		return ErrDirectWriterClosed
+	}
+	if err := dw.tryFlush(); err != nil {
+		return err
+	}
+	if err := dw.flushOut(); err != nil {
+		return err
+	}
+	return dw.waitFlushed()
+}
+
 func (dw *DirectWriter) tryFlush() error {
+	dw.RLock()
+	async := dw.asyncChunks != nil
+	dw.RUnlock()
+	if async {
+		return dw.queueFlush()
+	}
 	dw.Lock()
+	defer dw.Unlock()
 	if dw.out == nil {
-		dw.Unlock()
 		return nil
 	}
 	if dw.bytesWritten == 0 {
-		n, err := dw.out.Write(dw.buildHeader())
+		header := dw.buildHeader()
+		n, err := dw.out.Write(header)
 		if err != nil {
-			return err
+			return dw.setWriteErr(err)
 		}
 		dw.bytesWritten += int64(n)
+		if dw.onFlush != nil {
+			dw.onFlush(header, dw.bytesWritten)
+		}
 	}
 	n, err := dw.out.Write(dw.buf)
-	dw.Unlock()
 	if err != nil {
-		return err
+		return dw.setWriteErr(err)
 	}
 	dw.bytesWritten += int64(n)
+	if dw.onFlush != nil && n > 0 {
+		dw.onFlush(dw.buf, dw.bytesWritten)
+	}
 	dw.buf = dw.buf[:0]
 	return nil
 }
 
-func appendCellNoRef(dst []byte, c xlsxC) []byte {
+// EncodeCell appends the XML encoding of the given cell without cell reference to dst and returns the extended
+// buffer, as the cells written by DirectWriter.AddRow, so that custom writers can build worksheet fragments. The value
+// type is inferred like StreamWriter.SetRow, and string values are escaped and written as formula strings, with the
+// xml:space attribute if they have leading or trailing whitespace. NaN and infinite numbers are written as #NUM! error
+// cells. The FormulaOpts, NumFmt and Hyperlink of the cell are not supported.
+func EncodeCell(dst []byte, c Cell) ([]byte, error) {
+	cell := xlsxC{S: c.StyleID}
+	if c.Formula != "" {
+		cell.F = &xlsxF{Content: c.Formula}
+	}
+	if c.RawValue != nil {
+		return appendRawCellNoRef(dst, cell, c.RawValue, false), nil
+	}
+	if err := setCellValFunc(&cell, c.Value); err != nil && err != ErrNonFiniteNumber {
+		return dst, err
+	}
+	return appendCellNoRef(dst, cell, false, ControlCharReplace), nil
+}
+
+func appendCellNoRef(dst []byte, c xlsxC, rawFormula bool, mode ControlCharMode) []byte {
+	dst = appendCellStart(dst, c, rawFormula)
+	if mode == ControlCharEscape && (c.T == "str" || c.T == "inlineStr") && strings.Contains(c.V, "_x") {
+		// the literal escape sequences of the string, escaped by setCellStr,
+		// are escaped with the control characters instead
+		c.V = bstrUnmarshal(c.V)
+	}
+	if c.IS != nil {
+		var is bytes.Buffer
+		_ = xml.NewEncoder(&is).EncodeElement(c.IS, xml.StartElement{Name: xml.Name{Local: "is"}})
+		dst = append(dst, is.Bytes()...)
+		return append(dst, `</c>`...)
+	}
+	if c.T == "inlineStr" {
+		dst = append(dst, `<is><t`...)
+		if c.XMLSpace.Value != "" {
+			dst = append(dst, ` xml:`...)
+			dst = append(dst, c.XMLSpace.Name.Local...)
+			dst = append(dst, `="`...)
+			dst = append(dst, c.XMLSpace.Value...)
+			dst = append(dst, '"')
+		}
+		dst = append(dst, '>')
+		dst = appendEscapedText(dst, c.V, true, mode)
+		dst = append(dst, `</t></is></c>`...)
+		return dst
+	}
+	if c.V != "" {
+		dst = append(dst, `<v>`...)
+		dst = appendEscapedText(dst, c.V, true, mode)
+		dst = append(dst, `</v>`...)
+	}
+	dst = append(dst, `</c>`...)
+	return dst
+}
+
+// appendRawCellNoRef appends a cell with the given pre-formatted value, which is written verbatim without escaping.
+func appendRawCellNoRef(dst []byte, c xlsxC, raw []byte, rawFormula bool) []byte {
+	dst = appendCellStart(dst, c, rawFormula)
+	dst = append(dst, `<v>`...)
+	dst = append(dst, raw...)
+	return append(dst, `</v></c>`...)
+}
+
+// appendCellStart appends the start tag and the formula of the given cell. If rawFormula is true, the formula is
+// written verbatim without escaping.
+func appendCellStart(dst []byte, c xlsxC, rawFormula bool) []byte {
 	dst = append(dst, `<c`...)
-	if c.XMLSpace.Value != "" {
+	if c.XMLSpace.Value != "" && c.T != "inlineStr" {
 		dst = append(dst, ` xml:`...)
 		dst = append(dst, c.XMLSpace.Name.Local...)
 		dst = append(dst, `="`...)
@@ -256,14 +3057,31 @@ func appendCellNoRef(dst []byte, c xlsxC) []byte {
 	dst = append(dst, '>')
 	if c.F != nil {
 		dst = append(dst, `<f>`...)
-		dst = appendEscapedString(dst, c.F.Content, true)
+		if rawFormula {
+			dst = append(dst, c.F.Content...)
+		} else {
+			dst = appendEscapedString(dst, c.F.Content, true)
+		}
 		dst = append(dst, `</f>`...)
 	}
-	if c.V != "" {
-		dst = append(dst, `<v>`...)
-		dst = appendEscapedString(dst, c.V, true)
-		dst = append(dst, `</v>`...)
-	}
-	dst = append(dst, `</c>`...)
 	return dst
 }
+
+// appendNumericCell appends a numeric cell with the given style ID without cell reference to dst, in the same format
+// as EncodeCell for a float64 value, and returns the extended buffer and the length of the written value.
+func appendNumericCell(dst []byte, v float64, s int) ([]byte, int) {
+	dst = append(dst, `<c`...)
+	if s != 0 {
+		dst = append(dst, ` s="`...)
+		dst = strconv.AppendInt(dst, int64(s), 10)
+		dst = append(dst, '"')
+	}
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return append(dst, ` t="e"><v>#NUM!</v></c>`...), len("#NUM!")
+	}
+	dst = append(dst, `><v>`...)
+	n := len(dst)
+	dst = strconv.AppendFloat(dst, v, 'f', -1, 64)
+	n = len(dst) - n
+	return append(dst, `</v></c>`...), n
+}
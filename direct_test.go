@@ -3,9 +3,22 @@ package excelize
 import (
 	"archive/zip"
 	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"math"
+	"math/big"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
 	"strconv"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -14,6 +27,14 @@ import (
 )
 
 func BenchmarkAddRow(b *testing.B) {
+	benchmarkAddRow(b, false)
+}
+
+func BenchmarkAddRowInlineStrings(b *testing.B) {
+	benchmarkAddRow(b, true)
+}
+
+func benchmarkAddRow(b *testing.B, inlineStrings bool) {
 	file := NewFile()
 	row := make([]Cell, 10)
 	for colID := 0; colID < 10; colID++ {
@@ -24,6 +45,256 @@ func BenchmarkAddRow(b *testing.B) {
 	}
 	dw, err := file.NewDirectWriter("Sheet1", 8192)
 	require.NoError(b, err)
+	dw.SetInlineStrings(inlineStrings)
+	go dw.WriteTo(io.Discard) //nolint
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		_, _ = dw.AddRow(row)
+	}
+	err = dw.Close()
+	assert.NoError(b, err)
+	b.SetBytes(dw.bytesWritten)
+	b.ReportAllocs()
+}
+
+func BenchmarkAddRowNumeric(b *testing.B) {
+	vals := []float64{1.5, 2, 3.25, 4, 5.125, 6, 7.5, 8, 9.75, 10}
+	row := make([]Cell, len(vals))
+	for i, v := range vals {
+		row[i] = Cell{StyleID: 1, Value: v}
+	}
+	file := NewFile()
+	dw, err := file.NewDirectWriter("Sheet1", 8192)
+	require.NoError(b, err)
+	go dw.WriteTo(io.Discard) //nolint
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		for i, v := range vals {
+			row[i].Value = v
+		}
+		_, _ = dw.AddRow(row)
+	}
+	assert.NoError(b, dw.Close())
+	b.ReportAllocs()
+}
+
+func BenchmarkAddNumericRow(b *testing.B) {
+	vals := []float64{1.5, 2, 3.25, 4, 5.125, 6, 7.5, 8, 9.75, 10}
+	styleIDs := []int{1, 1, 1, 1, 1, 1, 1, 1, 1, 1}
+	file := NewFile()
+	dw, err := file.NewDirectWriter("Sheet1", 8192)
+	require.NoError(b, err)
+	go dw.WriteTo(io.Discard) //nolint
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		_, _ = dw.AddNumericRow(vals, styleIDs)
+	}
+	assert.NoError(b, dw.Close())
+	b.ReportAllocs()
+}
+
+// BenchmarkDirectWriterOutputBuffer reports the number of writes to a file of
+// an export of 100k rows, with and without the output buffer.
+func BenchmarkDirectWriterOutputBuffer(b *testing.B) {
+	rows := benchmarkRows(100000)
+	for _, size := range []int{0, defaultOutputBufferSize} {
+		b.Run(fmt.Sprintf("size=%d", size), func(b *testing.B) {
+			devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+			require.NoError(b, err)
+			defer devNull.Close()
+			out := &countingFile{File: devNull}
+			for n := 0; n < b.N; n++ {
+				file := NewFile()
+				dw, err := file.NewDirectWriter("Sheet1", 8192)
+				require.NoError(b, err)
+				require.NoError(b, dw.SetOutputBuffer(size))
+				go dw.WriteTo(out) //nolint
+				waitDirectWriterOut(dw)
+				for _, row := range rows {
+					_, _ = dw.AddRow(row)
+				}
+				require.NoError(b, dw.Close())
+			}
+			b.ReportMetric(float64(out.writes)/float64(b.N), "writes/op")
+		})
+	}
+}
+
+func BenchmarkAddRow10k(b *testing.B) {
+	rows := benchmarkRows(10000)
+	for n := 0; n < b.N; n++ {
+		file := NewFile()
+		dw, err := file.NewDirectWriter("Sheet1", 8192)
+		require.NoError(b, err)
+		go dw.WriteTo(io.Discard) //nolint
+		for _, row := range rows {
+			_, _ = dw.AddRow(row)
+		}
+		assert.NoError(b, dw.Close())
+	}
+	b.ReportAllocs()
+}
+
+func BenchmarkAddRows10k(b *testing.B) {
+	rows := benchmarkRows(10000)
+	for n := 0; n < b.N; n++ {
+		file := NewFile()
+		dw, err := file.NewDirectWriter("Sheet1", 8192)
+		require.NoError(b, err)
+		go dw.WriteTo(io.Discard) //nolint
+		for i := 0; i < len(rows); i += 100 {
+			_, _ = dw.AddRows(rows[i : i+100])
+		}
+		assert.NoError(b, dw.Close())
+	}
+	b.ReportAllocs()
+}
+
+func BenchmarkNextSheet1k(b *testing.B) {
+	row := []Cell{{Value: "foo"}, {Value: 1}}
+	for n := 0; n < b.N; n++ {
+		file := NewFile()
+		dw, err := file.NewDirectWriter("Sheet1", 8192)
+		require.NoError(b, err)
+		for i := 2; i <= 1000; i++ {
+			_, _ = dw.AddRow(row)
+			require.NoError(b, dw.NextSheet("Sheet"+strconv.Itoa(i)))
+		}
+		_, _ = dw.AddRow(row)
+		require.NoError(b, dw.Close())
+		_, err = file.WriteTo(io.Discard)
+		require.NoError(b, err)
+	}
+	b.ReportAllocs()
+}
+
+func BenchmarkNewDirectWriter1k(b *testing.B) {
+	row := []Cell{{Value: "foo"}, {Value: 1}}
+	for n := 0; n < b.N; n++ {
+		file := NewFile()
+		for i := 1; i <= 1000; i++ {
+			dw, err := file.NewDirectWriter("Sheet"+strconv.Itoa(i), 8192)
+			require.NoError(b, err)
+			_, _ = dw.AddRow(row)
+			require.NoError(b, dw.Close())
+		}
+		_, err := file.WriteTo(io.Discard)
+		require.NoError(b, err)
+	}
+	b.ReportAllocs()
+}
+
+func BenchmarkDirectWriterBufPool(b *testing.B) {
+	b.Run("pool", func(b *testing.B) {
+		benchmarkShortLivedDirectWriters(b, false)
+	})
+	b.Run("no-pool", func(b *testing.B) {
+		benchmarkShortLivedDirectWriters(b, true)
+	})
+}
+
+// benchmarkShortLivedDirectWriters adds 10k short-lived writers, each writing 1000 rows.
+func benchmarkShortLivedDirectWriters(b *testing.B, noPool bool) {
+	rows := benchmarkRows(1000)
+	pool := directWriterBufPool
+	defer func() { directWriterBufPool = pool }()
+	for n := 0; n < b.N; n++ {
+		file := NewFile()
+		for i := 0; i < 10000; i++ {
+			if noPool {
+				directWriterBufPool = new(sync.Pool)
+			}
+			dw, err := file.NewDirectWriter("Sheet1", 1<<16)
+			require.NoError(b, err)
+			go dw.WriteTo(io.Discard) //nolint
+			waitDirectWriterOut(dw)
+			_, _ = dw.AddRows(rows)
+			require.NoError(b, dw.Close())
+		}
+	}
+	b.ReportAllocs()
+}
+
+// BenchmarkDirectWriterSharedStrings reports the size of a workbook of 100k rows with a string column whose values are
+// 90% repeated, written with inline strings and with shared strings.
+func BenchmarkDirectWriterSharedStrings(b *testing.B) {
+	rows := make([][]Cell, 100000)
+	for i := range rows {
+		value := "Category " + strconv.Itoa(i%10)
+		if i%10 == 0 {
+			value = "Unique value " + strconv.Itoa(i)
+		}
+		rows[i] = []Cell{{Value: value}}
+	}
+	for _, shared := range []bool{false, true} {
+		b.Run(fmt.Sprintf("shared-%v", shared), func(b *testing.B) {
+			var size int
+			for n := 0; n < b.N; n++ {
+				file := NewFile()
+				dw, err := file.NewDirectWriter("Sheet1", 1<<16)
+				require.NoError(b, err)
+				dw.SetSharedStrings(shared)
+				_, err = dw.AddRows(rows)
+				require.NoError(b, err)
+				require.NoError(b, dw.Close())
+				var out bytes.Buffer
+				_, err = file.WriteTo(&out)
+				require.NoError(b, err)
+				size = out.Len()
+			}
+			b.ReportMetric(float64(size), "bytes/file")
+		})
+	}
+}
+
+// benchmarkRows returns the given number of rows with 10 integer cells.
+func benchmarkRows(n int) [][]Cell {
+	rows := make([][]Cell, n)
+	for i := range rows {
+		rows[i] = make([]Cell, 10)
+		for colID := range rows[i] {
+			rows[i][colID] = Cell{Value: i * colID}
+		}
+	}
+	return rows
+}
+
+func BenchmarkAddRowInt(b *testing.B) {
+	row := make([]Cell, 10)
+	for colID := 0; colID < 10; colID++ {
+		row[colID] = Cell{Value: 1234567 * colID}
+	}
+	benchmarkAddRowCells(b, row)
+}
+
+func BenchmarkAddRowRawValue(b *testing.B) {
+	row := make([]Cell, 10)
+	for colID := 0; colID < 10; colID++ {
+		row[colID] = Cell{RawValue: strconv.AppendInt(nil, int64(1234567*colID), 10)}
+	}
+	benchmarkAddRowCells(b, row)
+}
+
+func BenchmarkAddRowCleanString(b *testing.B) {
+	row := make([]Cell, 10)
+	for colID := 0; colID < 10; colID++ {
+		row[colID] = Cell{Value: "Product name " + strconv.Itoa(colID) + ", size M, color blue"}
+	}
+	benchmarkAddRowCells(b, row)
+}
+
+func BenchmarkAddRowDirtyString(b *testing.B) {
+	row := make([]Cell, 10)
+	for colID := 0; colID < 10; colID++ {
+		row[colID] = Cell{Value: "Product <name> " + strconv.Itoa(colID) + " & size \"M\", color blue"}
+	}
+	benchmarkAddRowCells(b, row)
+}
+
+func benchmarkAddRowCells(b *testing.B, row []Cell) {
+	file := NewFile()
+	dw, err := file.NewDirectWriter("Sheet1", 8192)
+	require.NoError(b, err)
 	go dw.WriteTo(io.Discard) //nolint
 	b.ResetTimer()
 	for n := 0; n < b.N; n++ {
@@ -43,7 +314,7 @@ func TestDirectWriter(t *testing.T) {
 		require.NoError(t, err)
 
 		require.NoError(t, dw.SetColWidth(1, 2, 20))
-		expectedCols := `<cols><col min="1" max="2" width="20.000000" customWidth="1"/></cols>`
+		expectedCols := `<cols><col min="1" max="2" width="20" customWidth="1"/></cols>`
 
 		_, err = dw.AddRow(row)
 		assert.NoError(t, err)
@@ -141,15 +412,7 @@ func TestDirectWriter(t *testing.T) {
 		require.NoError(t, dw.SetWait(true))
 
 		go dw.WriteTo(io.Discard) //nolint
-		// loop waiting for the goroutine to launch and register the writer
-		for {
-			dw.Lock()
-			w := dw.out
-			dw.Unlock()
-			if w != nil {
-				break
-			}
-		}
+		waitDirectWriterOut(dw)
 
 		buffered, err := dw.AddRow(row)
 		assert.NoError(t, err)
@@ -161,6 +424,3368 @@ func TestDirectWriter(t *testing.T) {
 		assert.NoError(t, err)
 		assert.Equal(t, 0, buffered, "buffer should have been flushed since wait mode is now disabled")
 	})
+	t.Run("merge-cells", func(t *testing.T) {
+		file, row, _ := setupTestFileRow()
+		dw, err := file.NewDirectWriter("Sheet1", 8192)
+		require.NoError(t, err)
+
+		_, err = dw.AddRow(row)
+		assert.NoError(t, err)
+		assert.NoError(t, dw.MergeCell("A1", "D1"))
+		assert.NoError(t, dw.MergeCell("B3", "A2"))
+		assert.EqualError(t, dw.MergeCell("A", "D1"), `cannot convert cell "A" to coordinates: invalid cell name "A"`)
+		assert.EqualError(t, dw.MergeCell("C1", "C2"), ErrMergeCellOverlap.Error())
+		require.NoError(t, dw.Close())
+
+		var out bytes.Buffer
+		_, err = file.WriteTo(&out)
+		require.NoError(t, err)
+
+		f, err := OpenReader(&out)
+		require.NoError(t, err)
+		assert.Empty(t, f.validateParts())
+		mergeCells, err := f.GetMergeCells("Sheet1")
+		require.NoError(t, err)
+		require.Len(t, mergeCells, 2)
+		assert.Equal(t, "A1", mergeCells[0].GetStartAxis())
+		assert.Equal(t, "D1", mergeCells[0].GetEndAxis())
+		assert.Equal(t, "A2", mergeCells[1].GetStartAxis())
+		assert.Equal(t, "B3", mergeCells[1].GetEndAxis())
+	})
+	t.Run("row-opts", func(t *testing.T) {
+		file, row, _ := setupTestFileRow()
+		dw, err := file.NewDirectWriter("Sheet1", 8192)
+		require.NoError(t, err)
+
+		_, err = dw.AddRow(row, RowOpts{Height: 45})
+		assert.NoError(t, err)
+		_, err = dw.AddRow(row)
+		assert.NoError(t, err)
+		_, err = dw.AddRow(row, RowOpts{Height: 20.5, Hidden: true, OutlineLevel: 2})
+		assert.NoError(t, err)
+		_, err = dw.AddRow(row, RowOpts{Height: MaxRowHeight + 1})
+		assert.EqualError(t, err, ErrMaxRowHeight.Error())
+		_, err = dw.AddRow(row, RowOpts{OutlineLevel: 8})
+		assert.EqualError(t, err, ErrOutlineLevel.Error())
+		require.NoError(t, dw.Close())
+		assert.Contains(t, string(dw.buf), `<row r="1" ht="45" customHeight="1">`)
+
+		var out bytes.Buffer
+		_, err = file.WriteTo(&out)
+		require.NoError(t, err)
+
+		f, err := OpenReader(&out)
+		require.NoError(t, err)
+		assert.Empty(t, f.validateParts())
+		for r, expected := range []float64{45, defaultRowHeight, 20.5} {
+			height, err := f.GetRowHeight("Sheet1", r+1)
+			assert.NoError(t, err)
+			assert.Equal(t, expected, height)
+		}
+		visible, err := f.GetRowVisible("Sheet1", 3)
+		assert.NoError(t, err)
+		assert.False(t, visible)
+		level, err := f.GetRowOutlineLevel("Sheet1", 3)
+		assert.NoError(t, err)
+		assert.Equal(t, uint8(2), level)
+	})
+	t.Run("row-groups", func(t *testing.T) {
+		file, row, _ := setupTestFileRow()
+		dw, err := file.NewDirectWriter("Sheet1", 8192)
+		require.NoError(t, err)
+		_, err = dw.AddRow(row)
+		assert.NoError(t, err)
+		_, err = dw.AddRows([][]Cell{row, row, row, row}, RowOpts{Hidden: true, OutlineLevel: 1})
+		assert.NoError(t, err)
+		_, err = dw.AddRow(row, RowOpts{Collapsed: true})
+		assert.NoError(t, err)
+		require.NoError(t, dw.Close())
+		assert.Contains(t, string(dw.buf), `<row r="5" hidden="true" outlineLevel="1">`)
+		assert.Contains(t, string(dw.buf), `<row r="6" collapsed="true">`)
+		assert.Contains(t, string(dw.buildHeader()), `<sheetFormatPr defaultRowHeight="15" outlineLevelRow="1"></sheetFormatPr>`)
+
+		var out bytes.Buffer
+		_, err = file.WriteTo(&out)
+		require.NoError(t, err)
+
+		f, err := OpenReader(&out)
+		require.NoError(t, err)
+		assert.Empty(t, f.validateParts())
+		for r := 1; r <= 6; r++ {
+			level, err := f.GetRowOutlineLevel("Sheet1", r)
+			assert.NoError(t, err)
+			visible, err := f.GetRowVisible("Sheet1", r)
+			assert.NoError(t, err)
+			grouped := r >= 2 && r <= 5
+			assert.Equal(t, grouped, level == 1, r)
+			assert.Equal(t, !grouped, visible, r)
+		}
+		ws, err := f.workSheetReader("Sheet1")
+		require.NoError(t, err)
+		assert.True(t, ws.SheetData.Row[5].Collapsed)
+		assert.Equal(t, uint8(1), ws.SheetFormatPr.OutlineLevelRow)
+	})
+	t.Run("default-row-height", func(t *testing.T) {
+		file, row, _ := setupTestFileRow()
+		dw, err := file.NewDirectWriter("Sheet1", 1)
+		require.NoError(t, err)
+
+		assert.EqualError(t, dw.SetDefaultRowHeight(MaxRowHeight+1), ErrMaxRowHeight.Error())
+		require.NoError(t, dw.SetDefaultRowHeight(30))
+		assert.Contains(t, string(dw.buildHeader()), `<sheetFormatPr defaultRowHeight="30" customHeight="true"></sheetFormatPr>`)
+
+		var out bytes.Buffer
+		ch := make(chan error)
+		go func() {
+			_, err := file.WriteTo(&out)
+			ch <- err
+		}()
+		waitDirectWriterOut(dw)
+
+		_, err = dw.AddRow(row)
+		assert.NoError(t, err)
+		_, err = dw.AddRow(row, RowOpts{Height: 45})
+		assert.NoError(t, err)
+		assert.EqualError(t, dw.SetDefaultRowHeight(20), "Can't set default row height since first data already written.")
+		require.NoError(t, dw.Close())
+		require.NoError(t, <-ch)
+
+		f, err := OpenReader(&out)
+		require.NoError(t, err)
+		assert.Empty(t, f.validateParts())
+		height, err := f.GetRowHeight("Sheet1", 1)
+		assert.NoError(t, err)
+		assert.Equal(t, 30.0, height)
+		height, err = f.GetRowHeight("Sheet1", 2)
+		assert.NoError(t, err)
+		assert.Equal(t, 45.0, height)
+	})
+	t.Run("default-col-width", func(t *testing.T) {
+		file, row, _ := setupTestFileRow()
+		dw, err := file.NewDirectWriter("Sheet1", 1)
+		require.NoError(t, err)
+
+		assert.EqualError(t, dw.SetDefaultColWidth(MaxColumnWidth+1), ErrColumnWidth.Error())
+		assert.EqualError(t, dw.SetDefaultColWidth(-1), ErrParameterInvalid.Error())
+		require.NoError(t, dw.SetDefaultColWidth(20))
+		require.NoError(t, dw.SetColWidth(2, 2, 30))
+		assert.Contains(t, string(dw.buildHeader()), `<sheetFormatPr defaultColWidth="20" defaultRowHeight="15"></sheetFormatPr>`)
+
+		var out bytes.Buffer
+		ch := make(chan error)
+		go func() {
+			_, err := file.WriteTo(&out)
+			ch <- err
+		}()
+		waitDirectWriterOut(dw)
+
+		_, err = dw.AddRow(row)
+		assert.NoError(t, err)
+		assert.EqualError(t, dw.SetDefaultColWidth(10), "Can't set default col width since first data already written.")
+		require.NoError(t, dw.Close())
+		require.NoError(t, <-ch)
+
+		f, err := OpenReader(&out)
+		require.NoError(t, err)
+		assert.Empty(t, f.validateParts())
+		width, err := f.GetColWidth("Sheet1", "A")
+		assert.NoError(t, err)
+		assert.Equal(t, 20.0, width)
+		width, err = f.GetColWidth("Sheet1", "B")
+		assert.NoError(t, err)
+		assert.Equal(t, 30.0, width)
+		height, err := f.GetRowHeight("Sheet1", 1)
+		assert.NoError(t, err)
+		assert.Equal(t, defaultRowHeight, height)
+	})
+	t.Run("hyperlinks", func(t *testing.T) {
+		file, row, _ := setupTestFileRow()
+		dw, err := file.NewDirectWriter("Sheet1", 1)
+		require.NoError(t, err)
+
+		var out bytes.Buffer
+		ch := make(chan error)
+		go func() {
+			_, err := file.WriteTo(&out)
+			ch <- err
+		}()
+
+		_, err = dw.AddRow(row)
+		assert.NoError(t, err)
+		_, err = dw.AddRow(row)
+		assert.NoError(t, err)
+		assert.NoError(t, dw.SetCellHyperLink(1, 1, "https://github.com/xuri/excelize", true))
+		assert.NoError(t, dw.SetCellHyperLink(2, 2, "Sheet1!A1", false))
+		assert.EqualError(t, dw.SetCellHyperLink(0, 1, "Sheet1!A1", false), `invalid cell coordinates [1, 0]`)
+		require.NoError(t, dw.Close())
+		require.NoError(t, <-ch)
+
+		f, err := OpenReader(&out)
+		require.NoError(t, err)
+		assert.Empty(t, f.validateParts())
+		ok, link, err := f.GetCellHyperLink("Sheet1", "A1")
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, "https://github.com/xuri/excelize", link)
+		ok, link, err = f.GetCellHyperLink("Sheet1", "B2")
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, "Sheet1!A1", link)
+	})
+	t.Run("inline-strings", func(t *testing.T) {
+		file, row, _ := setupTestFileRow()
+		dw, err := file.NewDirectWriter("Sheet1", 8192)
+		require.NoError(t, err)
+		dw.SetInlineStrings(true)
+
+		_, err = dw.AddRow(append(row, Cell{Value: "<formula>", Formula: "A1"}))
+		assert.NoError(t, err)
+		require.NoError(t, dw.Close())
+		assert.Contains(t, string(dw.buf), `<c t="inlineStr"><is><t>foo</t></is></c><c t="inlineStr"><is><t xml:space="preserve">bar </t></is></c>`)
+		assert.Contains(t, string(dw.buf), `<c t="str"><f>A1</f><v>&lt;formula&gt;</v></c>`)
+
+		var out bytes.Buffer
+		_, err = file.WriteTo(&out)
+		require.NoError(t, err)
+
+		f, err := OpenReader(&out)
+		require.NoError(t, err)
+		assert.Empty(t, f.validateParts())
+		rows, err := f.GetRows("Sheet1")
+		require.NoError(t, err)
+		assert.Equal(t, "foo", rows[0][0])
+		assert.Equal(t, "bar ", rows[0][1])
+	})
+	t.Run("flush", func(t *testing.T) {
+		file, row, expectedRow := setupTestFileRow()
+		dw, err := file.NewDirectWriter("Sheet1", 8192)
+		require.NoError(t, err)
+
+		// flush is a no-op while no writer is registered
+		_, err = dw.AddRow(row)
+		assert.NoError(t, err)
+		require.NoError(t, dw.Flush())
+		assert.NotEmpty(t, dw.buf)
+
+		var out bytes.Buffer
+		ch := make(chan error)
+		go func() {
+			_, err := dw.WriteTo(&out)
+			ch <- err
+		}()
+		waitDirectWriterOut(dw)
+
+		require.NoError(t, dw.Flush())
+		dw.Lock()
+		flushed := out.String()
+		dw.Unlock()
+		assert.Equal(t, string(dw.buildHeader())+strings.TrimSuffix(strings.TrimPrefix(expectedRow, "<sheetData>"), "</sheetData>"), flushed)
+		assert.Empty(t, dw.buf)
+
+		_, err = dw.AddRow(row)
+		assert.NoError(t, err)
+		require.NoError(t, dw.Close())
+		require.NoError(t, <-ch)
+		assert.True(t, bytes.HasSuffix(out.Bytes(), []byte("</worksheet>")))
+	})
+	t.Run("context-cancel", func(t *testing.T) {
+		file, row, _ := setupTestFileRow()
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		dw, err := file.NewDirectWriterContext(ctx, "Sheet1", 512)
+		require.NoError(t, err)
+
+		writerCh := make(chan error)
+		go func() {
+			_, err := file.WriteTo(io.Discard)
+			writerCh <- err
+		}()
+		producerCh := make(chan error)
+		go func() {
+			for {
+				if _, err := dw.AddRow(row); err != nil {
+					producerCh <- err
+					return
+				}
+			}
+		}()
+
+		cancel()
+		for _, ch := range []chan error{producerCh, writerCh} {
+			select {
+			case err := <-ch:
+				assert.True(t, errors.Is(err, context.Canceled), err)
+			case <-time.After(5 * time.Second):
+				t.Fatal("timeout waiting for the direct writer to be cancelled")
+			}
+		}
+		assert.Equal(t, context.Canceled, dw.Close())
+	})
+	t.Run("stats", func(t *testing.T) {
+		file, row, _ := setupTestFileRow()
+		dw, err := file.NewDirectWriter("Sheet1", 8192)
+		require.NoError(t, err)
+
+		ch := make(chan error)
+		go func() {
+			_, err := dw.WriteTo(io.Discard)
+			ch <- err
+		}()
+		waitDirectWriterOut(dw)
+
+		// report progress from a separate goroutine while rows are added
+		stop, reported := make(chan struct{}), make(chan struct{})
+		go func() {
+			defer close(reported)
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+					_, _, _ = dw.Stats()
+				}
+			}
+		}()
+
+		var lastRows int
+		var lastFlushed int64
+		for i := 0; i < 5; i++ {
+			_, err = dw.AddRow(row)
+			assert.NoError(t, err)
+			rows, flushed, buffered := dw.Stats()
+			assert.Equal(t, lastRows+1, rows)
+			assert.Equal(t, lastFlushed, flushed)
+			assert.Greater(t, buffered, 0)
+
+			require.NoError(t, dw.Flush())
+			rows, flushed, buffered = dw.Stats()
+			assert.Equal(t, lastRows+1, rows)
+			assert.Greater(t, flushed, lastFlushed)
+			assert.Equal(t, 0, buffered)
+			lastRows, lastFlushed = rows, flushed
+		}
+		close(stop)
+		<-reported
+		require.NoError(t, dw.Close())
+		require.NoError(t, <-ch)
+	})
+	t.Run("after-close", func(t *testing.T) {
+		file, row, _ := setupTestFileRow()
+		dw, err := file.NewDirectWriter("Sheet1", 8192)
+		require.NoError(t, err)
+		_, err = dw.AddRow(row)
+		assert.NoError(t, err)
+		require.NoError(t, dw.Close())
+		buffered := len(dw.buf)
+
+		_, err = dw.AddRow(row)
+		assert.EqualError(t, err, ErrDirectWriterClosed.Error())
+		assert.EqualError(t, dw.SetWait(true), ErrDirectWriterClosed.Error())
+		assert.EqualError(t, dw.SetColWidth(1, 2, 20), ErrDirectWriterClosed.Error())
+		assert.EqualError(t, dw.SetDefaultRowHeight(20), ErrDirectWriterClosed.Error())
+		assert.EqualError(t, dw.MergeCell("A1", "B1"), ErrDirectWriterClosed.Error())
+		assert.EqualError(t, dw.SetCellHyperLink(1, 1, "Sheet1!A1", false), ErrDirectWriterClosed.Error())
+		assert.EqualError(t, dw.SetPanes(`{"freeze":false,"split":false}`), ErrDirectWriterClosed.Error())
+		assert.EqualError(t, dw.SetDimension("A1:D1"), ErrDirectWriterClosed.Error())
+		assert.EqualError(t, dw.SetColOutlineLevel(1, 2, 1, false), ErrDirectWriterClosed.Error())
+		assert.EqualError(t, dw.SetTabColor("#FF0000"), ErrDirectWriterClosed.Error())
+		assert.EqualError(t, dw.SetOutlineProps(false, false), ErrDirectWriterClosed.Error())
+		assert.EqualError(t, dw.ProtectSheet(nil), ErrDirectWriterClosed.Error())
+		assert.EqualError(t, dw.SetAsyncFlush(1), ErrDirectWriterClosed.Error())
+		assert.EqualError(t, dw.SetDefaultColWidth(20), ErrDirectWriterClosed.Error())
+		assert.EqualError(t, dw.SetView(100, SheetViewNormal), ErrDirectWriterClosed.Error())
+		assert.EqualError(t, dw.AddPageBreakRow(2), ErrDirectWriterClosed.Error())
+		assert.EqualError(t, dw.AddPageBreakCol(2), ErrDirectWriterClosed.Error())
+		assert.EqualError(t, dw.SetDefaultCellStyle(1), ErrDirectWriterClosed.Error())
+		_, err = dw.Finalize()
+		assert.EqualError(t, err, ErrDirectWriterClosed.Error())
+		assert.EqualError(t, dw.SetStartRow(1), ErrDirectWriterClosed.Error())
+		assert.EqualError(t, dw.AddAutofilter("A1:B2", nil), ErrDirectWriterClosed.Error())
+		assert.EqualError(t, dw.SetSheetVisible(false), ErrDirectWriterClosed.Error())
+		assert.EqualError(t, dw.SetPreserveSheet(true), ErrDirectWriterClosed.Error())
+		assert.EqualError(t, dw.AddDataValidation(NewDataValidation(true)), ErrDirectWriterClosed.Error())
+		assert.EqualError(t, dw.SetConditionalFormat("A1:A2", "[]"), ErrDirectWriterClosed.Error())
+		assert.EqualError(t, dw.AddComment("A1", Comment{}), ErrDirectWriterClosed.Error())
+		assert.EqualError(t, dw.SetSheetViewOptions(0, ShowGridLines(false)), ErrDirectWriterClosed.Error())
+		assert.EqualError(t, dw.SetActiveCell("A1"), ErrDirectWriterClosed.Error())
+		assert.EqualError(t, dw.AutoFitColumns(1), ErrDirectWriterClosed.Error())
+		assert.EqualError(t, dw.Flush(), ErrDirectWriterClosed.Error())
+		assert.EqualError(t, dw.Close(), ErrDirectWriterClosed.Error())
+		assert.Equal(t, buffered, len(dw.buf))
+		assert.True(t, bytes.HasSuffix(dw.buf, []byte("</worksheet>")))
+	})
+	t.Run("panes", func(t *testing.T) {
+		file, row, _ := setupTestFileRow()
+		dw, err := file.NewDirectWriter("Sheet1", 1)
+		require.NoError(t, err)
+		require.NoError(t, dw.SetPanes(`{"freeze":true,"split":false,"x_split":0,"y_split":1,"top_left_cell":"A2","active_pane":"bottomLeft"}`))
+		assert.Contains(t, string(dw.buildHeader()), `<pane activePane="bottomLeft" state="frozen" topLeftCell="A2" ySplit="1"></pane>`)
+
+		var out bytes.Buffer
+		ch := make(chan error)
+		go func() {
+			_, err := file.WriteTo(&out)
+			ch <- err
+		}()
+		waitDirectWriterOut(dw)
+		_, err = dw.AddRow(row)
+		assert.NoError(t, err)
+		assert.EqualError(t, dw.SetPanes(`{"freeze":false,"split":false}`), "Can't set panes since first data already written.")
+		require.NoError(t, dw.Close())
+		require.NoError(t, <-ch)
+
+		f, err := OpenReader(&out)
+		require.NoError(t, err)
+		assert.Empty(t, f.validateParts())
+		ws, err := f.workSheetReader("Sheet1")
+		require.NoError(t, err)
+		pane := ws.SheetViews.SheetView[0].Pane
+		require.NotNil(t, pane)
+		assert.Equal(t, "frozen", pane.State)
+		assert.Equal(t, 1.0, pane.YSplit)
+	})
+	t.Run("dimension", func(t *testing.T) {
+		file, row, _ := setupTestFileRow()
+		dw, err := file.NewDirectWriter("Sheet1", 1)
+		require.NoError(t, err)
+		assert.EqualError(t, dw.SetDimension("A1:B2:C3"), ErrParameterInvalid.Error())
+		assert.EqualError(t, dw.SetDimension("A:B2"), `cannot convert cell "A" to coordinates: invalid cell name "A"`)
+		require.NoError(t, dw.SetDimension("A1:D100"))
+		assert.Contains(t, string(dw.buildHeader()), `<dimension ref="A1:D100"></dimension>`)
+
+		var out bytes.Buffer
+		ch := make(chan error)
+		go func() {
+			_, err := dw.WriteTo(&out)
+			ch <- err
+		}()
+		waitDirectWriterOut(dw)
+		_, err = dw.AddRow(row)
+		assert.NoError(t, err)
+		assert.EqualError(t, dw.SetDimension("A1:D1"), "Can't set dimension since first data already written.")
+		require.NoError(t, dw.Close())
+		require.NoError(t, <-ch)
+		assert.Contains(t, out.String(), `<dimension ref="A1:D100"></dimension>`)
+
+		// Test compute the dimension on close in non-concurrent mode.
+		file, row, _ = setupTestFileRow()
+		dw, err = file.NewDirectWriter("Sheet1", 1)
+		require.NoError(t, err)
+		for i := 0; i < 3; i++ {
+			_, err = dw.AddRow(row)
+			assert.NoError(t, err)
+		}
+		_, err = dw.AddRow(row[:2])
+		assert.NoError(t, err)
+		require.NoError(t, dw.Close())
+		out.Reset()
+		_, err = dw.WriteTo(&out)
+		require.NoError(t, err)
+		assert.Contains(t, out.String(), `<dimension ref="A1:D4"></dimension>`)
+	})
+	t.Run("col-outline-level", func(t *testing.T) {
+		file, row, _ := setupTestFileRow()
+		dw, err := file.NewDirectWriter("Sheet1", 1)
+		require.NoError(t, err)
+		assert.EqualError(t, dw.SetColOutlineLevel(0, 2, 1, false), ErrColumnNumber.Error())
+		assert.EqualError(t, dw.SetColOutlineLevel(1, TotalColumns+1, 1, false), ErrColumnNumber.Error())
+		assert.EqualError(t, dw.SetColOutlineLevel(1, 2, 8, false), ErrOutlineLevel.Error())
+		require.NoError(t, dw.SetColWidth(1, 1, 20))
+		require.NoError(t, dw.SetColOutlineLevel(3, 2, 1, false))
+		require.NoError(t, dw.SetColOutlineLevel(4, 4, 2, true))
+		assert.Contains(t, string(dw.buildHeader()), `<col min="2" max="3" outlineLevel="1"/><col min="4" max="4" outlineLevel="2" collapsed="1"/></cols>`)
+
+		var out bytes.Buffer
+		ch := make(chan error)
+		go func() {
+			_, err := file.WriteTo(&out)
+			ch <- err
+		}()
+		waitDirectWriterOut(dw)
+		_, err = dw.AddRow(row)
+		assert.NoError(t, err)
+		assert.EqualError(t, dw.SetColOutlineLevel(5, 5, 1, false), "Can't set col outline level since first data already written.")
+		require.NoError(t, dw.Close())
+		require.NoError(t, <-ch)
+
+		f, err := OpenReader(&out)
+		require.NoError(t, err)
+		assert.Empty(t, f.validateParts())
+		for col, expected := range map[string]uint8{"A": 0, "B": 1, "C": 1, "D": 2, "E": 0} {
+			level, err := f.GetColOutlineLevel("Sheet1", col)
+			assert.NoError(t, err)
+			assert.Equal(t, expected, level, col)
+		}
+	})
+	t.Run("bool", func(t *testing.T) {
+		file := NewFile()
+		dw, err := file.NewDirectWriter("Sheet1", 8192)
+		require.NoError(t, err)
+		_, err = dw.AddRow([]Cell{{Value: true}, {Value: false}})
+		require.NoError(t, err)
+		assert.Equal(t, `<row r="1"><c t="b"><v>1</v></c><c t="b"><v>0</v></c></row>`, string(dw.buf))
+
+		var out bytes.Buffer
+		ch := make(chan error)
+		go func() {
+			_, err := file.WriteTo(&out)
+			ch <- err
+		}()
+		require.NoError(t, dw.Close())
+		require.NoError(t, <-ch)
+
+		f, err := OpenReader(&out)
+		require.NoError(t, err)
+		assert.Empty(t, f.validateParts())
+		for cell, expected := range map[string]string{"A1": "TRUE", "B1": "FALSE"} {
+			val, err := f.GetCellValue("Sheet1", cell)
+			assert.NoError(t, err)
+			assert.Equal(t, expected, val, cell)
+		}
+	})
+	t.Run("raw-value", func(t *testing.T) {
+		file := NewFile()
+		dw, err := file.NewDirectWriter("Sheet1", 8192)
+		require.NoError(t, err)
+		_, err = dw.AddRow([]Cell{{RawValue: []byte("123456"), StyleID: 1}, {Value: 1}, {RawValue: []byte("2.5"), Formula: "A1/2"}})
+		require.NoError(t, err)
+		assert.Equal(t, `<row r="1"><c s="1"><v>123456</v></c><c><v>1</v></c><c><f>A1/2</f><v>2.5</v></c></row>`, string(dw.buf))
+		assert.Equal(t, []int{6, 1, 3}, dw.MaxColumnLengths())
+
+		var out bytes.Buffer
+		ch := make(chan error)
+		go func() {
+			_, err := file.WriteTo(&out)
+			ch <- err
+		}()
+		require.NoError(t, dw.Close())
+		require.NoError(t, <-ch)
+
+		f, err := OpenReader(&out)
+		require.NoError(t, err)
+		assert.Empty(t, f.validateParts())
+		val, err := f.GetCellValue("Sheet1", "A1", Options{RawCellValue: true})
+		assert.NoError(t, err)
+		assert.Equal(t, "123456", val)
+	})
+	t.Run("preserve-sheet", func(t *testing.T) {
+		tmpl := NewFile()
+		tmpl.NewSheet("Sheet2")
+		require.NoError(t, tmpl.SetColWidth("Sheet2", "A", "B", 30))
+		require.NoError(t, tmpl.MergeCell("Sheet2", "A1", "B1"))
+		require.NoError(t, tmpl.SetSheetPrOptions("Sheet2", TabColor("FF0000")))
+		buf, err := tmpl.WriteToBuffer()
+		require.NoError(t, err)
+		file, err := OpenReader(buf)
+		require.NoError(t, err)
+		assert.Empty(t, file.validateParts())
+
+		_, row, _ := setupTestFileRow()
+		dw, err := file.NewDirectWriter("Sheet2", 1)
+		require.NoError(t, err)
+		require.NoError(t, dw.SetPreserveSheet(true))
+		require.NoError(t, dw.SetColWidth(3, 3, 15))
+		assert.EqualError(t, dw.MergeCell("B1", "C2"), ErrMergeCellOverlap.Error())
+		require.NoError(t, dw.MergeCell("A2", "B2"))
+
+		var out bytes.Buffer
+		ch := make(chan error)
+		go func() {
+			_, err := file.WriteTo(&out)
+			ch <- err
+		}()
+		waitDirectWriterOut(dw)
+		_, err = dw.AddRow(row)
+		assert.NoError(t, err)
+		assert.EqualError(t, dw.SetPreserveSheet(false), "Can't set preserve mode since first data already written.")
+		_, err = dw.AddRow(row)
+		assert.NoError(t, err)
+		require.NoError(t, dw.Close())
+		require.NoError(t, <-ch)
+
+		f, err := OpenReader(&out)
+		require.NoError(t, err)
+		assert.Empty(t, f.validateParts())
+		for col, expected := range map[string]float64{"A": 30, "B": 30, "C": 15, "D": defaultColWidth} {
+			width, err := f.GetColWidth("Sheet2", col)
+			assert.NoError(t, err)
+			assert.Equal(t, expected, width, col)
+		}
+		mergeCells, err := f.GetMergeCells("Sheet2")
+		require.NoError(t, err)
+		require.Len(t, mergeCells, 2)
+		assert.Equal(t, "A1", mergeCells[0].GetStartAxis())
+		assert.Equal(t, "B1", mergeCells[0].GetEndAxis())
+		assert.Equal(t, "A2", mergeCells[1].GetStartAxis())
+		assert.Equal(t, "B2", mergeCells[1].GetEndAxis())
+		var tabColor TabColor
+		require.NoError(t, f.GetSheetPrOptions("Sheet2", &tabColor))
+		assert.Equal(t, TabColor("FF0000"), tabColor)
+		val, err := f.GetCellValue("Sheet2", "A2")
+		assert.NoError(t, err)
+		assert.Equal(t, "foo", val)
+	})
+	t.Run("data-validation", func(t *testing.T) {
+		file, row, _ := setupTestFileRow()
+		dw, err := file.NewDirectWriter("Sheet1", 1)
+		require.NoError(t, err)
+
+		var out bytes.Buffer
+		ch := make(chan error)
+		go func() {
+			_, err := file.WriteTo(&out)
+			ch <- err
+		}()
+		waitDirectWriterOut(dw)
+		_, err = dw.AddRow(row)
+		assert.NoError(t, err)
+		dv := NewDataValidation(true)
+		dv.Sqref = "A1:A10"
+		require.NoError(t, dv.SetDropList([]string{"foo", "bar", "baz"}))
+		// data validations may be added after the first data is flushed
+		require.NoError(t, dw.AddDataValidation(dv))
+		require.NoError(t, dw.MergeCell("B2", "C2"))
+		require.NoError(t, dw.SetCellHyperLink(1, 4, "https://github.com", true))
+		require.NoError(t, dw.Close())
+		require.NoError(t, <-ch)
+
+		sheet := readZipEntry(t, out.Bytes(), "xl/worksheets/sheet1.xml")
+		mergeCells, dataValidations, hyperlinks := strings.Index(sheet, "<mergeCells"), strings.Index(sheet, "<dataValidations"), strings.Index(sheet, "<hyperlinks")
+		assert.True(t, mergeCells != -1 && mergeCells < dataValidations && dataValidations < hyperlinks, sheet)
+		assert.Contains(t, sheet, `<dataValidations count="1">`)
+
+		f, err := OpenReader(&out)
+		require.NoError(t, err)
+		assert.Empty(t, f.validateParts())
+		dvs, err := f.GetDataValidations("Sheet1")
+		require.NoError(t, err)
+		require.Len(t, dvs, 1)
+		assert.Equal(t, "A1:A10", dvs[0].Sqref)
+		assert.Equal(t, "list", dvs[0].Type)
+		assert.Equal(t, dv.Formula1, dvs[0].Formula1)
+	})
+	t.Run("comments", func(t *testing.T) {
+		file, row, _ := setupTestFileRow()
+		require.NoError(t, file.AddComment("Sheet1", "A1", `{"author":"Excelize: ","text":"Existing comment."}`))
+		dw, err := file.NewDirectWriter("Sheet2", 1)
+		require.NoError(t, err)
+		dw2, err := file.NewDirectWriter("Sheet3", 1)
+		require.NoError(t, err)
+
+		var out bytes.Buffer
+		ch := make(chan error)
+		go func() {
+			_, err := file.WriteTo(&out)
+			ch <- err
+		}()
+		waitDirectWriterOut(dw)
+		_, err = dw.AddRow(row)
+		assert.NoError(t, err)
+		// comments may be added after the first data is flushed
+		require.NoError(t, dw.AddComment("A1", Comment{Author: "Excelize: ", Text: "Computed value."}))
+		require.NoError(t, dw.AddComment("B1", Comment{Text: "Line 1\nLine 2"}))
+		assert.EqualError(t, dw.AddComment("A", Comment{}), `cannot convert cell "A" to coordinates: `+newInvalidCellNameError("A").Error())
+		require.NoError(t, dw.Close())
+		require.NoError(t, dw2.AddComment("C3", Comment{Author: "Excelize: ", Text: "Other sheet."}))
+		require.NoError(t, dw2.Close())
+		require.NoError(t, <-ch)
+		assert.Regexp(t, `<legacyDrawing [^>]*id="rId1"></legacyDrawing></worksheet>$`, readZipEntry(t, out.Bytes(), "xl/worksheets/sheet2.xml"))
+
+		f, err := OpenReader(&out)
+		require.NoError(t, err)
+		assert.Empty(t, f.validateParts())
+		assert.Equal(t, map[string][]Comment{
+			"Sheet1": {{Author: "Excelize: ", Ref: "A1", Text: "Excelize: Existing comment."}},
+			"Sheet2": {
+				{Author: "Excelize: ", Ref: "A1", Text: "Excelize: Computed value."},
+				{Author: "Author:", AuthorID: 1, Ref: "B1", Text: "Author:Line 1\nLine 2"},
+			},
+			"Sheet3": {{Author: "Excelize: ", Ref: "C3", Text: "Excelize: Other sheet."}},
+		}, f.GetComments())
+		// comments are added to the existing parts of a preserved sheet
+		dw, err = f.NewDirectWriter("Sheet2", 1)
+		require.NoError(t, err)
+		require.NoError(t, dw.AddComment("D4", Comment{Author: "Excelize: ", Text: "Added."}))
+		require.NoError(t, dw.Close())
+		out.Reset()
+		require.NoError(t, f.Write(&out))
+		f, err = OpenReader(&out)
+		require.NoError(t, err)
+		assert.Empty(t, f.validateParts())
+		comments := f.GetComments()["Sheet2"]
+		require.Len(t, comments, 3)
+		assert.Equal(t, "D4", comments[2].Ref)
+	})
+	t.Run("conditional-format", func(t *testing.T) {
+		file, row, _ := setupTestFileRow()
+		dw, err := file.NewDirectWriter("Sheet1", 1)
+		require.NoError(t, err)
+
+		var out bytes.Buffer
+		ch := make(chan error)
+		go func() {
+			_, err := file.WriteTo(&out)
+			ch <- err
+		}()
+		waitDirectWriterOut(dw)
+		for i := 0; i < 3; i++ {
+			_, err = dw.AddRow(row)
+			assert.NoError(t, err)
+		}
+		// the rules and their differential styles may be added after the first
+		// data is flushed
+		require.NoError(t, dw.SetConditionalFormat("D1:D3", `[{"type":"3_color_scale","criteria":"=","min_type":"min","mid_type":"percentile","max_type":"max","min_color":"#F8696B","mid_color":"#FFEB84","max_color":"#63BE7B"}]`))
+		format, err := file.NewConditionalStyle(`{"font":{"color":"#9A0511"},"fill":{"type":"pattern","color":["#FEC7CE"],"pattern":1}}`)
+		require.NoError(t, err)
+		require.NoError(t, dw.SetConditionalFormat("D1:D3", fmt.Sprintf(`[{"type":"cell","criteria":">","format":%d,"value":"100"}]`, format)))
+		assert.EqualError(t, dw.SetConditionalFormat("D1:D3", ""), "unexpected end of JSON input")
+		require.NoError(t, dw.MergeCell("A4", "B4"))
+		require.NoError(t, dw.Close())
+		require.NoError(t, <-ch)
+
+		sheet := readZipEntry(t, out.Bytes(), "xl/worksheets/sheet1.xml")
+		mergeCells, conditionalFormatting := strings.Index(sheet, "<mergeCells"), strings.Index(sheet, "<conditionalFormatting")
+		assert.True(t, mergeCells != -1 && mergeCells < conditionalFormatting, sheet)
+
+		f, err := OpenReader(&out)
+		require.NoError(t, err)
+		assert.Empty(t, f.validateParts())
+		ws, err := f.workSheetReader("Sheet1")
+		require.NoError(t, err)
+		require.Len(t, ws.ConditionalFormatting, 2)
+		assert.Equal(t, "D1:D3", ws.ConditionalFormatting[0].SQRef)
+		require.NotNil(t, ws.ConditionalFormatting[0].CfRule[0].ColorScale)
+		assert.Len(t, ws.ConditionalFormatting[0].CfRule[0].ColorScale.Color, 3)
+		assert.Equal(t, "cellIs", ws.ConditionalFormatting[1].CfRule[0].Type)
+		require.NotNil(t, ws.ConditionalFormatting[1].CfRule[0].DxfID)
+		require.NotNil(t, f.Styles.Dxfs)
+		assert.Equal(t, len(f.Styles.Dxfs.Dxfs), *ws.ConditionalFormatting[1].CfRule[0].DxfID+1)
+	})
+	t.Run("shared-strings", func(t *testing.T) {
+		write := func(file *File, dw *DirectWriter) []byte {
+			_, err := dw.AddRow([]Cell{{Value: "foo"}})
+			require.NoError(t, err)
+			require.NoError(t, dw.Close())
+			var out bytes.Buffer
+			_, err = file.WriteTo(&out)
+			require.NoError(t, err)
+			return out.Bytes()
+		}
+		// register an empty shared string table
+		file := NewFile()
+		require.NotNil(t, file.sharedStringsReader())
+		dw, err := file.NewDirectWriter("Sheet1", 1)
+		require.NoError(t, err)
+		out := write(file, dw)
+		z, err := zip.NewReader(bytes.NewReader(out), int64(len(out)))
+		require.NoError(t, err)
+		for _, zf := range z.File {
+			assert.NotEqual(t, "xl/sharedStrings.xml", zf.Name)
+		}
+		assert.NotContains(t, readZipEntry(t, out, "[Content_Types].xml"), "sharedStrings")
+		assert.NotContains(t, readZipEntry(t, out, "xl/_rels/workbook.xml.rels"), "sharedStrings")
+		f, err := OpenReader(bytes.NewReader(out))
+		require.NoError(t, err)
+		assert.Empty(t, f.validateParts())
+		val, err := f.GetCellValue("Sheet1", "A1")
+		assert.NoError(t, err)
+		assert.Equal(t, "foo", val)
+
+		// keep the shared string table used by other worksheets
+		file = NewFile()
+		file.NewSheet("Sheet2")
+		require.NoError(t, file.SetCellStr("Sheet2", "A1", "bar"))
+		dw, err = file.NewDirectWriter("Sheet1", 1)
+		require.NoError(t, err)
+		out = write(file, dw)
+		assert.Contains(t, readZipEntry(t, out, "xl/sharedStrings.xml"), "bar")
+		f, err = OpenReader(bytes.NewReader(out))
+		require.NoError(t, err)
+		assert.Empty(t, f.validateParts())
+		val, err = f.GetCellValue("Sheet2", "A1")
+		assert.NoError(t, err)
+		assert.Equal(t, "bar", val)
+	})
+	t.Run("num-fmt", func(t *testing.T) {
+		file := NewFile()
+		file.NewSheet("Sheet2")
+		styleCount := len(file.Styles.CellXfs.Xf)
+		var writers []*DirectWriter
+		for _, sheet := range []string{"Sheet1", "Sheet2"} {
+			dw, err := file.NewDirectWriter(sheet, 1<<20)
+			require.NoError(t, err)
+			writers = append(writers, dw)
+		}
+		var wg sync.WaitGroup
+		for _, dw := range writers {
+			wg.Add(1)
+			go func(dw *DirectWriter) {
+				defer wg.Done()
+				for r := 1; r <= 100; r++ {
+					_, err := dw.AddRow([]Cell{{Value: 0.125, NumFmt: "0.00%"}, {Value: 1234.5, NumFmt: "#,##0.00", StyleID: 1}, {Value: r}})
+					assert.NoError(t, err)
+				}
+				assert.NoError(t, dw.Close())
+			}(dw)
+		}
+		wg.Wait()
+		// the styles are created once and shared by the rows of all writers
+		assert.Len(t, file.Styles.CellXfs.Xf, styleCount+2)
+		percent, err := file.numFmtStyleID("0.00%")
+		require.NoError(t, err)
+		number, err := file.numFmtStyleID("#,##0.00")
+		require.NoError(t, err)
+		assert.NotEqual(t, percent, number)
+		for _, dw := range writers {
+			expected := fmt.Sprintf(`<c s="%d"><v>0.125</v></c><c s="%d"><v>1234.5</v></c>`, percent, number)
+			assert.Equal(t, 100, strings.Count(string(dw.buf), expected))
+		}
+
+		var out bytes.Buffer
+		_, err = file.WriteTo(&out)
+		require.NoError(t, err)
+		f, err := OpenReader(&out)
+		require.NoError(t, err)
+		assert.Empty(t, f.validateParts())
+		numFmtCode := func(sheet, cell string) string {
+			styleID, err := f.GetCellStyle(sheet, cell)
+			require.NoError(t, err)
+			numFmtID := *f.Styles.CellXfs.Xf[styleID].NumFmtID
+			for _, numFmt := range f.Styles.NumFmts.NumFmt {
+				if numFmt.NumFmtID == numFmtID {
+					return numFmt.FormatCode
+				}
+			}
+			return ""
+		}
+		for _, sheet := range []string{"Sheet1", "Sheet2"} {
+			assert.Equal(t, "0.00%", numFmtCode(sheet, "A100"))
+			assert.Equal(t, "#,##0.00", numFmtCode(sheet, "B100"))
+		}
+	})
+	t.Run("register-styles", func(t *testing.T) {
+		file := NewFile()
+		dw, err := file.NewDirectWriter("Sheet1", 1<<20)
+		require.NoError(t, err)
+		customNumFmt := "0.000%"
+		bold := &Style{Font: &Font{Bold: true, Color: "#FF0000"}}
+		percent := &Style{CustomNumFmt: &customNumFmt}
+		fill := &Style{Fill: Fill{Type: "pattern", Pattern: 1, Color: []string{"#E0EBF5"}}}
+		ids, err := dw.RegisterStyles([]*Style{bold, percent, fill,
+			{Font: &Font{Bold: true, Color: "#FF0000"}}, percent, {Fill: Fill{Type: "pattern", Pattern: 1, Color: []string{"#E0EBF5"}}}})
+		require.NoError(t, err)
+		require.Len(t, ids, 6)
+		assert.Equal(t, ids[:3], ids[3:])
+		assert.NotEqual(t, ids[0], ids[1])
+		assert.NotEqual(t, ids[1], ids[2])
+		assert.Len(t, file.Styles.CellXfs.Xf, ids[2]+1)
+		// registering the same styles again doesn't grow the styles
+		again, err := dw.RegisterStyles([]*Style{fill, bold})
+		require.NoError(t, err)
+		assert.Equal(t, []int{ids[2], ids[0]}, again)
+		assert.Len(t, file.Styles.CellXfs.Xf, ids[2]+1)
+
+		ids, err = dw.RegisterStyles([]*Style{bold, nil})
+		assert.EqualError(t, err, ErrParameterInvalid.Error())
+		assert.Equal(t, again[1:], ids)
+		_, err = dw.RegisterStyles([]*Style{{Font: &Font{Size: MaxFontSize + 1}}})
+		assert.EqualError(t, err, ErrFontSize.Error())
+		require.NoError(t, dw.Close())
+		_, err = dw.RegisterStyles([]*Style{bold})
+		assert.EqualError(t, err, ErrDirectWriterClosed.Error())
+	})
+	t.Run("auto-fit-columns", func(t *testing.T) {
+		file := NewFile()
+		dw, err := file.NewDirectWriter("Sheet1", 1)
+		require.NoError(t, err)
+		require.NoError(t, dw.SetWait(true))
+
+		var out bytes.Buffer
+		ch := make(chan error)
+		go func() {
+			_, err := file.WriteTo(&out)
+			ch <- err
+		}()
+		waitDirectWriterOut(dw)
+		for _, row := range [][]Cell{
+			{{Value: "foo"}, {Value: "foo"}, {}, {Value: strings.Repeat("a", 300)}, {Value: "数据"}},
+			{{Value: "foobar"}, {Value: "foo bar"}, {}, {}, {Value: "数据数据"}},
+		} {
+			_, err = dw.AddRow(row)
+			require.NoError(t, err)
+		}
+		assert.EqualError(t, dw.AutoFitColumns(0), ErrParameterInvalid.Error())
+		require.NoError(t, dw.AutoFitColumns(1))
+		assert.Equal(t, `<col min="1" max="1" width="6.71484375" customWidth="1"/><col min="2" max="2" width="7.71484375" customWidth="1"/><col min="4" max="4" width="255" customWidth="1"/><col min="5" max="5" width="12.71484375" customWidth="1"/>`, dw.cols)
+		dw.cols = ""
+		require.NoError(t, dw.AutoFitColumns(0.5))
+		assert.Contains(t, dw.cols, `<col min="1" max="1" width="3.35546875" customWidth="1"/>`)
+		require.NoError(t, dw.SetWait(false))
+		require.NoError(t, dw.Flush())
+		assert.EqualError(t, dw.AutoFitColumns(1), "Can't auto fit columns since first data already written.")
+		require.NoError(t, dw.Close())
+		require.NoError(t, <-ch)
+
+		f, err := OpenReader(&out)
+		require.NoError(t, err)
+		assert.Empty(t, f.validateParts())
+		for col, expected := range map[string]float64{"A": 3.35546875, "C": defaultColWidth, "D": 150.35546875} {
+			width, err := f.GetColWidth("Sheet1", col)
+			assert.NoError(t, err)
+			assert.Equal(t, expected, width, col)
+		}
+	})
+	t.Run("add-rows", func(t *testing.T) {
+		file, row, expectedRow := setupTestFileRow()
+		dw, err := file.NewDirectWriter("Sheet1", 1)
+		require.NoError(t, err)
+		buffered, err := dw.AddRows([][]Cell{row, row}, RowOpts{Height: 20})
+		require.NoError(t, err)
+		assert.Equal(t, len(dw.buf), buffered)
+		rows := strings.Split(strings.TrimSuffix(strings.TrimPrefix(expectedRow, "<sheetData>"), "</sheetData>"), `<row r="1">`)[1]
+		assert.Equal(t, `<row r="1" ht="20" customHeight="1">`+rows+`<row r="2" ht="20" customHeight="1">`+rows, string(dw.buf))
+		_, err = dw.AddRows([][]Cell{row}, RowOpts{OutlineLevel: 8})
+		assert.EqualError(t, err, ErrOutlineLevel.Error())
+
+		var out bytes.Buffer
+		ch := make(chan error)
+		go func() {
+			_, err := file.WriteTo(&out)
+			ch <- err
+		}()
+		waitDirectWriterOut(dw)
+		// flushes after the batch
+		buffered, err = dw.AddRows([][]Cell{row, row})
+		require.NoError(t, err)
+		assert.Equal(t, 0, buffered)
+		rowCount, _, _ := dw.Stats()
+		assert.Equal(t, 4, rowCount)
+		require.NoError(t, dw.Close())
+		require.NoError(t, <-ch)
+		_, err = dw.AddRows([][]Cell{row})
+		assert.EqualError(t, err, ErrDirectWriterClosed.Error())
+	})
+	t.Run("add-raw-row", func(t *testing.T) {
+		file, row, _ := setupTestFileRow()
+		dw, err := file.NewDirectWriter("Sheet1", 1<<20)
+		require.NoError(t, err)
+		_, err = dw.AddRow(row)
+		require.NoError(t, err)
+		_, err = dw.AddRawRow([]byte(`<c><v>1</v></c>`))
+		assert.EqualError(t, err, ErrDirectWriterRawRow.Error())
+		buffered, err := dw.AddRawRow([]byte(`<row r="2"><c t="inlineStr"><is><t>cached &amp; escaped</t></is></c><c><v>42</v></c></row>`))
+		require.NoError(t, err)
+		assert.Equal(t, len(dw.buf), buffered)
+		_, err = dw.AddRow([]Cell{{Value: 3}})
+		require.NoError(t, err)
+		rowCount, _, _ := dw.Stats()
+		assert.Equal(t, 3, rowCount)
+		assert.True(t, bytes.HasSuffix(dw.buf, []byte(`<row r="3"><c><v>3</v></c></row>`)))
+
+		var out bytes.Buffer
+		ch := make(chan error)
+		go func() {
+			_, err := file.WriteTo(&out)
+			ch <- err
+		}()
+		waitDirectWriterOut(dw)
+		// flushes as AddRow does
+		dw.maxBufferSize = 1
+		buffered, err = dw.AddRawRow([]byte(`<row r="4"><c><v>4</v></c></row>`))
+		require.NoError(t, err)
+		assert.Equal(t, 0, buffered)
+		require.NoError(t, dw.Close())
+		require.NoError(t, <-ch)
+		_, err = dw.AddRawRow([]byte(`<row r="5"></row>`))
+		assert.EqualError(t, err, ErrDirectWriterClosed.Error())
+
+		f, err := OpenReader(&out)
+		require.NoError(t, err)
+		assert.Empty(t, f.validateParts())
+		rows, err := f.GetRows("Sheet1")
+		require.NoError(t, err)
+		require.Len(t, rows, 4)
+		assert.Equal(t, []string{"cached & escaped", "42"}, rows[1])
+		assert.Equal(t, []string{"3"}, rows[2])
+		assert.Equal(t, []string{"4"}, rows[3])
+
+		dw, err = NewFile().NewDirectWriter("Sheet1", 1<<20)
+		require.NoError(t, err)
+		dw.rowCount = TotalRows
+		_, err = dw.AddRawRow([]byte(`<row r="1048577"></row>`))
+		assert.EqualError(t, err, ErrMaxRows.Error())
+		assert.Empty(t, dw.buf)
+	})
+	t.Run("whitespace", func(t *testing.T) {
+		values := []string{"\t", "\n", " ", "a\n", "\ta", " a b ", "\r\n"}
+		for _, inlineStrings := range []bool{false, true} {
+			file := NewFile()
+			dw, err := file.NewDirectWriter("Sheet1", 1<<20)
+			require.NoError(t, err)
+			dw.SetInlineStrings(inlineStrings)
+			for _, v := range values {
+				_, err = dw.AddRow([]Cell{{Value: v}})
+				require.NoError(t, err)
+			}
+			require.NoError(t, dw.Close())
+			assert.Equal(t, len(values), strings.Count(string(dw.buf), `xml:space="preserve"`))
+
+			var out bytes.Buffer
+			_, err = file.WriteTo(&out)
+			require.NoError(t, err)
+			f, err := OpenReader(&out)
+			require.NoError(t, err)
+			assert.Empty(t, f.validateParts())
+			for r, v := range values {
+				cell, _ := CoordinatesToCellName(1, r+1)
+				val, err := f.GetCellValue("Sheet1", cell)
+				assert.NoError(t, err)
+				assert.Equal(t, v, val, cell)
+			}
+		}
+	})
+	t.Run("assume-dense", func(t *testing.T) {
+		file := NewFile()
+		dw, err := file.NewDirectWriter("Sheet1", 1<<20)
+		require.NoError(t, err)
+		_, err = dw.AddRow([]Cell{{Value: "a"}, {}, {Value: "c"}, {}, {}})
+		require.NoError(t, err)
+		_, err = dw.AddRow([]Cell{{}, {Value: 2}, {}})
+		require.NoError(t, err)
+		_, err = dw.AddRow([]Cell{{}, {}})
+		require.NoError(t, err)
+		require.NoError(t, dw.Close())
+
+		var out bytes.Buffer
+		_, err = file.WriteTo(&out)
+		require.NoError(t, err)
+		f, err := OpenReader(&out)
+		require.NoError(t, err)
+		assert.Empty(t, f.validateParts())
+		rows, err := f.GetRows("Sheet1")
+		assert.NoError(t, err)
+		assert.Equal(t, [][]string{{"a", "", "c"}, {"", "2"}}, rows)
+		rows, err = f.GetRows("Sheet1", Options{AssumeDense: true})
+		assert.NoError(t, err)
+		assert.Equal(t, [][]string{{"a", "", "c", "", ""}, {"", "2", ""}, {"", ""}}, rows)
+	})
+	t.Run("header-footer", func(t *testing.T) {
+		file := NewFile()
+		dw, err := file.NewDirectWriter("Sheet1", 16)
+		require.NoError(t, err)
+		_, err = dw.AddRow([]Cell{{Value: "a"}})
+		require.NoError(t, err)
+		require.NoError(t, dw.Flush())
+		assert.EqualError(t, dw.SetHeaderFooter(&FormatHeaderFooter{OddHeader: strings.Repeat("c", MaxFieldLength+1)}), newFieldLengthError("OddHeader").Error())
+		settings := &FormatHeaderFooter{
+			DifferentFirst: true,
+			OddHeader:      "&C&\"Arial,Bold\"Report",
+			OddFooter:      "&LPage &P of &N&R&D",
+			FirstHeader:    "&CFirst",
+		}
+		require.NoError(t, dw.SetHeaderFooter(settings))
+		require.NoError(t, dw.MergeCell("A2", "B2"))
+		require.NoError(t, dw.SetCellHyperLink(1, 1, "https://github.com", true))
+		require.NoError(t, dw.Close())
+		assert.EqualError(t, dw.SetHeaderFooter(settings), ErrDirectWriterClosed.Error())
+		buf := string(dw.buf)
+		assert.Contains(t, buf, `<headerFooter differentFirst="true"><oddHeader>&amp;C&amp;&#34;Arial,Bold&#34;Report</oddHeader>`)
+		assert.Less(t, strings.Index(buf, "<mergeCells"), strings.Index(buf, "<hyperlinks"))
+		assert.Less(t, strings.Index(buf, "<hyperlinks"), strings.Index(buf, "<headerFooter"))
+
+		var out bytes.Buffer
+		_, err = file.WriteTo(&out)
+		require.NoError(t, err)
+		f, err := OpenReader(&out)
+		require.NoError(t, err)
+		assert.Empty(t, f.validateParts())
+		ws, err := f.workSheetReader("Sheet1")
+		require.NoError(t, err)
+		require.NotNil(t, ws.HeaderFooter)
+		assert.True(t, ws.HeaderFooter.DifferentFirst)
+		assert.Equal(t, settings.OddHeader, ws.HeaderFooter.OddHeader)
+		assert.Equal(t, settings.OddFooter, ws.HeaderFooter.OddFooter)
+		assert.Equal(t, settings.FirstHeader, ws.HeaderFooter.FirstHeader)
+	})
+	t.Run("page-layout", func(t *testing.T) {
+		file := NewFile()
+		dw, err := file.NewDirectWriter("My Sheet", 1)
+		require.NoError(t, err)
+		require.NoError(t, dw.SetPageLayout(FitToWidth(1), FitToHeight(0)))
+
+		var out bytes.Buffer
+		ch := make(chan error)
+		go func() {
+			_, err := file.WriteTo(&out)
+			ch <- err
+		}()
+		waitDirectWriterOut(dw)
+		_, err = dw.AddRow([]Cell{{Value: "a"}})
+		require.NoError(t, err)
+		assert.EqualError(t, dw.SetPageLayout(FitToWidth(2)), "Can't set fit to page since first data already written.")
+		require.NoError(t, dw.SetPageLayout(PageLayoutOrientation(OrientationLandscape)))
+		require.NoError(t, dw.SetPageMargins(PageMarginLeft(0.5), PageMarginRight(0.5)))
+		assert.EqualError(t, dw.SetDefinedPrintArea("A1"), ErrParameterInvalid.Error())
+		require.NoError(t, dw.SetDefinedPrintArea("D20:A1"))
+		require.NoError(t, dw.SetHeaderFooter(&FormatHeaderFooter{OddFooter: "&P"}))
+		require.NoError(t, dw.Close())
+		assert.EqualError(t, dw.SetPageLayout(), ErrDirectWriterClosed.Error())
+		assert.EqualError(t, dw.SetPageMargins(), ErrDirectWriterClosed.Error())
+		assert.EqualError(t, dw.SetDefinedPrintArea("A1:B2"), ErrDirectWriterClosed.Error())
+		require.NoError(t, <-ch)
+		sheet := readZipEntry(t, out.Bytes(), "xl/worksheets/sheet2.xml")
+		assert.Contains(t, sheet, `<pageSetup fitToWidth="1" orientation="landscape"></pageSetup>`)
+		assert.Less(t, strings.Index(sheet, "<pageMargins"), strings.Index(sheet, "<pageSetup"))
+		assert.Less(t, strings.Index(sheet, "<pageSetup"), strings.Index(sheet, "<headerFooter"))
+
+		f, err := OpenReader(&out)
+		require.NoError(t, err)
+		assert.Empty(t, f.validateParts())
+		var (
+			orientation PageLayoutOrientation
+			fitToWidth  FitToWidth
+			fitToPage   FitToPage
+			marginLeft  PageMarginLeft
+		)
+		require.NoError(t, f.GetPageLayout("My Sheet", &orientation, &fitToWidth))
+		assert.Equal(t, PageLayoutOrientation(OrientationLandscape), orientation)
+		assert.Equal(t, FitToWidth(1), fitToWidth)
+		require.NoError(t, f.GetSheetPrOptions("My Sheet", &fitToPage))
+		assert.True(t, bool(fitToPage))
+		require.NoError(t, f.GetPageMargins("My Sheet", &marginLeft))
+		assert.Equal(t, PageMarginLeft(0.5), marginLeft)
+		assert.Equal(t, []DefinedName{{Name: "_xlnm.Print_Area", RefersTo: "'My Sheet'!$A$1:$D$20", Scope: "My Sheet"}}, f.GetDefinedName())
+	})
+	t.Run("next-sheet", func(t *testing.T) {
+		for _, opts := range []Options{{}, {CompressionConcurrency: 4}} {
+			for _, streaming := range []bool{false, true} {
+				file := NewFile(opts)
+				dw, err := file.NewDirectWriter("Sheet1", 1)
+				require.NoError(t, err)
+				var out bytes.Buffer
+				ch := make(chan error)
+				if streaming {
+					go func() {
+						_, err := file.WriteTo(&out)
+						ch <- err
+					}()
+				}
+				for i := 1; i <= 3; i++ {
+					if streaming {
+						waitDirectWriterOut(dw)
+					}
+					require.NoError(t, dw.SetColWidth(1, 1, float64(10*i)))
+					_, err = dw.AddRow([]Cell{{Value: "Data" + strconv.Itoa(i)}, {Value: i}})
+					require.NoError(t, err)
+					require.NoError(t, dw.MergeCell("A2", "B2"))
+					require.NoError(t, dw.AddComment("A1", Comment{Author: "Excelize", Text: "Sheet" + strconv.Itoa(i)}))
+					if i == 2 {
+						require.NoError(t, dw.SetSheetVisible(false))
+					}
+					if i < 3 {
+						assert.EqualError(t, dw.NextSheet("Sheet1"), ErrDirectWriterSheet.Error())
+						require.NoError(t, dw.NextSheet("Data "+strconv.Itoa(i+1)))
+						rows, _, buffered := dw.Stats()
+						assert.Equal(t, 0, rows)
+						assert.Equal(t, 0, buffered)
+					}
+				}
+				require.NoError(t, dw.Close())
+				assert.EqualError(t, dw.NextSheet("Data 4"), ErrDirectWriterClosed.Error())
+				if streaming {
+					require.NoError(t, <-ch)
+				} else {
+					_, err = file.WriteTo(&out)
+					require.NoError(t, err)
+				}
+
+				f, err := OpenReader(&out)
+				require.NoError(t, err)
+				assert.Empty(t, f.validateParts())
+				assert.Equal(t, []string{"Sheet1", "Data 2", "Data 3"}, f.GetSheetList())
+				assert.False(t, f.GetSheetVisible("Data 2"))
+				comments := f.GetComments()
+				for i, sheet := range f.GetSheetList() {
+					rows, err := f.GetRows(sheet)
+					assert.NoError(t, err)
+					assert.Equal(t, [][]string{{"Data" + strconv.Itoa(i+1), strconv.Itoa(i + 1)}}, rows, sheet)
+					width, err := f.GetColWidth(sheet, "A")
+					assert.NoError(t, err)
+					assert.Equal(t, float64(10*(i+1)), width, sheet)
+					merged, err := f.GetMergeCells(sheet)
+					assert.NoError(t, err)
+					assert.Len(t, merged, 1, sheet)
+					require.Len(t, comments[sheet], 1, sheet)
+					assert.Equal(t, "ExcelizeSheet"+strconv.Itoa(i+1), comments[sheet][0].Text, sheet)
+				}
+			}
+		}
+	})
+	t.Run("next-sheet-attach", func(t *testing.T) {
+		// Test File.WriteTo attaches after the worksheet is finished and
+		// before the writer moves on to the next sheet.
+		for _, opts := range []Options{{}, {CompressionConcurrency: 4}} {
+			file := NewFile(opts)
+			dw, err := file.NewDirectWriter("Sheet1", 1<<20)
+			require.NoError(t, err)
+			_, err = dw.AddRow([]Cell{{Value: "Data1"}})
+			require.NoError(t, err)
+			file.NewSheet("Data 2")
+			ws, err := file.workSheetReader("Data 2")
+			require.NoError(t, err)
+			require.NoError(t, dw.finish())
+			var out bytes.Buffer
+			ch := make(chan error)
+			go func() {
+				_, err := file.WriteTo(&out)
+				ch <- err
+			}()
+			waitDirectWriterOut(dw)
+			require.NoError(t, dw.switchSheet("Data 2", file.getSheetID("Data 2"), ws))
+			waitDirectWriterOut(dw)
+			_, err = dw.AddRow([]Cell{{Value: "Data2"}})
+			require.NoError(t, err)
+			require.NoError(t, dw.Close())
+			require.NoError(t, <-ch)
+
+			f, err := OpenReader(&out)
+			require.NoError(t, err)
+			for i, sheet := range f.GetSheetList() {
+				rows, err := f.GetRows(sheet)
+				assert.NoError(t, err)
+				assert.Equal(t, [][]string{{"Data" + strconv.Itoa(i+1)}}, rows, sheet)
+			}
+			require.NoError(t, f.Close())
+		}
+	})
+	t.Run("next-sheet-concurrent", func(t *testing.T) {
+		// Test the writers create sheets by NextSheet while File.WriteTo
+		// marshals the workbook.
+		for _, opts := range []Options{{}, {CompressionConcurrency: 4}} {
+			file := NewFile(opts)
+			file.NewSheet("W1")
+			var writers []*DirectWriter
+			for _, sheet := range []string{"Sheet1", "W1"} {
+				dw, err := file.NewDirectWriter(sheet, 1<<20)
+				require.NoError(t, err)
+				writers = append(writers, dw)
+			}
+			var out bytes.Buffer
+			ch := make(chan error)
+			go func() {
+				_, err := file.WriteTo(&out)
+				ch <- err
+			}()
+			var wg sync.WaitGroup
+			for w, dw := range writers {
+				wg.Add(1)
+				go func(w int, dw *DirectWriter) {
+					defer wg.Done()
+					for i := 0; i < 3; i++ {
+						_, err := dw.AddRow([]Cell{{Value: w*10 + i}})
+						assert.NoError(t, err)
+						assert.NoError(t, dw.NextSheet(fmt.Sprintf("S%d_%d", w, i)))
+					}
+					assert.NoError(t, dw.Close())
+				}(w, dw)
+			}
+			wg.Wait()
+			require.NoError(t, <-ch)
+
+			f, err := OpenReader(&out)
+			require.NoError(t, err)
+			assert.Len(t, f.GetSheetList(), 8)
+			for w, sheet := range []string{"Sheet1", "W1"} {
+				for i := 0; i < 3; i++ {
+					rows, err := f.GetRows(sheet)
+					assert.NoError(t, err)
+					assert.Equal(t, [][]string{{strconv.Itoa(w*10 + i)}}, rows, sheet)
+					sheet = fmt.Sprintf("S%d_%d", w, i)
+				}
+			}
+			assert.Empty(t, f.validateParts())
+			require.NoError(t, f.Close())
+		}
+	})
+	t.Run("col-width-format", func(t *testing.T) {
+		for width, expected := range map[float64]string{20: "20", 8.43: "8.43", 0.5: "0.5", 10.125: "10.125", 255: "255"} {
+			file := NewFile()
+			dw, err := file.NewDirectWriter("Sheet1", 1<<20)
+			require.NoError(t, err)
+			require.NoError(t, dw.SetColWidth(2, 3, width))
+			assert.Equal(t, `<col min="2" max="3" width="`+expected+`" customWidth="1"/>`, dw.cols)
+			require.NoError(t, dw.Close())
+
+			var out bytes.Buffer
+			_, err = file.WriteTo(&out)
+			require.NoError(t, err)
+			f, err := OpenReader(&out)
+			require.NoError(t, err)
+			assert.Empty(t, f.validateParts())
+			actual, err := f.GetColWidth("Sheet1", "C")
+			assert.NoError(t, err)
+			assert.Equal(t, width, actual)
+		}
+	})
+	t.Run("add-row-at", func(t *testing.T) {
+		file := NewFile()
+		dw, err := file.NewDirectWriter("Sheet1", 1<<20)
+		require.NoError(t, err)
+		_, err = dw.AddRow([]Cell{{Value: "a"}})
+		require.NoError(t, err)
+		_, err = dw.AddRowAt(1000, []Cell{{Value: "b"}, {Value: 2}}, RowOpts{Height: 30})
+		require.NoError(t, err)
+		_, err = dw.AddRow([]Cell{{Value: "c"}})
+		require.NoError(t, err)
+		for row, expected := range map[int]string{
+			0:             newInvalidRowNumberError(0).Error(),
+			5:             ErrDirectWriterRowOrder.Error(),
+			1001:          ErrDirectWriterRowOrder.Error(),
+			TotalRows + 1: ErrMaxRows.Error(),
+		} {
+			_, err = dw.AddRowAt(row, []Cell{{Value: "d"}})
+			assert.EqualError(t, err, expected, row)
+		}
+		_, err = dw.AddRowAt(1003, make([]Cell, TotalColumns+1))
+		assert.EqualError(t, err, ErrColumnNumber.Error())
+		rows, _, _ := dw.Stats()
+		assert.Equal(t, 1001, rows)
+		_, err = dw.AddRowAt(TotalRows, []Cell{{Value: "e"}})
+		require.NoError(t, err)
+		_, err = dw.AddRow([]Cell{{Value: "f"}})
+		assert.EqualError(t, err, ErrMaxRows.Error())
+		require.NoError(t, dw.Close())
+		assert.Equal(t, fmt.Sprintf(`<row r="1"><c t="str"><v>a</v></c></row><row r="1000" ht="30" customHeight="1"><c t="str"><v>b</v></c><c><v>2</v></c></row><row r="1001"><c t="str"><v>c</v></c></row><row r="%d"><c t="str"><v>e</v></c></row></sheetData></worksheet>`, TotalRows), string(dw.buf))
+		assert.Equal(t, fmt.Sprintf("A1:B%d", TotalRows), dw.worksheet.Dimension.Ref)
+		_, err = dw.AddRowAt(TotalRows, nil)
+		assert.EqualError(t, err, ErrDirectWriterClosed.Error())
+
+		var out bytes.Buffer
+		_, err = file.WriteTo(&out)
+		require.NoError(t, err)
+		f, err := OpenReader(&out)
+		require.NoError(t, err)
+		assert.Empty(t, f.validateParts())
+		for cell, expected := range map[string]string{"A1": "a", "A2": "", "A1000": "b", "B1000": "2", "A1001": "c"} {
+			val, err := f.GetCellValue("Sheet1", cell)
+			assert.NoError(t, err)
+			assert.Equal(t, expected, val, cell)
+		}
+		height, err := f.GetRowHeight("Sheet1", 1000)
+		assert.NoError(t, err)
+		assert.Equal(t, 30.0, height)
+	})
+	t.Run("cell-hyperlink", func(t *testing.T) {
+		file := NewFile()
+		dw, err := file.NewDirectWriter("Sheet1", 1)
+		require.NoError(t, err)
+		var out bytes.Buffer
+		ch := make(chan error)
+		go func() {
+			_, err := file.WriteTo(&out)
+			ch <- err
+		}()
+		waitDirectWriterOut(dw)
+		_, err = dw.AddRow([]Cell{{Value: "a"}, {Hyperlink: &CellHyperlink{Link: "https://github.com/xuri/excelize", Display: "Excelize", Tooltip: "Excelize on GitHub"}}})
+		require.NoError(t, err)
+		_, err = dw.AddRowAt(3, []Cell{{Value: "Go to A40", Hyperlink: &CellHyperlink{Link: "Sheet1!A40", LinkType: "Location"}}})
+		require.NoError(t, err)
+		_, err = dw.AddRow([]Cell{{Hyperlink: &CellHyperlink{Link: "Sheet1!A40", LinkType: "None"}}})
+		assert.EqualError(t, err, `invalid link type "None"`)
+		require.NoError(t, dw.Close())
+		require.NoError(t, <-ch)
+
+		f, err := OpenReader(&out)
+		require.NoError(t, err)
+		assert.Empty(t, f.validateParts())
+		for cell, expected := range map[string][2]string{"B1": {"Excelize", "https://github.com/xuri/excelize"}, "A3": {"Go to A40", "Sheet1!A40"}} {
+			val, err := f.GetCellValue("Sheet1", cell)
+			assert.NoError(t, err)
+			assert.Equal(t, expected[0], val, cell)
+			ok, target, err := f.GetCellHyperLink("Sheet1", cell)
+			assert.NoError(t, err)
+			assert.True(t, ok, cell)
+			assert.Equal(t, expected[1], target, cell)
+		}
+		ws, err := f.workSheetReader("Sheet1")
+		require.NoError(t, err)
+		require.Len(t, ws.Hyperlinks.Hyperlink, 2)
+		assert.Equal(t, xlsxHyperlink{Ref: "B1", RID: "rId1", Display: "Excelize", Tooltip: "Excelize on GitHub"}, ws.Hyperlinks.Hyperlink[0])
+	})
+	t.Run("error-value", func(t *testing.T) {
+		values := []ErrorValue{"#NULL!", "#DIV/0!", "#VALUE!", "#REF!", "#NAME?", "#NUM!", "#N/A", "#GETTING_DATA"}
+		file := NewFile()
+		dw, err := file.NewDirectWriter("Sheet1", 1<<20)
+		require.NoError(t, err)
+		row := make([]Cell, len(values))
+		for i, v := range values {
+			row[i] = Cell{Value: v}
+		}
+		_, err = dw.AddRow(row)
+		require.NoError(t, err)
+		for _, v := range []ErrorValue{"", "N/A", "#n/a", "#SPILL!"} {
+			_, err = dw.AddRow([]Cell{{Value: v}})
+			assert.EqualError(t, err, newInvalidErrorValue(string(v)).Error())
+		}
+		require.NoError(t, dw.Close())
+
+		var out bytes.Buffer
+		_, err = file.WriteTo(&out)
+		require.NoError(t, err)
+		f, err := OpenReader(&out)
+		require.NoError(t, err)
+		assert.Empty(t, f.validateParts())
+		for i, v := range values {
+			cell, _ := CoordinatesToCellName(i+1, 1)
+			val, err := f.GetCellValue("Sheet1", cell)
+			assert.NoError(t, err)
+			assert.Equal(t, string(v), val, cell)
+			typ, err := f.GetCellType("Sheet1", cell)
+			assert.NoError(t, err)
+			assert.Equal(t, CellTypeError, typ, cell)
+		}
+	})
+	t.Run("sheet-state", func(t *testing.T) {
+		file := NewFile()
+		file.NewSheet("Sheet2")
+		file.NewSheet("Sheet3")
+		dw2, err := file.NewDirectWriter("Sheet2", 1)
+		require.NoError(t, err)
+		dw3, err := file.NewDirectWriter("Sheet3", 1)
+		require.NoError(t, err)
+		assert.EqualError(t, dw2.SetSheetState(SheetStateVeryHidden+1), ErrSheetState.Error())
+		require.NoError(t, dw2.SetSheetState(SheetStateVeryHidden))
+		require.NoError(t, dw3.SetSheetState(SheetStateHidden))
+		require.NoError(t, dw3.SetSheetState(SheetStateVisible))
+		for _, dw := range []*DirectWriter{dw2, dw3} {
+			_, err = dw.AddRow([]Cell{{Value: dw.Sheet}})
+			require.NoError(t, err)
+			require.NoError(t, dw.Close())
+		}
+		assert.EqualError(t, dw2.SetSheetState(SheetStateVisible), ErrDirectWriterClosed.Error())
+		var out bytes.Buffer
+		_, err = file.WriteTo(&out)
+		require.NoError(t, err)
+		workbook := readZipEntry(t, out.Bytes(), "xl/workbook.xml")
+		assert.Contains(t, workbook, `<sheet name="Sheet2" sheetId="2" r:id="rId4" state="veryHidden"></sheet>`)
+		assert.Contains(t, workbook, `<sheet name="Sheet3" sheetId="3" r:id="rId5"></sheet>`)
+
+		f, err := OpenReader(&out)
+		require.NoError(t, err)
+		assert.Empty(t, f.validateParts())
+		assert.True(t, f.GetSheetVisible("Sheet1"))
+		assert.False(t, f.GetSheetVisible("Sheet2"))
+		assert.True(t, f.GetSheetVisible("Sheet3"))
+
+		// the last visible worksheet can't be hidden
+		file = NewFile()
+		dw, err := file.NewDirectWriter("Sheet1", 1)
+		require.NoError(t, err)
+		assert.EqualError(t, dw.SetSheetState(SheetStateHidden), ErrSheetVisible.Error())
+		file.NewSheet("Sheet2")
+		dw2, err = file.NewDirectWriter("Sheet2", 1)
+		require.NoError(t, err)
+		require.NoError(t, dw.SetSheetState(SheetStateVeryHidden))
+		assert.EqualError(t, dw2.SetSheetState(SheetStateHidden), ErrSheetVisible.Error())
+		require.NoError(t, dw.Close())
+		require.NoError(t, dw2.Close())
+	})
+	t.Run("rotate", func(t *testing.T) {
+		file := NewFile()
+		dw, err := file.NewDirectWriter("Sheet1", 1<<20)
+		require.NoError(t, err)
+		require.NoError(t, dw.SetColWidth(1, 1, 20))
+		var parts [3]bytes.Buffer
+		for i, n := range []int{3, 2} {
+			for row := 1; row <= n; row++ {
+				_, err = dw.AddRow([]Cell{{Value: "Part" + strconv.Itoa(i+1)}, {Value: row}})
+				require.NoError(t, err)
+			}
+			require.NoError(t, dw.MergeCell("A1", "B1"))
+			require.NoError(t, dw.Rotate(&parts[i]))
+		}
+		_, err = dw.AddRow([]Cell{{Value: "Part3"}, {Value: 1}})
+		require.NoError(t, err)
+		require.NoError(t, dw.Close())
+		assert.EqualError(t, dw.Rotate(&parts[2]), ErrDirectWriterClosed.Error())
+		var out bytes.Buffer
+		_, err = file.WriteTo(&out)
+		require.NoError(t, err)
+		parts[2].WriteString(readZipEntry(t, out.Bytes(), "xl/worksheets/sheet1.xml"))
+
+		for i, dimension := range []string{"A1:B3", "A1:B2", "A1:B1"} {
+			var ws xlsxWorksheet
+			require.NoError(t, xml.Unmarshal(parts[i].Bytes(), &ws), parts[i].String())
+			assert.Equal(t, dimension, ws.Dimension.Ref)
+			assert.Equal(t, 20.0, ws.Cols.Col[0].Width)
+			assert.Len(t, ws.SheetData.Row, 3-i)
+			assert.Equal(t, "Part"+strconv.Itoa(i+1), ws.SheetData.Row[0].C[0].V)
+			if i < 2 {
+				assert.Equal(t, "A1:B1", ws.MergeCells.Cells[0].Ref)
+				assert.Equal(t, 1, ws.MergeCells.Count)
+			} else {
+				assert.Nil(t, ws.MergeCells)
+			}
+		}
+		f, err := OpenReader(&out)
+		require.NoError(t, err)
+		assert.Empty(t, f.validateParts())
+		rows, err := f.GetRows("Sheet1")
+		require.NoError(t, err)
+		assert.Equal(t, [][]string{{"Part3", "1"}}, rows)
+
+		// the writer can't be rotated once comments are added
+		file = NewFile()
+		dw, err = file.NewDirectWriter("Sheet1", 1)
+		require.NoError(t, err)
+		require.NoError(t, dw.AddComment("A1", Comment{Author: "Excelize", Text: "Note"}))
+		assert.EqualError(t, dw.Rotate(&out), "Can't rotate since comments, hyperlinks or pictures already added.")
+		require.NoError(t, dw.Close())
+
+		// Test rotate the writer attached to the writer of WriteTo.
+		file = NewFile()
+		dw, err = file.NewDirectWriter("Sheet1", 1)
+		require.NoError(t, err)
+		out.Reset()
+		ch := make(chan int64)
+		go func() {
+			n, err := dw.WriteTo(&out)
+			assert.NoError(t, err)
+			ch <- n
+		}()
+		waitDirectWriterOut(dw)
+		for i := range parts {
+			parts[i].Reset()
+		}
+		for i, n := range []int{3, 2, 1} {
+			for row := 1; row <= n; row++ {
+				_, err = dw.AddRow([]Cell{{Value: "Part" + strconv.Itoa(i+1)}, {Value: row}})
+				require.NoError(t, err)
+			}
+			if i < 2 {
+				require.NoError(t, dw.Rotate(&parts[i]))
+			}
+			if i == 0 {
+				assert.Equal(t, int64(out.Len()), <-ch)
+			}
+		}
+		require.NoError(t, dw.Close())
+		for i, part := range []*bytes.Buffer{&out, &parts[0], &parts[1]} {
+			var ws xlsxWorksheet
+			require.NoError(t, xml.Unmarshal(part.Bytes(), &ws), part.String())
+			assert.Len(t, ws.SheetData.Row, 3-i)
+			assert.Equal(t, "Part"+strconv.Itoa(i+1), ws.SheetData.Row[0].C[0].V)
+			assert.Equal(t, 1, ws.SheetData.Row[0].R)
+		}
+	})
+	t.Run("on-flush", func(t *testing.T) {
+		for _, streaming := range []bool{false, true} {
+			file := NewFile()
+			dw, err := file.NewDirectWriter("Sheet1", 64)
+			require.NoError(t, err)
+			var chunks [][]byte
+			var total int64
+			dw.OnFlush(func(chunk []byte, totalWritten int64) {
+				total += int64(len(chunk))
+				assert.Equal(t, total, totalWritten)
+				chunks = append(chunks, append([]byte(nil), chunk...))
+			})
+			var out bytes.Buffer
+			ch := make(chan error)
+			if streaming {
+				go func() {
+					_, err := file.WriteTo(&out)
+					ch <- err
+				}()
+				waitDirectWriterOut(dw)
+			}
+			for row := 1; row <= 100; row++ {
+				_, err = dw.AddRow([]Cell{{Value: row}, {Value: "Row" + strconv.Itoa(row)}})
+				require.NoError(t, err)
+			}
+			require.NoError(t, dw.Close())
+			if streaming {
+				require.NoError(t, <-ch)
+				assert.Greater(t, len(chunks), 2)
+			} else {
+				_, err = file.WriteTo(&out)
+				require.NoError(t, err)
+				assert.Len(t, chunks, 2)
+			}
+			assert.Equal(t, readZipEntry(t, out.Bytes(), "xl/worksheets/sheet1.xml"), string(bytes.Join(chunks, nil)))
+		}
+	})
+	t.Run("auto-date-style", func(t *testing.T) {
+		file := NewFile()
+		file.NewSheet("Sheet2")
+		date := time.Date(2021, time.January, 2, 0, 0, 0, 0, time.UTC)
+		style, err := file.NewStyle(&Style{NumFmt: 15})
+		require.NoError(t, err)
+		for _, sheet := range []string{"Sheet1", "Sheet2"} {
+			dw, err := file.NewDirectWriter(sheet, 1)
+			require.NoError(t, err)
+			if sheet == "Sheet1" {
+				assert.EqualError(t, dw.SetAutoDateStyle(1), ErrParameterInvalid.Error())
+				require.NoError(t, dw.SetAutoDateStyle(14))
+			}
+			_, err = dw.AddRow([]Cell{{Value: date}, {Value: date, StyleID: style}, {Value: 1.5}})
+			require.NoError(t, err)
+			require.NoError(t, dw.Close())
+			assert.EqualError(t, dw.SetAutoDateStyle(14), ErrDirectWriterClosed.Error())
+		}
+		var out bytes.Buffer
+		_, err = file.WriteTo(&out)
+		require.NoError(t, err)
+
+		f, err := OpenReader(&out)
+		require.NoError(t, err)
+		assert.Empty(t, f.validateParts())
+		rows, err := f.GetRows("Sheet1")
+		require.NoError(t, err)
+		assert.Equal(t, [][]string{{"01-02-21", "2-Jan-21", "1.5"}}, rows)
+		rows, err = f.GetRows("Sheet2")
+		require.NoError(t, err)
+		assert.Equal(t, [][]string{{"44198", "2-Jan-21", "1.5"}}, rows)
+	})
+	t.Run("shared-strings-mode", func(t *testing.T) {
+		file := NewFile()
+		require.NoError(t, file.SetCellValue("Sheet1", "A1", "Existing"))
+		file.NewSheet("Sheet2")
+		dw1, err := file.NewDirectWriter("Sheet1", 1)
+		require.NoError(t, err)
+		dw2, err := file.NewDirectWriter("Sheet2", 1)
+		require.NoError(t, err)
+		dw1.SetSharedStrings(true)
+		dw2.SetSharedStrings(true)
+		var out bytes.Buffer
+		ch := make(chan error)
+		go func() {
+			_, err := file.WriteTo(&out)
+			ch <- err
+		}()
+		waitDirectWriterOut(dw1)
+		for row := 0; row < 3; row++ {
+			_, err = dw1.AddRow([]Cell{{Value: "Existing"}, {Value: []byte("Shared")}, {Value: row}})
+			require.NoError(t, err)
+		}
+		_, err = dw1.AddRows([][]Cell{{{Value: "Shared"}, {Formula: `"Formula"`, Value: "Formula"}, {Hyperlink: &CellHyperlink{Link: "https://github.com", Display: "Link"}}}})
+		require.NoError(t, err)
+		require.NoError(t, dw1.Close())
+		waitDirectWriterOut(dw2)
+		_, err = dw2.AddRow([]Cell{{Value: "Shared"}, {Value: "Sheet2"}})
+		require.NoError(t, err)
+		require.NoError(t, dw2.Close())
+		require.NoError(t, <-ch)
+		sheet := readZipEntry(t, out.Bytes(), "xl/worksheets/sheet1.xml")
+		assert.Contains(t, sheet, `<row r="1"><c t="s"><v>0</v></c><c t="s"><v>1</v></c><c><v>0</v></c></row>`)
+		assert.Contains(t, sheet, `<c t="str"><f>&#34;Formula&#34;</f><v>Formula</v></c><c t="s"><v>2</v></c>`)
+		assert.Contains(t, readZipEntry(t, out.Bytes(), "xl/worksheets/sheet2.xml"), `<row r="1"><c t="s"><v>1</v></c><c t="s"><v>3</v></c></row>`)
+		assert.Contains(t, readZipEntry(t, out.Bytes(), "xl/sharedStrings.xml"), `<si><t>Existing</t></si><si><t>Shared</t></si><si><t>Link</t></si><si><t>Sheet2</t></si>`)
+
+		f, err := OpenReader(&out)
+		require.NoError(t, err)
+		assert.Empty(t, f.validateParts())
+		rows, err := f.GetRows("Sheet1")
+		require.NoError(t, err)
+		assert.Equal(t, [][]string{{"Existing", "Shared", "0"}, {"Existing", "Shared", "1"}, {"Existing", "Shared", "2"}, {"Shared", "Formula", "Link"}}, rows)
+		rows, err = f.GetRows("Sheet2")
+		require.NoError(t, err)
+		assert.Equal(t, [][]string{{"Shared", "Sheet2"}}, rows)
+	})
+	t.Run("worksheet-attrs", func(t *testing.T) {
+		file := NewFile()
+		dw, err := file.NewDirectWriter("Sheet1", 1)
+		require.NoError(t, err)
+		for _, attrs := range []string{`foo`, `foo="1`, `foo="1"><bar`, `foo="1"/><bar`} {
+			assert.Error(t, dw.SetWorksheetAttrs(attrs), attrs)
+		}
+		require.NoError(t, dw.SetWorksheetAttrs(`xmlns:foo="urn:foo" mc:Ignorable="x14ac foo" foo:bar="a&amp;b"`))
+		var out bytes.Buffer
+		ch := make(chan error)
+		go func() {
+			_, err := file.WriteTo(&out)
+			ch <- err
+		}()
+		waitDirectWriterOut(dw)
+		_, err = dw.AddRow([]Cell{{Value: 1}})
+		require.NoError(t, err)
+		assert.EqualError(t, dw.SetWorksheetAttrs(`foo="1"`), "Can't set worksheet attributes since first data already written.")
+		require.NoError(t, dw.Close())
+		assert.EqualError(t, dw.SetWorksheetAttrs(`foo="1"`), ErrDirectWriterClosed.Error())
+		require.NoError(t, <-ch)
+		sheet := readZipEntry(t, out.Bytes(), "xl/worksheets/sheet1.xml")
+		assert.Contains(t, sheet, ` mc:Ignorable="x14ac foo" xmlns:mo=`)
+		assert.Equal(t, 1, strings.Count(sheet, "mc:Ignorable"))
+		assert.Contains(t, sheet, ` xr:uid="{00000000-0001-0000-0000-000000000000}" xmlns:foo="urn:foo" foo:bar="a&amp;b"><dimension ref="A1">`)
+
+		f, err := OpenReader(&out)
+		require.NoError(t, err)
+		assert.Empty(t, f.validateParts())
+		cell, err := f.GetCellValue("Sheet1", "A1")
+		require.NoError(t, err)
+		assert.Equal(t, "1", cell)
+	})
+	t.Run("numeric-row", func(t *testing.T) {
+		vals := []float64{1, -2.5, 1e21, 0.1 + 0.2, math.Inf(-1), math.NaN()}
+		styleIDs := []int{0, 3, 4}
+		file := NewFile()
+		dw, err := file.NewDirectWriter("Sheet1", 1<<20)
+		require.NoError(t, err)
+		_, err = dw.AddNumericRow(vals, styleIDs)
+		require.NoError(t, err)
+		_, err = dw.AddNumericRow(vals[:2], nil)
+		require.NoError(t, err)
+		// The numeric row is encoded like the generic row of the same values.
+		expected := NewFile()
+		edw, err := expected.NewDirectWriter("Sheet1", 1<<20)
+		require.NoError(t, err)
+		row := make([]Cell, len(vals))
+		for i, v := range vals {
+			row[i].Value = v
+			if i < len(styleIDs) {
+				row[i].StyleID = styleIDs[i]
+			}
+		}
+		_, err = edw.AddRow(row)
+		require.NoError(t, err)
+		_, err = edw.AddRow([]Cell{{Value: vals[0]}, {Value: vals[1]}})
+		require.NoError(t, err)
+		assert.Equal(t, string(edw.buf), string(dw.buf))
+		assert.Equal(t, edw.MaxColumnLengths(), dw.MaxColumnLengths())
+		rows, _, _ := dw.Stats()
+		assert.Equal(t, 2, rows)
+
+		_, err = dw.AddNumericRow(make([]float64, TotalColumns+1), nil)
+		assert.EqualError(t, err, ErrColumnNumber.Error())
+		require.NoError(t, dw.Close())
+		_, err = dw.AddNumericRow(vals, nil)
+		assert.EqualError(t, err, ErrDirectWriterClosed.Error())
+
+		file = NewFile(Options{RejectNonFinite: true})
+		dw, err = file.NewDirectWriter("Sheet1", 1<<20)
+		require.NoError(t, err)
+		_, err = dw.AddNumericRow(vals, nil)
+		assert.EqualError(t, err, ErrNonFiniteNumber.Error())
+		assert.Empty(t, dw.buf)
+		dw.rowCount = TotalRows
+		_, err = dw.AddNumericRow(vals[:1], nil)
+		assert.EqualError(t, err, ErrMaxRows.Error())
+	})
+	t.Run("output-buffer", func(t *testing.T) {
+		export := func(size int) (*countingFile, string) {
+			tmp, err := ioutil.TempFile(os.TempDir(), "excelize-")
+			require.NoError(t, err)
+			defer os.Remove(tmp.Name())
+			out := &countingFile{File: tmp}
+			file := NewFile()
+			dw, err := file.NewDirectWriter("Sheet1", 1)
+			require.NoError(t, err)
+			assert.Equal(t, ErrParameterInvalid, dw.SetOutputBuffer(-1))
+			if size != defaultOutputBufferSize {
+				require.NoError(t, dw.SetOutputBuffer(size))
+			}
+			ch := make(chan error)
+			go func() {
+				_, err := dw.WriteTo(out)
+				ch <- err
+			}()
+			waitDirectWriterOut(dw)
+			assert.EqualError(t, dw.SetOutputBuffer(size), "Can't set output buffer since the writer is already registered.")
+			for i := 0; i < 100; i++ {
+				_, err = dw.AddRow([]Cell{{Value: i}})
+				require.NoError(t, err)
+			}
+			require.NoError(t, dw.Flush())
+			written, _ := tmp.Seek(0, io.SeekCurrent)
+			assert.Equal(t, dw.bytesWritten, written)
+			_, err = dw.AddRow([]Cell{{Value: "last"}})
+			require.NoError(t, err)
+			require.NoError(t, dw.Close())
+			require.NoError(t, <-ch)
+			assert.EqualError(t, dw.SetOutputBuffer(size), ErrDirectWriterClosed.Error())
+			content, err := ioutil.ReadFile(tmp.Name())
+			require.NoError(t, err)
+			assert.NoError(t, tmp.Close())
+			return out, string(content)
+		}
+		buffered, content := export(defaultOutputBufferSize)
+		unbuffered, expected := export(0)
+		assert.Equal(t, expected, content)
+		assert.Equal(t, 2, buffered.writes)
+		assert.Equal(t, 104, unbuffered.writes)
+		assert.True(t, strings.HasSuffix(content, `<row r="101"><c t="str"><v>last</v></c></row></sheetData></worksheet>`))
+	})
+	t.Run("ref-less-read", func(t *testing.T) {
+		file := NewFile()
+		dw, err := file.NewDirectWriter("Sheet1", 1)
+		require.NoError(t, err)
+		var out bytes.Buffer
+		ch := make(chan error)
+		go func() {
+			_, err := file.WriteTo(&out)
+			ch <- err
+		}()
+		_, err = dw.AddRow([]Cell{{Value: "A1"}, {Value: "B1"}, {Value: 3}})
+		require.NoError(t, err)
+		_, err = dw.AddRowAt(3, []Cell{{Value: "A3"}, {}, {Value: true}, {Value: "D3"}})
+		require.NoError(t, err)
+		_, err = dw.AddRawRow([]byte(`<row r="4"><c r="C4" t="str"><v>C4</v></c><c t="str"><v>D4</v></c></row>`))
+		require.NoError(t, err)
+		_, err = dw.AddNumericRow([]float64{1.5, 2.5}, nil)
+		require.NoError(t, err)
+		require.NoError(t, dw.Close())
+		require.NoError(t, <-ch)
+		assert.NotContains(t, readZipEntry(t, out.Bytes(), "xl/worksheets/sheet1.xml"), `<c r="A`)
+
+		f, err := OpenReader(&out)
+		require.NoError(t, err)
+		for cell, expected := range map[string]string{
+			"A1": "A1", "B1": "B1", "C1": "3", "D1": "",
+			"A2": "", "B2": "",
+			"A3": "A3", "B3": "", "C3": "TRUE", "D3": "D3", "E3": "",
+			"A4": "", "B4": "", "C4": "C4", "D4": "D4",
+			"A5": "1.5", "B5": "2.5", "A6": "",
+		} {
+			val, err := f.GetCellValue("Sheet1", cell)
+			require.NoError(t, err)
+			assert.Equal(t, expected, val, cell)
+		}
+		cellType, err := f.GetCellType("Sheet1", "C3")
+		require.NoError(t, err)
+		assert.Equal(t, CellTypeBool, cellType)
+		require.NoError(t, f.SetCellValue("Sheet1", "B3", "B3"))
+		val, err := f.GetCellValue("Sheet1", "B3")
+		require.NoError(t, err)
+		assert.Equal(t, "B3", val)
+	})
+	t.Run("pictures", func(t *testing.T) {
+		png, err := ioutil.ReadFile(filepath.Join("test", "images", "excel.png"))
+		require.NoError(t, err)
+		jpg, err := ioutil.ReadFile(filepath.Join("test", "images", "excel.jpg"))
+		require.NoError(t, err)
+		file := NewFile()
+		dw, err := file.NewDirectWriter("Sheet1", 1)
+		require.NoError(t, err)
+		var out bytes.Buffer
+		ch := make(chan error)
+		go func() {
+			_, err := file.WriteTo(&out)
+			ch <- err
+		}()
+		for i := 1; i <= 3; i++ {
+			_, err = dw.AddRow([]Cell{{Value: "Product " + strconv.Itoa(i)}})
+			require.NoError(t, err)
+			require.NoError(t, dw.AddPictureFromBytes("B"+strconv.Itoa(i), `{"x_scale": 0.1, "y_scale": 0.1}`, "Thumbnail", ".png", png))
+		}
+		require.NoError(t, dw.AddPicture("C1", filepath.Join("test", "images", "excel.jpg"), ""))
+		assert.True(t, os.IsNotExist(dw.AddPicture("C1", filepath.Join("test", "images", "missing.png"), "")))
+		assert.EqualError(t, dw.AddPicture("C1", filepath.Join("test", "Book1.xlsx"), ""), ErrImgExt.Error())
+		assert.EqualError(t, dw.AddPictureFromBytes("B0", "", "", ".png", png), `cannot convert cell "B0" to coordinates: `+newInvalidCellNameError("B0").Error())
+		assert.EqualError(t, dw.AddPictureFromBytes("B1", "", "", ".svg", png), ErrImgExt.Error())
+		assert.Error(t, dw.AddPictureFromBytes("B1", "{", "", ".png", png))
+		assert.Error(t, dw.AddPictureFromBytes("B1", "", "", ".png", []byte("foo")))
+		assert.EqualError(t, dw.Rotate(io.Discard), "Can't rotate since comments, hyperlinks or pictures already added.")
+		require.NoError(t, dw.Close())
+		assert.EqualError(t, dw.AddPictureFromBytes("B1", "", "", ".png", png), ErrDirectWriterClosed.Error())
+		assert.EqualError(t, dw.AddPicture("B1", filepath.Join("test", "images", "excel.png"), ""), ErrDirectWriterClosed.Error())
+		require.NoError(t, <-ch)
+
+		var media int
+		z, err := zip.NewReader(bytes.NewReader(out.Bytes()), int64(out.Len()))
+		require.NoError(t, err)
+		for _, zf := range z.File {
+			if strings.HasPrefix(zf.Name, "xl/media/") {
+				media++
+			}
+		}
+		assert.Equal(t, 2, media)
+		assert.Contains(t, readZipEntry(t, out.Bytes(), "xl/worksheets/sheet1.xml"), `</sheetData><drawing `)
+		assert.Equal(t, 4, strings.Count(readZipEntry(t, out.Bytes(), "xl/drawings/drawing1.xml"), "<xdr:pic>"))
+
+		f, err := OpenReader(&out)
+		require.NoError(t, err)
+		assert.Empty(t, f.validateParts())
+		for cell, expected := range map[string][]byte{"B1": png, "B2": png, "B3": png, "C1": jpg} {
+			_, raw, err := f.GetPicture("Sheet1", cell)
+			require.NoError(t, err)
+			assert.Equal(t, expected, raw, cell)
+		}
+		val, err := f.GetCellValue("Sheet1", "A3")
+		require.NoError(t, err)
+		assert.Equal(t, "Product 3", val)
+
+		// the pictures of each sheet are placed in their own drawing
+		file = NewFile()
+		dw, err = file.NewDirectWriter("Sheet1", 1<<20)
+		require.NoError(t, err)
+		require.NoError(t, dw.AddPictureFromBytes("A1", "", "", ".png", png))
+		require.NoError(t, dw.NextSheet("Sheet2"))
+		require.NoError(t, dw.AddPictureFromBytes("A1", "", "", ".jpg", jpg))
+		require.NoError(t, dw.Close())
+		out.Reset()
+		_, err = file.WriteTo(&out)
+		require.NoError(t, err)
+		f, err = OpenReader(&out)
+		require.NoError(t, err)
+		assert.Empty(t, f.validateParts())
+		for sheet, expected := range map[string][]byte{"Sheet1": png, "Sheet2": jpg} {
+			_, raw, err := f.GetPicture(sheet, "A1")
+			require.NoError(t, err)
+			assert.Equal(t, expected, raw, sheet)
+		}
+	})
+	t.Run("write-error", func(t *testing.T) {
+		for _, opts := range []Options{{}, {CompressionConcurrency: 2}} {
+			file := NewFile(opts)
+			ctx, cancel := context.WithCancel(context.Background())
+			var writers []*DirectWriter
+			for i, sheet := range []string{"Sheet1", "Sheet2", "Sheet3"} {
+				c := context.Background()
+				if i == 1 {
+					c = ctx
+				}
+				dw, err := file.NewDirectWriterContext(c, sheet, 1<<20)
+				require.NoError(t, err)
+				_, err = dw.AddRow([]Cell{{Value: sheet}})
+				require.NoError(t, err)
+				writers = append(writers, dw)
+			}
+			require.NoError(t, writers[0].Close())
+			cancel()
+			require.NoError(t, writers[2].Close())
+			_, err := file.WriteTo(io.Discard)
+			assert.EqualError(t, err, `failed to write sheet "Sheet2" (xl/worksheets/sheet2.xml): context canceled`)
+			var werr *WriteError
+			require.True(t, errors.As(err, &werr))
+			assert.Equal(t, "Sheet2", werr.Sheet)
+			assert.Equal(t, context.Canceled, werr.Err)
+		}
+
+		// the error of the underlying writer is reported for the worksheet
+		// which was written at that time
+		file := NewFile()
+		dw, err := file.NewDirectWriter("Sheet1", 1024)
+		require.NoError(t, err)
+		ch := make(chan error)
+		go func() {
+			_, err := file.WriteTo(&failingWriter{limit: 4096})
+			ch <- err
+		}()
+		waitDirectWriterOut(dw)
+		for i := 0; err == nil && i < 100000; i++ {
+			_, err = dw.AddRow([]Cell{{Value: i}, {Value: strconv.FormatInt(int64(i)*7919, 36)}})
+		}
+		assert.EqualError(t, err, errFailingWriter.Error())
+		assert.EqualError(t, dw.Close(), errFailingWriter.Error())
+		err = <-ch
+		assert.EqualError(t, err, `failed to write sheet "Sheet1" (xl/worksheets/sheet1.xml): `+errFailingWriter.Error())
+		assert.True(t, errors.Is(err, errFailingWriter))
+	})
+	t.Run("max-bytes", func(t *testing.T) {
+		file := NewFile()
+		dw, err := file.NewDirectWriter("Sheet1", 1024)
+		require.NoError(t, err)
+		dw.SetMaxBytes(16 << 10)
+		var buf bytes.Buffer
+		ch := make(chan error)
+		go func() {
+			_, err := file.WriteTo(&buf)
+			ch <- err
+		}()
+		waitDirectWriterOut(dw)
+		var rows int
+		for ; rows < 100000; rows++ {
+			if _, err = dw.AddRow([]Cell{{Value: rows}, {Value: "row"}, {Hyperlink: &CellHyperlink{Link: "https://github.com", Display: "link"}}}); err != nil {
+				break
+			}
+		}
+		assert.EqualError(t, err, ErrSheetTooLarge.Error())
+		n, flushed, buffered := dw.Stats()
+		assert.Equal(t, rows, n)
+		assert.LessOrEqual(t, flushed+int64(buffered), int64(16<<10))
+		assert.Len(t, dw.hyperlinks, rows)
+		_, err = dw.AddNumericRow(make([]float64, 4096), nil)
+		assert.EqualError(t, err, ErrSheetTooLarge.Error())
+		_, err = dw.AddRawRow([]byte(`<row r="0">` + strings.Repeat("<c><v>1</v></c>", 4096) + `</row>`))
+		assert.EqualError(t, err, ErrSheetTooLarge.Error())
+		assert.Equal(t, 3, len(dw.MaxColumnLengths()))
+		n, _, _ = dw.Stats()
+		assert.Equal(t, rows, n)
+		require.NoError(t, dw.Close())
+		require.NoError(t, <-ch)
+
+		// the worksheet is closed after the last row which fits
+		f, err := OpenReader(&buf)
+		require.NoError(t, err)
+		result, err := f.GetRows("Sheet1")
+		require.NoError(t, err)
+		require.Len(t, result, rows)
+		assert.Equal(t, []string{strconv.Itoa(rows - 1), "row", "link"}, result[rows-1])
+		require.NoError(t, f.Close())
+
+		// a zero limit removes the cap
+		dw, err = NewFile().NewDirectWriter("Sheet1", 1<<20)
+		require.NoError(t, err)
+		dw.SetMaxBytes(1)
+		_, err = dw.AddRow([]Cell{{Value: 1}})
+		assert.EqualError(t, err, ErrSheetTooLarge.Error())
+		dw.SetMaxBytes(0)
+		_, err = dw.AddRow([]Cell{{Value: 1}})
+		assert.NoError(t, err)
+		require.NoError(t, dw.Close())
+
+		// the column lengths of a discarded row are restored
+		dw, err = NewFile().NewDirectWriter("Sheet1", 1<<20)
+		require.NoError(t, err)
+		_, err = dw.AddRow([]Cell{{Value: "ab"}, {Value: 1}})
+		require.NoError(t, err)
+		dw.SetMaxBytes(64)
+		_, err = dw.AddRow([]Cell{{Value: strings.Repeat("c", 64)}, {Value: 123}, {Value: "d"}})
+		assert.EqualError(t, err, ErrSheetTooLarge.Error())
+		_, err = dw.AddNumericRow([]float64{123456789, 1, 2, 3, 4, 5, 6, 7}, nil)
+		assert.EqualError(t, err, ErrSheetTooLarge.Error())
+		assert.Equal(t, []int{2, 1}, dw.MaxColumnLengths())
+		require.NoError(t, dw.Close())
+	})
+	t.Run("outline-props", func(t *testing.T) {
+		file := NewFile()
+		dw, err := file.NewDirectWriter("Sheet1", 1)
+		require.NoError(t, err)
+		require.NoError(t, dw.SetTabColor("#FF0000"))
+		require.NoError(t, dw.SetOutlineProps(true, true))
+		require.NoError(t, dw.SetOutlineProps(false, false))
+		assert.Contains(t, string(dw.buildHeader()), `<sheetPr><tabColor rgb="FFFF0000"></tabColor><outlinePr summaryBelow="false" summaryRight="false" showOutlineSymbols="true"></outlinePr></sheetPr>`)
+
+		var out bytes.Buffer
+		ch := make(chan error)
+		go func() {
+			_, err := file.WriteTo(&out)
+			ch <- err
+		}()
+		waitDirectWriterOut(dw)
+		_, err = dw.AddRow([]Cell{{Value: "total"}})
+		require.NoError(t, err)
+		_, err = dw.AddRow([]Cell{{Value: 1}}, RowOpts{OutlineLevel: 1})
+		require.NoError(t, err)
+		assert.EqualError(t, dw.SetOutlineProps(true, true), "Can't set outline properties since first data already written.")
+		require.NoError(t, dw.Close())
+		require.NoError(t, <-ch)
+
+		f, err := OpenReader(&out)
+		require.NoError(t, err)
+		assert.Empty(t, f.validateParts())
+		var summaryBelow OutlineSummaryBelow
+		require.NoError(t, f.GetSheetPrOptions("Sheet1", &summaryBelow))
+		assert.False(t, bool(summaryBelow))
+		ws, err := f.workSheetReader("Sheet1")
+		require.NoError(t, err)
+		assert.Equal(t, &xlsxOutlinePr{ShowOutlineSymbols: true}, ws.SheetPr.OutlinePr)
+		require.NoError(t, f.Close())
+	})
+	t.Run("protect-sheet", func(t *testing.T) {
+		file, row, _ := setupTestFileRow()
+		dw, err := file.NewDirectWriter("Sheet1", 1)
+		require.NoError(t, err)
+		var out bytes.Buffer
+		ch := make(chan error)
+		go func() {
+			_, err := file.WriteTo(&out)
+			ch <- err
+		}()
+		waitDirectWriterOut(dw)
+		_, err = dw.AddRow(row)
+		require.NoError(t, err)
+		require.NoError(t, dw.MergeCell("A1", "B1"))
+		// the protection may be set after the first data is flushed
+		require.NoError(t, dw.ProtectSheet(&FormatSheetProtection{Password: "password", SelectLockedCells: true}))
+		require.NoError(t, dw.Close())
+		require.NoError(t, <-ch)
+
+		sheet := readZipEntry(t, out.Bytes(), "xl/worksheets/sheet1.xml")
+		f, err := OpenReader(&out)
+		require.NoError(t, err)
+		assert.Empty(t, f.validateParts())
+		assert.Less(t, strings.Index(sheet, "</sheetData>"), strings.Index(sheet, "<sheetProtection "))
+		assert.Less(t, strings.Index(sheet, "<sheetProtection "), strings.Index(sheet, "<mergeCells "))
+		ws, err := f.workSheetReader("Sheet1")
+		require.NoError(t, err)
+		require.NotNil(t, ws.SheetProtection)
+		assert.Equal(t, genSheetPasswd("password"), ws.SheetProtection.Password)
+		assert.True(t, ws.SheetProtection.Sheet)
+		assert.True(t, ws.SheetProtection.SelectLockedCells)
+		assert.False(t, ws.SheetProtection.Objects)
+		require.NoError(t, f.Close())
+	})
+	t.Run("spans", func(t *testing.T) {
+		file := NewFile()
+		dw, err := file.NewDirectWriter("Sheet1", 1<<20)
+		require.NoError(t, err)
+		dw.SetEmitSpans(true)
+		_, err = dw.AddRow([]Cell{{Value: 1}, {Value: "b"}, {Value: true}}, RowOpts{Height: 20})
+		require.NoError(t, err)
+		_, err = dw.AddRow([]Cell{{Value: 1}})
+		require.NoError(t, err)
+		_, err = dw.AddRow(nil)
+		require.NoError(t, err)
+		_, err = dw.AddNumericRow([]float64{1, 2, 3, 4, 5}, nil)
+		require.NoError(t, err)
+		_, err = dw.AddRawRow([]byte(`<row r="5"><c><v>1</v></c></row>`))
+		require.NoError(t, err)
+		dw.SetEmitSpans(false)
+		_, err = dw.AddRow([]Cell{{Value: 1}, {Value: 2}})
+		require.NoError(t, err)
+		require.NoError(t, dw.Close())
+		var buf bytes.Buffer
+		_, err = file.WriteTo(&buf)
+		require.NoError(t, err)
+		sheet := readZipEntry(t, buf.Bytes(), "xl/worksheets/sheet1.xml")
+		for _, row := range []string{
+			`<row r="1" spans="1:3" ht="20" customHeight="1">`,
+			`<row r="2" spans="1:1">`,
+			`<row r="3">`,
+			`<row r="4" spans="1:5">`,
+			`<row r="5">`,
+			`<row r="6">`,
+		} {
+			assert.Contains(t, sheet, row)
+		}
+		f, err := OpenReader(&buf)
+		require.NoError(t, err)
+		rows, err := f.GetRows("Sheet1")
+		require.NoError(t, err)
+		assert.Equal(t, [][]string{{"1", "b", "TRUE"}, {"1"}, nil, {"1", "2", "3", "4", "5"}, {"1"}, {"1", "2"}}, rows)
+		require.NoError(t, f.Close())
+	})
+	t.Run("async-flush", func(t *testing.T) {
+		const depth, maxBufferSize, rows = 4, 1024, 5000
+		file := NewFile()
+		dw, err := file.NewDirectWriter("Sheet1", maxBufferSize)
+		require.NoError(t, err)
+		assert.EqualError(t, dw.SetAsyncFlush(-1), ErrParameterInvalid.Error())
+		require.NoError(t, dw.SetAsyncFlush(depth))
+		var chunks int
+		dw.OnFlush(func(chunk []byte, totalWritten int64) { chunks++ })
+		pr, pw := io.Pipe()
+		ch := make(chan error)
+		go func() {
+			_, err := dw.WriteTo(pw)
+			_ = pw.CloseWithError(err)
+			ch <- err
+		}()
+		waitDirectWriterOut(dw)
+		assert.EqualError(t, dw.SetAsyncFlush(1), "Can't set async flush since the writer is already registered.")
+
+		// the producer makes progress while nothing is read from the pipe
+		added := make(chan error)
+		go func() {
+			for i := 0; i < rows; i++ {
+				if _, err := dw.AddRow([]Cell{{Value: i}, {Value: "async"}}); err != nil {
+					added <- err
+					return
+				}
+			}
+			added <- nil
+		}()
+		var flushed int64
+		for {
+			time.Sleep(20 * time.Millisecond)
+			_, n, _ := dw.Stats()
+			if n > 0 && n == flushed {
+				break
+			}
+			flushed = n
+		}
+		// up to the chunk being written, the queued chunks and the chunk being queued
+		assert.Greater(t, flushed, int64(depth*maxBufferSize))
+		assert.Less(t, flushed, int64((depth+3)*2*maxBufferSize))
+		select {
+		case err := <-added:
+			t.Fatalf("producer not blocked by the full queue: %v", err)
+		default:
+		}
+
+		// the producer completes once the pipe is read
+		read := make(chan []byte)
+		go func() {
+			data, _ := ioutil.ReadAll(pr)
+			read <- data
+		}()
+		require.NoError(t, <-added)
+		require.NoError(t, dw.Close())
+		require.NoError(t, <-ch)
+		data := <-read
+		_, flushed, _ = dw.Stats()
+		assert.Equal(t, int64(len(data)), flushed)
+		assert.Equal(t, rows, strings.Count(string(data), "<row "))
+		assert.True(t, strings.HasSuffix(string(data), "</sheetData></worksheet>"))
+		assert.Greater(t, chunks, rows/100)
+
+		// Flush waits for the queued chunks, and the error of the writer is
+		// returned by the next flush
+		file = NewFile()
+		dw, err = file.NewDirectWriter("Sheet1", maxBufferSize)
+		require.NoError(t, err)
+		require.NoError(t, dw.SetAsyncFlush(depth))
+		w := &failingWriter{limit: 8 * maxBufferSize}
+		go func() {
+			_, err := dw.WriteTo(w)
+			ch <- err
+		}()
+		waitDirectWriterOut(dw)
+		_, err = dw.AddRow([]Cell{{Value: 1}})
+		require.NoError(t, err)
+		require.NoError(t, dw.Flush())
+		dw.RLock()
+		assert.Equal(t, w.written, int(dw.bytesWritten))
+		dw.RUnlock()
+		for i := 0; err == nil && i < 100000; i++ {
+			_, err = dw.AddRow([]Cell{{Value: i}, {Value: "async"}})
+		}
+		assert.EqualError(t, err, errFailingWriter.Error())
+		assert.EqualError(t, dw.Flush(), errFailingWriter.Error())
+		assert.EqualError(t, dw.Close(), errFailingWriter.Error())
+		assert.EqualError(t, <-ch, errFailingWriter.Error())
+		_, flushed, _ = dw.Stats()
+		assert.Equal(t, int64(w.written), flushed)
+	})
+	t.Run("by-index", func(t *testing.T) {
+		file := NewFile()
+		file.NewSheet("Übersicht")
+		for _, index := range []int{-1, 2} {
+			_, err := file.NewDirectWriterByIndex(index, 1024)
+			assert.EqualError(t, err, ErrSheetIdx.Error())
+		}
+		dw, err := file.NewDirectWriterByIndex(1, 1024)
+		require.NoError(t, err)
+		assert.Equal(t, "Übersicht", dw.Sheet)
+		_, err = dw.AddRow([]Cell{{Value: "second"}})
+		require.NoError(t, err)
+		require.NoError(t, dw.Close())
+		var out bytes.Buffer
+		_, err = file.WriteTo(&out)
+		require.NoError(t, err)
+		f, err := OpenReader(&out)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"Sheet1", "Übersicht"}, f.GetSheetList())
+		rows, err := f.GetRows("Übersicht")
+		require.NoError(t, err)
+		assert.Equal(t, [][]string{{"second"}}, rows)
+		rows, err = f.GetRows("Sheet1")
+		require.NoError(t, err)
+		assert.Empty(t, rows)
+		require.NoError(t, f.Close())
+	})
+	t.Run("estimate-size", func(t *testing.T) {
+		const rows, cols = 5000, 8
+		file := NewFile()
+		dw, err := file.NewDirectWriter("Sheet1", 1<<20)
+		require.NoError(t, err)
+		row := make([]Cell, cols)
+		for i := range row {
+			row[i] = Cell{Value: "value"}
+		}
+		for i := 0; i < rows; i++ {
+			_, err = dw.AddRow(row)
+			require.NoError(t, err)
+		}
+		require.NoError(t, dw.Close())
+		var out bytes.Buffer
+		_, err = file.WriteTo(&out)
+		require.NoError(t, err)
+		actual := float64(len(readZipEntry(t, out.Bytes(), "xl/worksheets/sheet1.xml")))
+		// the string cells have the type attribute
+		estimate := float64(EstimateWorksheetSize(rows, cols, len("value")+len(` t="str"`)))
+		assert.InEpsilon(t, actual, estimate, 0.2)
+		assert.Greater(t, EstimateWorksheetSize(0, 0, 0), int64(len(XMLHeader)))
+		assert.Equal(t, EstimateWorksheetSize(0, 0, 0), EstimateWorksheetSize(-1, cols, 10))
+	})
+	t.Run("formula-cached", func(t *testing.T) {
+		file := NewFile()
+		dw, err := file.NewDirectWriter("Sheet1", 1<<20)
+		require.NoError(t, err)
+		_, err = dw.AddRow([]Cell{{Value: 2}, {Value: 3}, {Formula: "A1*B1", Value: 6}, {Formula: `"x"&A1`, Value: "x2"}, {Formula: "A1>B1", Value: false}})
+		require.NoError(t, err)
+		require.NoError(t, dw.Close())
+		var out bytes.Buffer
+		_, err = file.WriteTo(&out)
+		require.NoError(t, err)
+		sheet := readZipEntry(t, out.Bytes(), "xl/worksheets/sheet1.xml")
+		assert.Contains(t, sheet, `<c><f>A1*B1</f><v>6</v></c><c t="str"><f>&#34;x&#34;&amp;A1</f><v>x2</v></c><c t="b"><f>A1&gt;B1</f><v>0</v></c>`)
+
+		// the cached results are read without recalculation
+		f, err := OpenReader(&out)
+		require.NoError(t, err)
+		assert.Empty(t, f.validateParts())
+		for cell, expected := range map[string]string{"C1": "6", "D1": "x2", "E1": "FALSE"} {
+			value, err := f.GetCellValue("Sheet1", cell)
+			assert.NoError(t, err)
+			assert.Equal(t, expected, value)
+		}
+		formula, err := f.GetCellFormula("Sheet1", "C1")
+		assert.NoError(t, err)
+		assert.Equal(t, "A1*B1", formula)
+		sr, err := f.StreamRows("Sheet1")
+		require.NoError(t, err)
+		require.True(t, sr.Next())
+		assert.Equal(t, []Cell{{Value: "2"}, {Value: "3"}, {Formula: "A1*B1", Value: "6", CachedValue: "6"}, {Formula: `"x"&A1`, Value: "x2", CachedValue: "x2"}, {Formula: "A1>B1", Value: "FALSE", CachedValue: "0"}}, sr.Row())
+		assert.NoError(t, sr.Close())
+		require.NoError(t, f.Close())
+	})
+	t.Run("raw-formulas", func(t *testing.T) {
+		file := NewFile()
+		dw, err := file.NewDirectWriter("Sheet1", 1<<20)
+		require.NoError(t, err)
+		_, err = dw.AddRow([]Cell{{Value: 1}, {Formula: `IF(A1<2,"a&b","c")`}})
+		require.NoError(t, err)
+		dw.SetRawFormulas(true)
+		_, err = dw.AddRow([]Cell{{Value: 1}, {Formula: `IF(A2&lt;2,&quot;a&amp;b&quot;,&quot;c&quot;)`}, {Formula: "A2*2", RawValue: []byte("2")}})
+		require.NoError(t, err)
+		require.NoError(t, dw.Close())
+		var out bytes.Buffer
+		_, err = file.WriteTo(&out)
+		require.NoError(t, err)
+		sheet := readZipEntry(t, out.Bytes(), "xl/worksheets/sheet1.xml")
+		assert.Contains(t, sheet, `<f>IF(A1&lt;2,&#34;a&amp;b&#34;,&#34;c&#34;)</f>`)
+		assert.Contains(t, sheet, `<f>IF(A2&lt;2,&quot;a&amp;b&quot;,&quot;c&quot;)</f>`)
+		assert.Contains(t, sheet, `<c><f>A2*2</f><v>2</v></c>`)
+
+		// both formulas are read back unescaped
+		f, err := OpenReader(&out)
+		require.NoError(t, err)
+		for cell, expected := range map[string]string{"B1": `IF(A1<2,"a&b","c")`, "B2": `IF(A2<2,"a&b","c")`} {
+			formula, err := f.GetCellFormula("Sheet1", cell)
+			assert.NoError(t, err)
+			assert.Equal(t, expected, formula)
+		}
+		require.NoError(t, f.Close())
+	})
+	t.Run("long-strings", func(t *testing.T) {
+		maxString, longString := strings.Repeat("a", TotalCellChars), strings.Repeat("b", TotalCellChars+1)
+		multiByte := strings.Repeat("é", TotalCellChars+1)
+		for _, sharedStrings := range []bool{false, true} {
+			file := NewFile()
+			dw, err := file.NewDirectWriter("Sheet1", 1<<20)
+			require.NoError(t, err)
+			dw.SetSharedStrings(sharedStrings)
+			assert.EqualError(t, dw.SetLongStringMode(LongStringMode(2)), ErrParameterInvalid.Error())
+			row := []Cell{{Value: maxString}, {Value: longString}, {Value: []byte(multiByte)}}
+			_, err = dw.AddRow(row)
+			require.NoError(t, err)
+			assert.Equal(t, longString, row[1].Value, "the values of the caller are not modified")
+
+			require.NoError(t, dw.SetLongStringMode(LongStringError))
+			_, err = dw.AddRow([]Cell{{Value: maxString}})
+			require.NoError(t, err)
+			_, err = dw.AddRow([]Cell{{Value: 1}, {Value: longString}})
+			assert.EqualError(t, err, ErrCellCharsLength.Error())
+			_, err = dw.AddRow([]Cell{{Value: []byte(multiByte)}})
+			assert.EqualError(t, err, ErrCellCharsLength.Error())
+			// the rows before the failed row are kept
+			_, err = dw.AddRows([][]Cell{{{Value: "kept"}}, {{Value: longString}}, {{Value: "skipped"}}})
+			assert.EqualError(t, err, ErrCellCharsLength.Error())
+			require.NoError(t, dw.Close())
+
+			var out bytes.Buffer
+			_, err = file.WriteTo(&out)
+			require.NoError(t, err)
+			f, err := OpenReader(&out)
+			require.NoError(t, err)
+			rows, err := f.GetRows("Sheet1")
+			require.NoError(t, err)
+			require.Len(t, rows, 3)
+			assert.Equal(t, []string{maxString, longString[:TotalCellChars], multiByte[:2*TotalCellChars]}, rows[0])
+			assert.Equal(t, [][]string{{maxString}, {"kept"}}, rows[1:])
+			require.NoError(t, f.Close())
+		}
+	})
+	t.Run("column-stats", func(t *testing.T) {
+		file := NewFile()
+		dw, err := file.NewDirectWriter("Sheet1", 1<<20)
+		require.NoError(t, err)
+		_, err = dw.AddRow([]Cell{{Value: "not counted"}})
+		require.NoError(t, err)
+		dw.EnableColumnStats()
+		date := time.Date(2021, 12, 31, 0, 0, 0, 0, time.UTC)
+		_, err = dw.AddRows([][]Cell{
+			{{Value: 1}, {Value: "a"}, {Value: date}, {Value: true}},
+			{{Value: 2.5}, {Value: []byte("b")}, {Value: date}, {Value: nil}, {Value: ErrorValue("#N/A")}},
+			{{RawValue: []byte("3")}, {Formula: "A1", Value: 1}, {Value: "c"}, {Formula: "B1"}},
+		})
+		require.NoError(t, err)
+		_, err = dw.AddNumericRow([]float64{4, math.NaN()}, nil)
+		require.NoError(t, err)
+		// the failed rows are not counted
+		_, err = dw.AddRow([]Cell{{Value: "d"}, {Value: 1, Hyperlink: &CellHyperlink{Link: "A1", LinkType: "Invalid"}}})
+		assert.Error(t, err)
+		dw.SetMaxBytes(1)
+		_, err = dw.AddRow([]Cell{{Value: "e"}})
+		assert.EqualError(t, err, ErrSheetTooLarge.Error())
+		assert.Equal(t, []ColumnStat{
+			{Numbers: 4},
+			{Strings: 2, Numbers: 1, Errors: 1},
+			{Dates: 2, Strings: 1},
+			{Bools: 1, Empty: 2},
+			{Errors: 1},
+		}, dw.ColumnStats())
+		require.NoError(t, dw.Close())
+	})
+	t.Run("shared-string-cache", func(t *testing.T) {
+		const size, rows = 100, 5000
+		file := NewFile(Options{SharedStringCacheSize: size})
+		dw, err := file.NewDirectWriter("Sheet1", 1<<16)
+		require.NoError(t, err)
+		dw.SetSharedStrings(true)
+		for i := 0; i < rows; i++ {
+			_, err = dw.AddRow([]Cell{{Value: "hot"}, {Value: fmt.Sprintf(" unique %d", i)}})
+			require.NoError(t, err)
+		}
+		require.NoError(t, dw.Close())
+		// the shared string table is bounded, and the hot string is still shared
+		sst := file.sharedStringsReader()
+		assert.Len(t, sst.SI, size)
+		assert.Len(t, file.sharedStringsMap, size)
+
+		var out bytes.Buffer
+		_, err = file.WriteTo(&out)
+		require.NoError(t, err)
+		sheet := readZipEntry(t, out.Bytes(), "xl/worksheets/sheet1.xml")
+		assert.Contains(t, sheet, `<row r="5000"><c t="s"><v>0</v></c><c t="inlineStr"><is><t xml:space="preserve"> unique 4999</t></is></c></row>`)
+		f, err := OpenReader(&out)
+		require.NoError(t, err)
+		assert.Empty(t, f.validateParts())
+		all, err := f.GetRows("Sheet1")
+		require.NoError(t, err)
+		require.Len(t, all, rows)
+		for i, row := range all {
+			assert.Equal(t, []string{"hot", fmt.Sprintf(" unique %d", i)}, row)
+		}
+		require.NoError(t, f.Close())
+	})
+	t.Run("view", func(t *testing.T) {
+		file, row, _ := setupTestFileRow()
+		dw, err := file.NewDirectWriter("Sheet1", 1)
+		require.NoError(t, err)
+		for _, c := range []struct {
+			zoom int
+			view SheetViewType
+		}{{9, SheetViewNormal}, {401, SheetViewNormal}, {100, ""}, {100, "pageBreak"}} {
+			assert.EqualError(t, dw.SetView(c.zoom, c.view), ErrParameterInvalid.Error())
+		}
+		require.NoError(t, dw.SetView(80, SheetViewPageBreakPreview))
+		assert.Contains(t, string(dw.buildHeader()), `view="pageBreakPreview" zoomScale="80"`)
+
+		var out bytes.Buffer
+		ch := make(chan error)
+		go func() {
+			_, err := file.WriteTo(&out)
+			ch <- err
+		}()
+		waitDirectWriterOut(dw)
+		_, err = dw.AddRow(row)
+		assert.NoError(t, err)
+		assert.EqualError(t, dw.SetView(100, SheetViewNormal), "Can't set view since first data already written.")
+		require.NoError(t, dw.Close())
+		require.NoError(t, <-ch)
+
+		f, err := OpenReader(&out)
+		require.NoError(t, err)
+		assert.Empty(t, f.validateParts())
+		var zoom ZoomScale
+		require.NoError(t, f.GetSheetViewOptions("Sheet1", -1, &zoom))
+		assert.Equal(t, ZoomScale(80), zoom)
+		ws, err := f.workSheetReader("Sheet1")
+		require.NoError(t, err)
+		assert.Equal(t, string(SheetViewPageBreakPreview), ws.SheetViews.SheetView[0].View)
+		require.NoError(t, f.Close())
+	})
+	t.Run("page-breaks", func(t *testing.T) {
+		file, row, _ := setupTestFileRow()
+		dw, err := file.NewDirectWriter("Sheet1", 1)
+		require.NoError(t, err)
+		assert.EqualError(t, dw.AddPageBreakRow(1), newInvalidRowNumberError(1).Error())
+		assert.EqualError(t, dw.AddPageBreakRow(TotalRows+1), newInvalidRowNumberError(TotalRows+1).Error())
+		assert.EqualError(t, dw.AddPageBreakCol(1), ErrColumnNumber.Error())
+		assert.EqualError(t, dw.AddPageBreakCol(TotalColumns+1), ErrColumnNumber.Error())
+		for i := 0; i < 30; i++ {
+			_, err = dw.AddRow(row)
+			require.NoError(t, err)
+			if i%10 == 0 && i > 0 {
+				require.NoError(t, dw.AddPageBreakRow(i+1))
+			}
+		}
+		// the page breaks are added once
+		require.NoError(t, dw.AddPageBreakRow(11))
+		require.NoError(t, dw.AddPageBreakCol(3))
+		require.NoError(t, dw.Close())
+		var out bytes.Buffer
+		_, err = file.WriteTo(&out)
+		require.NoError(t, err)
+		sheet := readZipEntry(t, out.Bytes(), "xl/worksheets/sheet1.xml")
+		assert.Contains(t, sheet, `<rowBreaks count="2" manualBreakCount="2"><brk id="10" max="16383" man="true"></brk><brk id="20" max="16383" man="true"></brk></rowBreaks><colBreaks count="1" manualBreakCount="1"><brk id="2" max="1048575" man="true"></brk></colBreaks>`)
+
+		f, err := OpenReader(&out)
+		require.NoError(t, err)
+		assert.Empty(t, f.validateParts())
+		ws, err := f.workSheetReader("Sheet1")
+		require.NoError(t, err)
+		require.NotNil(t, ws.RowBreaks)
+		require.NotNil(t, ws.ColBreaks)
+		assert.Equal(t, []*xlsxBrk{{ID: 10, Max: 16383, Man: true}, {ID: 20, Max: 16383, Man: true}}, ws.RowBreaks.Brk)
+		assert.Equal(t, []*xlsxBrk{{ID: 2, Max: 1048575, Man: true}}, ws.ColBreaks.Brk)
+		require.NoError(t, f.Close())
+	})
+	t.Run("default-cell-style", func(t *testing.T) {
+		file := NewFile()
+		dw, err := file.NewDirectWriter("Sheet1", 1<<20)
+		require.NoError(t, err)
+		styles, err := dw.RegisterStyles([]*Style{{Font: &Font{Bold: true}}, {Font: &Font{Italic: true}}})
+		require.NoError(t, err)
+		assert.EqualError(t, dw.SetDefaultCellStyle(-1), newInvalidStyleID(-1).Error())
+		require.NoError(t, dw.SetDefaultCellStyle(styles[0]))
+		require.NoError(t, dw.SetAutoDateStyle(14))
+		date := time.Date(2021, 12, 31, 0, 0, 0, 0, time.UTC)
+		_, err = dw.AddRow([]Cell{{Value: "default"}, {Value: "explicit", StyleID: styles[1]}, {Value: 0.5, NumFmt: "0%"}, {Value: date}, {RawValue: []byte("1")}})
+		require.NoError(t, err)
+		_, err = dw.AddNumericRow([]float64{1, 2}, []int{0, styles[1]})
+		require.NoError(t, err)
+		require.NoError(t, dw.SetDefaultCellStyle(0))
+		_, err = dw.AddRow([]Cell{{Value: "unstyled"}})
+		require.NoError(t, err)
+		require.NoError(t, dw.Close())
+		var out bytes.Buffer
+		_, err = file.WriteTo(&out)
+		require.NoError(t, err)
+
+		f, err := OpenReader(&out)
+		require.NoError(t, err)
+		percent, err := f.GetCellStyle("Sheet1", "C1")
+		require.NoError(t, err)
+		assert.NotContains(t, []int{0, styles[0], styles[1]}, percent)
+		date14, err := f.GetCellStyle("Sheet1", "D1")
+		require.NoError(t, err)
+		assert.NotContains(t, []int{0, styles[0], styles[1], percent}, date14)
+		for cell, expected := range map[string]int{"A1": styles[0], "B1": styles[1], "E1": styles[0], "A2": styles[0], "B2": styles[1], "A3": 0} {
+			styleID, err := f.GetCellStyle("Sheet1", cell)
+			assert.NoError(t, err)
+			assert.Equal(t, expected, styleID, cell)
+		}
+		require.NoError(t, f.Close())
+	})
+	t.Run("finalize", func(t *testing.T) {
+		parts := make([][]byte, 2)
+		for i := range parts {
+			file := NewFile()
+			dw, err := file.NewDirectWriter("Sheet1", 1<<20)
+			require.NoError(t, err)
+			require.NoError(t, dw.SetColWidth(1, 1, 20))
+			_, err = dw.AddRow([]Cell{{Value: "part"}, {Value: i + 1}})
+			require.NoError(t, err)
+			require.NoError(t, dw.MergeCell("A2", "B2"))
+			_, err = dw.AddRow([]Cell{{Value: "merged"}})
+			require.NoError(t, err)
+			parts[i], err = dw.Finalize()
+			require.NoError(t, err)
+			_, err = dw.Finalize()
+			assert.EqualError(t, err, ErrDirectWriterClosed.Error())
+			// the unregistered worksheet is written as before
+			require.NoError(t, file.SaveAs(filepath.Join("test", "TestDirectWriterFinalize.xlsx")))
+		}
+
+		file := NewFile()
+		assert.EqualError(t, file.AddRawWorksheet("Bad", []byte("<sheetData/>")), ErrParameterInvalid.Error())
+		assert.EqualError(t, file.AddRawWorksheet("Bad", []byte("<worksheet")), ErrParameterInvalid.Error())
+		require.NoError(t, file.AddRawWorksheet("Sheet1", parts[0]))
+		require.NoError(t, file.AddRawWorksheet("Part 2", parts[1]))
+		dw, err := file.NewDirectWriter("Sheet3", 1<<20)
+		require.NoError(t, err)
+		assert.EqualError(t, file.AddRawWorksheet("Sheet3", parts[1]), ErrDirectWriterSheet.Error())
+		require.NoError(t, dw.Close())
+		var out bytes.Buffer
+		_, err = file.WriteTo(&out)
+		require.NoError(t, err)
+
+		f, err := OpenReader(&out)
+		require.NoError(t, err)
+		assert.Empty(t, f.validateParts())
+		for i, sheet := range []string{"Sheet1", "Part 2"} {
+			rows, err := f.GetRows(sheet)
+			require.NoError(t, err)
+			assert.Equal(t, [][]string{{"part", strconv.Itoa(i + 1)}, {"merged"}}, rows)
+			mergeCells, err := f.GetMergeCells(sheet)
+			require.NoError(t, err)
+			require.Len(t, mergeCells, 1)
+			assert.Equal(t, "A2:B2", mergeCells[0].GetStartAxis()+":"+mergeCells[0].GetEndAxis())
+			width, err := f.GetColWidth(sheet, "A")
+			require.NoError(t, err)
+			assert.Equal(t, 20.0, width)
+		}
+		require.NoError(t, f.Close())
+
+		file = NewFile()
+		dw, err = file.NewDirectWriter("Sheet1", 1<<20)
+		require.NoError(t, err)
+		require.NoError(t, dw.AddComment("A1", Comment{Text: "note"}))
+		_, err = dw.Finalize()
+		assert.EqualError(t, err, "Can't finalize since comments, hyperlinks or pictures already added.")
+		require.NoError(t, dw.Close())
+
+		// Test finalize a writer while the workbook is written.
+		file = NewFile()
+		dw, err = file.NewDirectWriter("Sheet1", 1<<20)
+		require.NoError(t, err)
+		next, err := file.NewDirectWriter("Sheet2", 1<<20)
+		require.NoError(t, err)
+		errCh := make(chan error)
+		go func() {
+			_, err := file.WriteTo(ioutil.Discard)
+			errCh <- err
+		}()
+		waitDirectWriterOut(dw)
+		_, err = next.Finalize()
+		assert.EqualError(t, err, "Can't finalize since the workbook is being written.")
+		_, err = next.AddRow([]Cell{{Value: "next"}})
+		require.NoError(t, err)
+		require.NoError(t, dw.Close())
+		require.NoError(t, next.Close())
+		require.NoError(t, <-errCh)
+	})
+	t.Run("start-row", func(t *testing.T) {
+		file := NewFile()
+		dw, err := file.NewDirectWriter("Sheet1", 1<<20)
+		require.NoError(t, err)
+		assert.EqualError(t, dw.SetStartRow(0), newInvalidRowNumberError(0).Error())
+		assert.EqualError(t, dw.SetStartRow(TotalRows+1), newInvalidRowNumberError(TotalRows+1).Error())
+		require.NoError(t, dw.SetStartRow(3))
+		require.NoError(t, dw.SetStartRow(5))
+		_, err = dw.AddRow([]Cell{{Value: "resumed"}})
+		require.NoError(t, err)
+		assert.EqualError(t, dw.SetStartRow(2), "Can't set start row since rows already added.")
+		_, err = dw.AddNumericRow([]float64{6}, nil)
+		require.NoError(t, err)
+		require.NoError(t, dw.Close())
+		var out bytes.Buffer
+		_, err = file.WriteTo(&out)
+		require.NoError(t, err)
+		sheet := readZipEntry(t, out.Bytes(), "xl/worksheets/sheet1.xml")
+		assert.Contains(t, sheet, `<sheetData><row r="5"`)
+
+		f, err := OpenReader(&out)
+		require.NoError(t, err)
+		rows, err := f.GetRows("Sheet1")
+		require.NoError(t, err)
+		assert.Equal(t, [][]string{nil, nil, nil, nil, {"resumed"}, {"6"}}, rows)
+		require.NoError(t, f.Close())
+	})
+	t.Run("control-chars", func(t *testing.T) {
+		file := NewFile()
+		dw, err := file.NewDirectWriter("Sheet1", 1<<20)
+		require.NoError(t, err)
+		assert.EqualError(t, dw.SetControlCharMode(ControlCharEscape+1), ErrParameterInvalid.Error())
+		_, err = dw.AddRow([]Cell{{Value: "a\x00b"}})
+		require.NoError(t, err)
+		require.NoError(t, dw.SetControlCharMode(ControlCharStrip))
+		_, err = dw.AddRow([]Cell{{Value: "a\x00b\ac\vd"}})
+		require.NoError(t, err)
+		require.NoError(t, dw.SetControlCharMode(ControlCharEscape))
+		_, err = dw.AddRow([]Cell{{Value: "a\x00b\ac\vd"}})
+		require.NoError(t, err)
+		dw.SetInlineStrings(true)
+		_, err = dw.AddRow([]Cell{{Value: "bell\a"}})
+		require.NoError(t, err)
+		_, err = dw.AddRow([]Cell{{Value: "\x00_x0041_"}, {Value: "_x005F_x0006_"}})
+		require.NoError(t, err)
+		require.NoError(t, dw.Close())
+		var out bytes.Buffer
+		_, err = file.WriteTo(&out)
+		require.NoError(t, err)
+		sheet := readZipEntry(t, out.Bytes(), "xl/worksheets/sheet1.xml")
+		assert.Contains(t, sheet, "<v>a\uFFFDb</v>")
+		assert.Contains(t, sheet, "<v>abcd</v>")
+		assert.Contains(t, sheet, "<v>a_x0000_b_x0007_c_x000B_d</v>")
+		assert.Contains(t, sheet, "<is><t>bell_x0007_</t></is>")
+		assert.Contains(t, sheet, "<is><t>_x0000__x005F_x0041_</t></is>")
+
+		// the worksheet is well-formed
+		f, err := OpenReader(&out)
+		require.NoError(t, err)
+		rows, err := f.GetRows("Sheet1")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"a\uFFFDb"}, rows[0])
+		assert.Equal(t, []string{"abcd"}, rows[1])
+		// the literal escape sequences are kept
+		assert.Equal(t, []string{"\x00_x0041_", "_x005F_x0006_"}, rows[4])
+		require.NoError(t, f.Close())
+	})
+	t.Run("is-concurrent", func(t *testing.T) {
+		file := NewFile()
+		dw, err := file.NewDirectWriter("Sheet1", 1<<20)
+		require.NoError(t, err)
+		assert.False(t, dw.IsConcurrent())
+		_, err = dw.AddRow([]Cell{{Value: "buffered"}})
+		require.NoError(t, err)
+		assert.False(t, dw.IsConcurrent())
+		done := make(chan error)
+		go func() {
+			_, err := dw.WriteTo(ioutil.Discard)
+			done <- err
+		}()
+		waitDirectWriterOut(dw)
+		assert.True(t, dw.IsConcurrent())
+		require.NoError(t, dw.Close())
+		assert.NoError(t, <-done)
+	})
+	t.Run("save-as-streaming", func(t *testing.T) {
+		file := NewFile()
+		dw, err := file.NewDirectWriter("Sheet1", 1<<16)
+		require.NoError(t, err)
+		path := filepath.Join("test", "TestSaveAsStreaming.xlsx")
+		addRows := func(dw *DirectWriter) error { return nil }
+		assert.EqualError(t, file.SaveAsStreaming(path, nil, addRows), ErrParameterInvalid.Error())
+		assert.EqualError(t, file.SaveAsStreaming(path, dw, nil), ErrParameterInvalid.Error())
+		assert.EqualError(t, NewFile().SaveAsStreaming(path, dw, addRows), ErrParameterInvalid.Error())
+		assert.EqualError(t, file.SaveAsStreaming(strings.Repeat("c", MaxFileNameLength+1), dw, addRows), ErrMaxFileNameLength.Error())
+		const rows = 50000
+		require.NoError(t, file.SaveAsStreaming(path, dw, func(dw *DirectWriter) error {
+			for i := 1; i <= rows; i++ {
+				if _, err := dw.AddRow([]Cell{{Value: i}, {Value: "row " + strconv.Itoa(i)}, {Value: float64(i) / 3}}); err != nil {
+					return err
+				}
+			}
+			return nil
+		}))
+		rowCount, bytesFlushed, _ := dw.Stats()
+		assert.Equal(t, rows, rowCount)
+		assert.Greater(t, bytesFlushed, int64(1<<20))
+
+		f, err := OpenFile(path)
+		require.NoError(t, err)
+		sr, err := f.StreamRows("Sheet1")
+		require.NoError(t, err)
+		var n int
+		for sr.Next() {
+			n++
+		}
+		assert.NoError(t, sr.Err())
+		assert.Equal(t, rows, n)
+		assert.NoError(t, sr.Close())
+		value, err := f.GetCellValue("Sheet1", "B50000")
+		require.NoError(t, err)
+		assert.Equal(t, "row 50000", value)
+		require.NoError(t, f.Close())
+
+		// Test the errors of the function and of writing the file are returned,
+		// and the DirectWriter is closed.
+		for _, c := range []struct {
+			path     string
+			fnErr    error
+			expected string
+		}{
+			{path, errors.New("producer failed"), "producer failed"},
+			{filepath.Join("test", "not-exist", "TestSaveAsStreaming.xlsx"), nil, ""},
+		} {
+			file = NewFile()
+			dw, err = file.NewDirectWriter("Sheet1", 1<<16)
+			require.NoError(t, err)
+			err = file.SaveAsStreaming(c.path, dw, func(dw *DirectWriter) error {
+				_, err := dw.AddRow([]Cell{{Value: 1}})
+				assert.NoError(t, err)
+				return c.fnErr
+			})
+			if c.expected != "" {
+				assert.EqualError(t, err, c.expected)
+			} else {
+				assert.Error(t, err)
+			}
+			assert.EqualError(t, dw.Close(), ErrDirectWriterClosed.Error())
+		}
+	})
+	t.Run("autofilter", func(t *testing.T) {
+		file := NewFile()
+		dw, err := file.NewDirectWriter("Sheet1", 1<<20)
+		require.NoError(t, err)
+		assert.EqualError(t, dw.AddAutofilter("A1", nil), ErrParameterInvalid.Error())
+		assert.EqualError(t, dw.AddAutofilter("A1:C6", []AutoFilterOptions{{Column: "D", Expression: "x == 1"}}), "incorrect index of column 'D'")
+		assert.EqualError(t, dw.AddAutofilter("A1:C6", []AutoFilterOptions{{Column: "A", Expression: "x"}}), "incorrect number of tokens in criteria 'x'")
+		_, err = dw.AddRow([]Cell{{Value: "Name"}, {Value: "Status"}, {Value: "Amount"}})
+		require.NoError(t, err)
+		require.NoError(t, dw.AddAutofilter("C6:A1", []AutoFilterOptions{
+			{Column: "B", Expression: "x == active"},
+			{Column: "C", Expression: "x > 10 and x < 100"},
+		}))
+		// the defined name is registered when the workbook is written
+		assert.Empty(t, file.GetDefinedName())
+		_, err = dw.AddRow([]Cell{{Value: "a"}, {Value: "active"}, {Value: 50}})
+		require.NoError(t, err)
+		_, err = dw.AddRow([]Cell{{Value: "b"}, {Value: "inactive"}, {Value: 50}})
+		require.NoError(t, err)
+		_, err = dw.AddRowAt(4, []Cell{{Value: "c"}, {Value: "Active"}, {Value: 5}})
+		require.NoError(t, err)
+		_, err = dw.AddRows([][]Cell{{{Value: "d"}, {Value: "ACTIVE"}, {Value: 99.5}}, {{Value: "e"}, {}, {Value: 20}}})
+		require.NoError(t, err)
+		_, err = dw.AddNumericRow([]float64{1, 2, 3}, nil)
+		require.NoError(t, err)
+		assert.EqualError(t, dw.AddAutofilter("A1:C6", nil), "Can't add autofilter since rows of the range already added.")
+		require.NoError(t, dw.Close())
+		var out bytes.Buffer
+		_, err = file.WriteTo(&out)
+		require.NoError(t, err)
+		sheet := readZipEntry(t, out.Bytes(), "xl/worksheets/sheet1.xml")
+		assert.Contains(t, sheet, `<sheetPr filterMode="true">`)
+		assert.Contains(t, sheet, `<autoFilter ref="$A$1:$C$6"><filterColumn colId="1"><filters><filter val="active"></filter></filters></filterColumn><filterColumn colId="2"><customFilters and="true">`)
+
+		f, err := OpenReader(&out)
+		require.NoError(t, err)
+		for row, expected := range []bool{true, true, false, false, true, false, true} {
+			visible, err := f.GetRowVisible("Sheet1", row+1)
+			assert.NoError(t, err)
+			assert.Equal(t, expected, visible, row+1)
+		}
+		assert.Equal(t, "Sheet1!$A$1:$C$6", f.GetDefinedName()[0].RefersTo)
+		require.NoError(t, f.Close())
+
+		for _, c := range []struct {
+			expression string
+			value      interface{}
+			expected   bool
+		}{
+			{"x == b*", "Bob", true},
+			{"x == b*", "abc", false},
+			{"x != *b*", "abc", false},
+			{"x == ?~*", "a*", true},
+			{"x == ?~*", "ab", false},
+			{"x == blanks", nil, true},
+			{"x == blanks", 0, false},
+			{"x != blanks", "", false},
+			{"x == nonblanks", false, true},
+			{"x >= m", "Mike", true},
+			{"x >= m", 1, false},
+			{"x < 2000", "1999", false},
+			{"x < 2000", 1999, true},
+			{"x == 10 or x == 20", 20.0, true},
+			{"x < 10 or x > 20", 15, false},
+			{"x != 5", nil, true},
+		} {
+			filterColumn, err := file.parseFilterColumn(0, c.expression)
+			require.NoError(t, err)
+			fc := newDirectFilterColumn(1, filterColumn)
+			s, num, isNum := filterCellValue(c.value)
+			assert.Equal(t, c.expected, fc.match(s, num, isNum), "%s %v", c.expression, c.value)
+		}
+	})
+	t.Run("json-number", func(t *testing.T) {
+		var rows [][]interface{}
+		decoder := json.NewDecoder(strings.NewReader(`[[12345678901234567890, 0.1, -2.5e-3, "7"]]`))
+		decoder.UseNumber()
+		require.NoError(t, decoder.Decode(&rows))
+		file := NewFile()
+		dw, err := file.NewDirectWriter("Sheet1", 1<<20)
+		require.NoError(t, err)
+		for _, row := range rows {
+			cells := make([]Cell, len(row))
+			for i, v := range row {
+				cells[i] = Cell{Value: v}
+			}
+			_, err = dw.AddRow(cells)
+			require.NoError(t, err)
+		}
+		require.NoError(t, dw.Close())
+		var out bytes.Buffer
+		_, err = file.WriteTo(&out)
+		require.NoError(t, err)
+		sheet := readZipEntry(t, out.Bytes(), "xl/worksheets/sheet1.xml")
+		assert.Contains(t, sheet, `<row r="1"><c><v>12345678901234567890</v></c><c><v>0.1</v></c><c><v>-2.5e-3</v></c><c t="str"><v>7</v></c></row>`)
+
+		f, err := OpenReader(&out)
+		require.NoError(t, err)
+		values, err := f.GetRows("Sheet1", Options{RawCellValue: true})
+		require.NoError(t, err)
+		assert.Equal(t, [][]string{{"12345678901234567890", "0.1", "-2.5e-3", "7"}}, values)
+		for cell, expected := range map[string]CellType{"A1": CellTypeUnset, "C1": CellTypeUnset, "D1": CellTypeString} {
+			cellType, err := f.GetCellType("Sheet1", cell)
+			assert.NoError(t, err)
+			assert.Equal(t, expected, cellType, cell)
+		}
+		require.NoError(t, f.Close())
+	})
+	t.Run("non-finite", func(t *testing.T) {
+		for _, opts := range []Options{{}, {RejectNonFinite: true}} {
+			file := NewFile(opts)
+			dw, err := file.NewDirectWriter("Sheet1", 1<<20)
+			require.NoError(t, err)
+			_, err = dw.AddRow([]Cell{{Value: 1}, {Value: math.Inf(1)}, {Value: math.NaN()}})
+			if opts.RejectNonFinite {
+				assert.EqualError(t, err, ErrNonFiniteNumber.Error())
+				continue
+			}
+			require.NoError(t, err)
+			require.NoError(t, dw.Close())
+			assert.Equal(t, `<row r="1"><c><v>1</v></c><c t="e"><v>#NUM!</v></c><c t="e"><v>#NUM!</v></c></row></sheetData></worksheet>`, string(dw.buf))
+		}
+	})
+	t.Run("limits", func(t *testing.T) {
+		file := NewFile()
+		dw, err := file.NewDirectWriter("Sheet1", 1<<20)
+		require.NoError(t, err)
+		_, err = dw.AddRow(make([]Cell, TotalColumns+1))
+		assert.EqualError(t, err, ErrColumnNumber.Error())
+		_, err = dw.AddRows([][]Cell{make([]Cell, TotalColumns+1)})
+		assert.EqualError(t, err, ErrColumnNumber.Error())
+		assert.Empty(t, dw.buf)
+		assert.Equal(t, 0, dw.rowCount)
+		_, err = dw.AddRow(make([]Cell, TotalColumns))
+		assert.NoError(t, err)
+		assert.Len(t, dw.MaxColumnLengths(), TotalColumns)
+
+		dw.rowCount = TotalRows - 1
+		_, err = dw.AddRow([]Cell{{Value: 1}})
+		assert.NoError(t, err)
+		buffered := len(dw.buf)
+		_, err = dw.AddRow([]Cell{{Value: 1}})
+		assert.EqualError(t, err, ErrMaxRows.Error())
+		_, err = dw.AddRows([][]Cell{{{Value: 1}}})
+		assert.EqualError(t, err, ErrMaxRows.Error())
+		assert.Equal(t, buffered, len(dw.buf))
+		assert.Equal(t, TotalRows, dw.rowCount)
+		assert.True(t, bytes.HasSuffix(dw.buf, []byte(`<row r="1048576"><c><v>1</v></c></row>`)))
+	})
+	t.Run("tab-color-visible", func(t *testing.T) {
+		file, row, _ := setupTestFileRow()
+		file.NewSheet("Sheet2")
+		dw1, err := file.NewDirectWriter("Sheet1", 1)
+		require.NoError(t, err)
+		dw2, err := file.NewDirectWriter("Sheet2", 1)
+		require.NoError(t, err)
+		require.NoError(t, dw1.SetTabColor("#FF0000"))
+		assert.Contains(t, string(dw1.buildHeader()), `<tabColor rgb="FFFF0000"></tabColor>`)
+		require.NoError(t, dw2.SetTabColor("#00FF00"))
+		// the active worksheet can't be hidden
+		require.NoError(t, dw1.SetSheetVisible(false))
+		require.NoError(t, dw2.SetSheetVisible(false))
+
+		var out bytes.Buffer
+		ch := make(chan error)
+		go func() {
+			_, err := file.WriteTo(&out)
+			ch <- err
+		}()
+		waitDirectWriterOut(dw1)
+		_, err = dw1.AddRow(row)
+		assert.NoError(t, err)
+		assert.EqualError(t, dw1.SetTabColor("#0000FF"), "Can't set tab color since first data already written.")
+		require.NoError(t, dw1.Close())
+		waitDirectWriterOut(dw2)
+		_, err = dw2.AddRow(row)
+		assert.NoError(t, err)
+		require.NoError(t, dw2.Close())
+		require.NoError(t, <-ch)
+
+		f, err := OpenReader(&out)
+		require.NoError(t, err)
+		assert.Empty(t, f.validateParts())
+		var tabColor TabColor
+		require.NoError(t, f.GetSheetPrOptions("Sheet1", &tabColor))
+		assert.Equal(t, TabColor("FF0000"), tabColor)
+		require.NoError(t, f.GetSheetPrOptions("Sheet2", &tabColor))
+		assert.Equal(t, TabColor("00FF00"), tabColor)
+		assert.True(t, f.GetSheetVisible("Sheet1"))
+		assert.False(t, f.GetSheetVisible("Sheet2"))
+	})
+	t.Run("flush-interval", func(t *testing.T) {
+		file, row, _ := setupTestFileRow()
+		dw, err := file.NewDirectWriter("Sheet1", 1<<20)
+		require.NoError(t, err)
+		require.NoError(t, dw.SetFlushInterval(10*time.Millisecond))
+		// restarting the background flushing stops the previous one
+		require.NoError(t, dw.SetFlushInterval(20*time.Millisecond))
+
+		var out syncBuffer
+		ch := make(chan error)
+		go func() {
+			_, err := dw.WriteTo(&out)
+			ch <- err
+		}()
+		waitDirectWriterOut(dw)
+		// nothing is flushed without rows
+		time.Sleep(50 * time.Millisecond)
+		assert.Empty(t, out.String())
+		for r := 1; r <= 3; r++ {
+			_, err = dw.AddRow(row)
+			require.NoError(t, err)
+			// the rows reach the writer within the interval although the buffer is below maxBufferSize
+			expected := `<row r="` + strconv.Itoa(r) + `">`
+			assert.Eventually(t, func() bool { return strings.Contains(out.String(), expected) }, time.Second, 5*time.Millisecond)
+		}
+		_, flushed, buffered := dw.Stats()
+		assert.Equal(t, int64(len(out.String())), flushed)
+		assert.Equal(t, 0, buffered)
+
+		require.NoError(t, dw.SetFlushInterval(0))
+		_, err = dw.AddRow(row)
+		require.NoError(t, err)
+		time.Sleep(50 * time.Millisecond)
+		assert.NotContains(t, out.String(), `<row r="4">`)
+		require.NoError(t, dw.SetFlushInterval(10*time.Millisecond))
+		require.NoError(t, dw.Close())
+		require.NoError(t, <-ch)
+		assert.True(t, strings.HasSuffix(out.String(), `</worksheet>`))
+		assert.EqualError(t, dw.SetFlushInterval(time.Millisecond), ErrDirectWriterClosed.Error())
+	})
+	t.Run("flush-interval-setters", func(t *testing.T) {
+		// Test the header setters while the header may be flushed in the
+		// background, a setter either fails or its change is written.
+		file := NewFile()
+		dw, err := file.NewDirectWriter("Sheet1", 1<<20)
+		require.NoError(t, err)
+		require.NoError(t, dw.SetFlushInterval(time.Millisecond))
+		var out syncBuffer
+		ch := make(chan error)
+		go func() {
+			_, err := dw.WriteTo(&out)
+			ch <- err
+		}()
+		waitDirectWriterOut(dw)
+		_, err = dw.AddRow([]Cell{{Value: 1}})
+		require.NoError(t, err)
+		var height int
+		for i := 0; ; i++ {
+			if err = dw.SetDefaultRowHeight(float64(i%MaxRowHeight + 1)); err != nil {
+				break
+			}
+			height = i%MaxRowHeight + 1
+			if err = dw.SetTabColor("#FF0000"); err != nil {
+				break
+			}
+			if err = dw.SetPanes(`{"freeze":true,"split":false,"x_split":0,"y_split":1,"top_left_cell":"A2","active_pane":"bottomLeft"}`); err != nil {
+				break
+			}
+			if err = dw.SetColWidth(1, 1, 20); err != nil {
+				break
+			}
+		}
+		assert.Contains(t, err.Error(), "since first data already written.")
+		require.NoError(t, dw.Close())
+		require.NoError(t, <-ch)
+		var ws xlsxWorksheet
+		require.NoError(t, xml.Unmarshal([]byte(out.String()), &ws))
+		if height > 0 {
+			assert.Equal(t, float64(height), ws.SheetFormatPr.DefaultRowHeight)
+		}
+	})
+	t.Run("flush-interval-wait", func(t *testing.T) {
+		file, row, _ := setupTestFileRow()
+		dw, err := file.NewDirectWriter("Sheet1", 1<<20)
+		require.NoError(t, err)
+		require.NoError(t, dw.SetWait(true))
+		require.NoError(t, dw.SetFlushInterval(5*time.Millisecond))
+		var out syncBuffer
+		ch := make(chan error)
+		go func() {
+			_, err := dw.WriteTo(&out)
+			ch <- err
+		}()
+		waitDirectWriterOut(dw)
+		_, err = dw.AddRow(row)
+		require.NoError(t, err)
+		// nothing is flushed in wait mode
+		time.Sleep(30 * time.Millisecond)
+		assert.Empty(t, out.String())
+		require.NoError(t, dw.SetWait(false))
+		assert.Eventually(t, func() bool { return strings.Contains(out.String(), `<row r="1">`) }, time.Second, 5*time.Millisecond)
+		require.NoError(t, dw.Close())
+		require.NoError(t, <-ch)
+	})
+	t.Run("sheet-view-options", func(t *testing.T) {
+		file, row, _ := setupTestFileRow()
+		dw, err := file.NewDirectWriter("Sheet1", 1)
+		require.NoError(t, err)
+		require.NoError(t, dw.SetSheetViewOptions(0, ShowGridLines(false), ShowRowColHeaders(false)))
+		assert.Contains(t, string(dw.buildHeader()), `<sheetView showGridLines="false" showRowColHeaders="false"`)
+		assert.EqualError(t, dw.SetSheetViewOptions(1, ShowGridLines(false)), "view index 1 out of range")
+
+		var out bytes.Buffer
+		ch := make(chan error)
+		go func() {
+			_, err := file.WriteTo(&out)
+			ch <- err
+		}()
+		waitDirectWriterOut(dw)
+		_, err = dw.AddRow(row)
+		assert.NoError(t, err)
+		assert.EqualError(t, dw.SetSheetViewOptions(0, ShowGridLines(true)), "Can't set sheet view options since first data already written.")
+		require.NoError(t, dw.Close())
+		require.NoError(t, <-ch)
+
+		f, err := OpenReader(&out)
+		require.NoError(t, err)
+		assert.Empty(t, f.validateParts())
+		var showGridLines ShowGridLines
+		var showRowColHeaders ShowRowColHeaders
+		require.NoError(t, f.GetSheetViewOptions("Sheet1", 0, &showGridLines, &showRowColHeaders))
+		assert.False(t, bool(showGridLines))
+		assert.False(t, bool(showRowColHeaders))
+	})
+	t.Run("active-cell", func(t *testing.T) {
+		file, row, _ := setupTestFileRow()
+		file.NewSheet("Sheet2")
+		dw1, err := file.NewDirectWriter("Sheet1", 1)
+		require.NoError(t, err)
+		dw2, err := file.NewDirectWriter("Sheet2", 1)
+		require.NoError(t, err)
+		require.NoError(t, dw1.SetActiveCell("B2"))
+		require.NoError(t, dw1.SetActiveCell("C3"))
+		assert.EqualError(t, dw1.SetActiveCell("C"), `cannot convert cell "C" to coordinates: `+newInvalidCellNameError("C").Error())
+		assert.Contains(t, string(dw1.buildHeader()), `<selection activeCell="C3" sqref="C3"></selection>`)
+		// the active cell is selected in the active pane
+		require.NoError(t, dw2.SetPanes(`{"freeze":true,"split":false,"x_split":0,"y_split":1,"top_left_cell":"A2","active_pane":"bottomLeft","panes":[{"sqref":"A2","active_cell":"A2","pane":"bottomLeft"}]}`))
+		require.NoError(t, dw2.SetActiveCell("D10"))
+
+		var out bytes.Buffer
+		ch := make(chan error)
+		go func() {
+			_, err := file.WriteTo(&out)
+			ch <- err
+		}()
+		waitDirectWriterOut(dw1)
+		_, err = dw1.AddRow(row)
+		assert.NoError(t, err)
+		assert.EqualError(t, dw1.SetActiveCell("A1"), "Can't set active cell since first data already written.")
+		require.NoError(t, dw1.Close())
+		require.NoError(t, dw2.Close())
+		require.NoError(t, <-ch)
+
+		f, err := OpenReader(&out)
+		require.NoError(t, err)
+		assert.Empty(t, f.validateParts())
+		for sheet, expected := range map[string]xlsxSelection{
+			"Sheet1": {ActiveCell: "C3", SQRef: "C3"},
+			"Sheet2": {ActiveCell: "D10", SQRef: "D10", Pane: "bottomLeft"},
+		} {
+			ws, err := f.workSheetReader(sheet)
+			require.NoError(t, err)
+			view := ws.SheetViews.SheetView[len(ws.SheetViews.SheetView)-1]
+			require.Len(t, view.Selection, 1)
+			assert.Equal(t, expected, *view.Selection[0])
+		}
+		assert.Equal(t, 0, f.GetActiveSheetIndex())
+	})
+}
+
+// syncBuffer is a bytes.Buffer which is safe for concurrent use.
+type syncBuffer struct {
+	sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.Lock()
+	defer b.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.Lock()
+	defer b.Unlock()
+	return b.buf.String()
+}
+
+// countingFile is a file which counts the calls to Write, as a writer backed by
+// a file descriptor.
+type countingFile struct {
+	*os.File
+	writes int
+}
+
+func (f *countingFile) Write(p []byte) (int, error) {
+	f.writes++
+	return f.File.Write(p)
+}
+
+// errFailingWriter is the error of the failingWriter.
+var errFailingWriter = errors.New("failing writer")
+
+// failingWriter is a writer which fails once the given number of bytes is
+// written.
+type failingWriter struct {
+	limit, written int
+}
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	if w.written+len(p) > w.limit {
+		return 0, errFailingWriter
+	}
+	w.written += len(p)
+	return len(p), nil
+}
+
+// waitDirectWriterOut loops waiting for the goroutine to launch and register
+// the writer of the given DirectWriter.
+func waitDirectWriterOut(dw *DirectWriter) {
+	for {
+		dw.Lock()
+		w := dw.out
+		dw.Unlock()
+		if w != nil {
+			return
+		}
+		runtime.Gosched()
+	}
+}
+
+func TestEncodeCell(t *testing.T) {
+	for _, c := range []struct {
+		cell     Cell
+		expected string
+	}{
+		{Cell{Value: 123}, `<c><v>123</v></c>`},
+		{Cell{Value: -1.5}, `<c><v>-1.5</v></c>`},
+		{Cell{Value: "foo"}, `<c t="str"><v>foo</v></c>`},
+		{Cell{Value: " foo bar "}, `<c xml:space="preserve" t="str"><v> foo bar </v></c>`},
+		{Cell{Value: "<a & b>"}, `<c t="str"><v>&lt;a &amp; b&gt;</v></c>`},
+		{Cell{Value: true}, `<c t="b"><v>1</v></c>`},
+		{Cell{Value: big.NewRat(1, 8)}, `<c><v>0.125</v></c>`},
+		{Cell{Value: json.Number("-1.50")}, `<c><v>-1.50</v></c>`},
+		{Cell{Value: json.Number("1,5")}, `<c t="str"><v>1,5</v></c>`},
+		{Cell{Formula: "SUM(A1:A2)"}, `<c t="str"><f>SUM(A1:A2)</f></c>`},
+		{Cell{Formula: `A1&"<"`, Value: 3}, `<c><f>A1&amp;&#34;&lt;&#34;</f><v>3</v></c>`},
+		{Cell{StyleID: 2, Value: 1}, `<c s="2"><v>1</v></c>`},
+		{Cell{StyleID: 2, RawValue: []byte("42")}, `<c s="2"><v>42</v></c>`},
+		{Cell{}, `<c t="str"></c>`},
+		{Cell{Value: "\t"}, `<c xml:space="preserve" t="str"><v>&#x9;</v></c>`},
+		{Cell{Value: "\n"}, `<c xml:space="preserve" t="str"><v>&#xA;</v></c>`},
+		{Cell{Value: " "}, `<c xml:space="preserve" t="str"><v> </v></c>`},
+		{Cell{Value: "a\n"}, `<c xml:space="preserve" t="str"><v>a&#xA;</v></c>`},
+		{Cell{Value: "a b"}, `<c t="str"><v>a b</v></c>`},
+		{Cell{Value: math.NaN()}, `<c t="e"><v>#NUM!</v></c>`},
+		{Cell{Value: math.Inf(-1), StyleID: 1}, `<c s="1" t="e"><v>#NUM!</v></c>`},
+		{Cell{Value: []RichTextRun{{Text: "a", Font: &Font{Bold: true}}, {Text: " <b>"}}}, `<c t="inlineStr"><is><r><rPr><b></b></rPr><t>a</t></r><r><t xml:space="preserve"> &lt;b&gt;</t></r></is></c>`},
+		{Cell{Value: ErrorValue("#N/A"), StyleID: 1}, `<c s="1" t="e"><v>#N/A</v></c>`},
+		{Cell{Value: ErrorValue("#DIV/0!")}, `<c t="e"><v>#DIV/0!</v></c>`},
+	} {
+		dst, err := EncodeCell([]byte("<row>"), c.cell)
+		assert.NoError(t, err)
+		assert.Equal(t, "<row>"+c.expected, string(dst))
+	}
+	_, err := EncodeCell(nil, Cell{Value: ErrorValue("#SPILL!")})
+	assert.EqualError(t, err, newInvalidErrorValue("#SPILL!").Error())
+}
+
+// readZipEntry returns the content of the file with the given name in the zip
+// archive.
+func readZipEntry(t *testing.T, archive []byte, name string) string {
+	z, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	require.NoError(t, err)
+	r, err := z.Open(name)
+	require.NoError(t, err)
+	defer r.Close()
+	content, err := io.ReadAll(r)
+	require.NoError(t, err)
+	return string(content)
+}
+
+// validateParts checks the ordering rules of the worksheet schema which bite
+// applications other than Excel, for each worksheet of the workbook:
+//
+// - the child elements of the worksheet, such as cols, sheetData, mergeCells
+// and headerFooter, are in the order of the schema, which is the order of the
+// fields of xlsxWorksheet, and only conditionalFormatting may be repeated.
+//
+// - the rows are in ascending order of the row numbers, and the cells with a
+// reference are in ascending order of the columns in a row.
+//
+// - the count attribute of mergeCells is the number of merged cells.
+func (f *File) validateParts() []error {
+	order, typ := map[string]int{}, reflect.TypeOf(xlsxWorksheet{})
+	for i := 0; i < typ.NumField(); i++ {
+		if name := strings.Split(typ.Field(i).Tag.Get("xml"), ",")[0]; name != "" && !strings.Contains(name, " ") {
+			order[name] = i
+		}
+	}
+	var errs []error
+	for _, sheet := range f.GetSheetList() {
+		path := f.sheetMap[trimSheetName(sheet)]
+		var (
+			decoder          = f.xmlNewDecoder(bytes.NewReader(f.readBytes(path)))
+			depth, prev      int
+			prevName         string
+			row, col, merged int
+			count            string
+		)
+		for {
+			token, err := decoder.Token()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %v", path, err))
+				break
+			}
+			switch element := token.(type) {
+			case xml.StartElement:
+				depth++
+				name, attr := element.Name.Local, map[string]string{}
+				for _, a := range element.Attr {
+					attr[a.Name.Local] = a.Value
+				}
+				switch depth {
+				case 2:
+					idx, ok := order[name]
+					if !ok {
+						continue
+					}
+					if idx < prev || idx == prev && name != "conditionalFormatting" {
+						errs = append(errs, fmt.Errorf("%s: %s after %s", path, name, prevName))
+					}
+					prev, prevName = idx, name
+					if name == "mergeCells" {
+						merged, count = 0, attr["count"]
+					}
+				case 3:
+					if name == "mergeCell" {
+						merged++
+					}
+					if r, ok := attr["r"]; ok && name == "row" {
+						n, _ := strconv.Atoi(r)
+						if n <= row {
+							errs = append(errs, fmt.Errorf("%s: row %d after row %d", path, n, row))
+						}
+						row, col = n, 0
+					}
+				case 4:
+					if r, ok := attr["r"]; ok && name == "c" {
+						c, _, _ := CellNameToCoordinates(r)
+						if c <= col {
+							errs = append(errs, fmt.Errorf("%s: cell %s out of order", path, r))
+						}
+						col = c
+					}
+				}
+			case xml.EndElement:
+				if depth == 2 && element.Name.Local == "mergeCells" && count != "" && count != strconv.Itoa(merged) {
+					errs = append(errs, fmt.Errorf("%s: mergeCells count %s of %d merged cells", path, count, merged))
+				}
+				depth--
+			}
+		}
+	}
+	return errs
+}
+
+func TestValidateParts(t *testing.T) {
+	f := NewFile()
+	f.Pkg.Store("xl/worksheets/sheet1.xml", []byte(`<worksheet><sheetData><row r="2"><c r="B2"/><c r="A2"/></row><row r="1"/></sheetData><cols/><mergeCells count="2"><mergeCell ref="A1:B1"/></mergeCells><mergeCells/></worksheet>`))
+	var msgs []string
+	for _, err := range f.validateParts() {
+		msgs = append(msgs, err.Error())
+	}
+	assert.Equal(t, []string{
+		"xl/worksheets/sheet1.xml: cell A2 out of order",
+		"xl/worksheets/sheet1.xml: row 1 after row 2",
+		"xl/worksheets/sheet1.xml: cols after sheetData",
+		"xl/worksheets/sheet1.xml: mergeCells count 2 of 1 merged cells",
+		"xl/worksheets/sheet1.xml: mergeCells after mergeCells",
+	}, msgs)
+	f.Pkg.Store("xl/worksheets/sheet1.xml", []byte(`<worksheet><sheetData>`))
+	assert.Len(t, f.validateParts(), 1)
 }
 
 func setupTestFileRow() (*File, []Cell, string) {
@@ -56,10 +56,45 @@ func newFieldLengthError(name string) error {
 	return fmt.Errorf("field %s must be less or equal than 255 characters", name)
 }
 
+// newInvalidErrorValue defined the error message on receiving the invalid error value of a cell.
+func newInvalidErrorValue(val string) error {
+	return fmt.Errorf("invalid error value %q", val)
+}
+
+// WriteError defined the error returned by File.WriteTo on failing to write a
+// part of the workbook, such as the worksheet of a DirectWriter whose context
+// is done or whose writes fail. It reports the name of the worksheet, if the
+// part is a worksheet, and the path of the part with the underlying error.
+type WriteError struct {
+	Sheet string
+	Part  string
+	Err   error
+}
+
+// Error returns the error message with the worksheet name, if any, and the
+// part path.
+func (e *WriteError) Error() string {
+	if e.Sheet != "" {
+		return fmt.Sprintf("failed to write sheet %q (%s): %v", e.Sheet, e.Part, e.Err)
+	}
+	return fmt.Sprintf("failed to write %s: %v", e.Part, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *WriteError) Unwrap() error {
+	return e.Err
+}
+
 var (
 	// ErrStreamSetColWidth defined the error message on set column width in
 	// stream writing mode.
 	ErrStreamSetColWidth = errors.New("must call the SetColWidth function before the SetRow function")
+	// ErrStreamSetColStyle defined the error message on set column style in
+	// stream writing mode.
+	ErrStreamSetColStyle = errors.New("must call the SetColStyle function before the SetRow function")
+	// ErrStreamSetPanes defined the error message on set panes in stream
+	// writing mode.
+	ErrStreamSetPanes = errors.New("must call the SetPanes function before the SetRow function")
 	// ErrColumnNumber defined the error message on receive an invalid column
 	// number.
 	ErrColumnNumber = errors.New("column number exceeds maximum limit")
@@ -97,6 +132,9 @@ var (
 	ErrMaxFileNameLength = errors.New("file name length exceeds maximum limit")
 	// ErrEncrypt defined the error message on encryption spreadsheet.
 	ErrEncrypt = errors.New("not support encryption currently")
+	// ErrEncryptionOptions defined the error message on receive the invalid
+	// hash algorithm, key bits or spin count of the encryption.
+	ErrEncryptionOptions = errors.New("unsupported encryption hash algorithm, key bits or spin count")
 	// ErrUnknownEncryptMechanism defined the error message on unsupport
 	// encryption mechanism.
 	ErrUnknownEncryptMechanism = errors.New("unknown encryption mechanism")
@@ -136,6 +174,36 @@ var (
 	// ErrCellCharsLength defined the error message for receiving a cell
 	// characters length that exceeds the limit.
 	ErrCellCharsLength = fmt.Errorf("cell value must be 0-%d characters", TotalCellChars)
+	// ErrMergeCellOverlap defined the error message on receive a merged cell
+	// area which overlaps with an existing merged cell area.
+	ErrMergeCellOverlap = errors.New("merged cell area overlaps with an existing merged cell area")
+	// ErrDirectWriterClosed defined the error message on use the direct writer
+	// after it has been closed.
+	ErrDirectWriterClosed = errors.New("the direct writer is closed")
+	// ErrDirectWriterRawRow defined the error message on add a raw row
+	// fragment which is not a row element to the direct writer.
+	ErrDirectWriterRawRow = errors.New("the raw row fragment must begin with <row")
+	// ErrDirectWriterRowOrder defined the error message on add a row to the
+	// direct writer at a row number which is not after the last row.
+	ErrDirectWriterRowOrder = errors.New("the row number must be greater than the last row number")
+	// ErrDirectWriterSheet defined the error message on switch the direct
+	// writer to a worksheet which is already written by a direct writer.
+	ErrDirectWriterSheet = errors.New("the worksheet is already written by a direct writer")
+	// ErrSheetTooLarge defined the error message on add a row to the direct
+	// writer which exceeds the maximum size of the worksheet.
+	ErrSheetTooLarge = errors.New("the worksheet exceeds the maximum size")
+	// ErrSheetState defined the error message on receive an invalid
+	// worksheet visibility state.
+	ErrSheetState = errors.New("invalid worksheet visibility state")
+	// ErrSheetVisible defined the error message on hide the last visible
+	// worksheet of the workbook.
+	ErrSheetVisible = errors.New("a workbook must contain at least one visible worksheet")
+	// ErrNonFiniteNumber defined the error message on write a NaN or infinite
+	// number, which can't be stored in the spreadsheet.
+	ErrNonFiniteNumber = errors.New("NaN and infinite numbers are not supported")
+	// ErrCompressionLevel defined the error message for receiving invalid
+	// CompressionLevel.
+	ErrCompressionLevel = errors.New("compression level must be between -2 and 9")
 	// ErrOptionsUnzipSizeLimit defined the error message for receiving
 	// invalid UnzipSizeLimit and WorksheetUnzipMemLimit.
 	ErrOptionsUnzipSizeLimit = errors.New("the value of UnzipSizeLimit should be greater than or equal to WorksheetUnzipMemLimit")
@@ -1,6 +1,8 @@
 package excelize
 
 import (
+	"errors"
+	"io"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -23,3 +25,11 @@ func TestNewInvalidCellNameError(t *testing.T) {
 func TestNewInvalidExcelDateError(t *testing.T) {
 	assert.EqualError(t, newInvalidExcelDateError(-1), "invalid date value -1.000000, negative values are not supported")
 }
+
+func TestWriteError(t *testing.T) {
+	err := &WriteError{Sheet: "Sheet1", Part: "xl/worksheets/sheet1.xml", Err: io.ErrShortWrite}
+	assert.EqualError(t, err, `failed to write sheet "Sheet1" (xl/worksheets/sheet1.xml): short write`)
+	assert.True(t, errors.Is(err, io.ErrShortWrite))
+	err = &WriteError{Part: "xl/styles.xml", Err: io.ErrShortWrite}
+	assert.EqualError(t, err, "failed to write xl/styles.xml: short write")
+}
@@ -25,6 +25,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"golang.org/x/net/html/charset"
 )
@@ -38,7 +39,11 @@ type File struct {
 	sheetMap         map[string]string
 	streams          map[string]*StreamWriter
 	directWriters    []*DirectWriter
+	directWriting    bool
+	deferredNames    []deferredDefinedName
+	directDrawings   sync.Mutex
 	tempFiles        sync.Map
+	numFmtStyles     sync.Map
 	CalcChain        *xlsxCalcChain
 	Comments         map[string]*xlsxComments
 	ContentTypes     *xlsxTypes
@@ -62,7 +67,19 @@ type charsetTranscoderFn func(charset string, input io.Reader) (rdr io.Reader, e
 
 // Options define the options for open and reading spreadsheet.
 //
-// Password specifies the password of the spreadsheet in plain text.
+// Password specifies the password of the spreadsheet in plain text. The
+// spreadsheet is saved with ECMA-376 agile encryption when it is specified.
+//
+// EncryptionHashAlgorithm specifies the hash algorithm used to derive the
+// keys of the agile encryption on saving the spreadsheet with a password, one
+// of MD4, MD5, RIPEMD-160, SHA1, SHA256, SHA384 and SHA512, the default value
+// is SHA512.
+//
+// EncryptionKeyBits specifies the key size in bits of the AES cipher used by
+// the agile encryption, one of 128, 192 and 256, the default value is 256.
+//
+// EncryptionSpinCount specifies the number of times the password is hashed
+// to derive the keys of the agile encryption, the default value is 100000.
 //
 // RawCellValue specifies if apply the number format for the cell value or get
 // the raw value.
@@ -75,11 +92,73 @@ type charsetTranscoderFn func(charset string, input io.Reader) (rdr io.Reader, e
 // bytes, worksheet XML will be extracted to system temporary directory when
 // the file size is over this value, this value should be less than or equal
 // to UnzipSizeLimit, the default value is 16MB.
+//
+// CompressionConcurrency specifies the number of workers used to compress the
+// parts of the spreadsheet concurrently on saving, which speeds up saving
+// workbooks with many worksheets on multi-core machines. Each compressed part
+// is buffered in memory until it is written in order, so the parts are
+// compressed serially by default. It requires Go 1.17 or later, and is
+// ignored otherwise.
+//
+// CompressionLevel specifies the deflate compression level used on saving the
+// spreadsheet, from flate.HuffmanOnly to flate.BestCompression, such as
+// flate.BestSpeed for streaming over network. The zero value uses
+// flate.DefaultCompression, so the entries can't be stored uncompressed.
+//
+// FixedModTime specifies the modification time of every entry of the zip
+// archive on saving the spreadsheet. The entries are always written ordered
+// by path, so saving the same content with a fixed modification time
+// produces byte-identical files, except for encrypted spreadsheets.
+//
+// AssumeDense specifies if the rows iterator and GetRows keep the empty and
+// trailing empty cells of each row, so that a row has as many columns as
+// cells in the worksheet, such as the rows written by the DirectWriter. It
+// only controls whether the empty cells are kept: the cells without
+// reference, which the DirectWriter omits, are always placed in the column
+// after the previous cell.
+//
+// RejectNonFinite specifies that the stream writers return
+// ErrNonFiniteNumber on writing a NaN or infinite number, which can't be
+// stored in the spreadsheet. By default, these numbers are written as #NUM!
+// error cells.
+//
+// WriteTransform specifies a function to wrap the writer given to WriteTo,
+// Write and SaveAs, such as for encrypting, compressing or checksumming the
+// output on the fly. It wraps the whole stream of the zip archive, not the
+// individual parts of the spreadsheet. If the returned writer implements
+// io.Closer, it is closed after the spreadsheet is written, without closing
+// the given writer.
+//
+// StoreOnly specifies that the entries of the zip archive are stored without
+// compression on saving the spreadsheet, ignoring CompressionLevel and
+// CompressionConcurrency. It is meant to be used with a single compression
+// layer of the whole stream, such as a gzip WriteTransform for a response
+// served with "Content-Encoding: gzip", so that the output isn't compressed
+// twice.
+//
+// SharedStringCacheSize specifies the maximum number of strings of the shared
+// string table to which the DirectWriter adds the strings in shared strings
+// mode, which bounds the memory used by a high-cardinality dataset. Once the
+// table is full, the strings which are not yet in the table are written as
+// inline strings, while the strings in the table are still shared. Since the
+// written cells refer to the strings in the table, they are never evicted.
+// The zero value doesn't limit the shared string table.
 type Options struct {
-	Password               string
-	RawCellValue           bool
-	UnzipSizeLimit         int64
-	WorksheetUnzipMemLimit int64
+	Password                string
+	EncryptionHashAlgorithm string
+	EncryptionKeyBits       int
+	EncryptionSpinCount     int
+	RawCellValue            bool
+	UnzipSizeLimit          int64
+	WorksheetUnzipMemLimit  int64
+	CompressionConcurrency  int
+	CompressionLevel        int
+	FixedModTime            time.Time
+	RejectNonFinite         bool
+	AssumeDense             bool
+	WriteTransform          func(io.Writer) io.Writer
+	StoreOnly               bool
+	SharedStringCacheSize   int
 }
 
 // OpenFile take the name of an spreadsheet file and returns a populated
@@ -132,14 +132,17 @@ func TestOpenFile(t *testing.T) {
 		value    bool
 		expected string
 	}{
-		{false, "0"},
-		{true, "1"},
+		{false, "FALSE"},
+		{true, "TRUE"},
 	}
 	for _, test := range booltest {
 		assert.NoError(t, f.SetCellValue("Sheet2", "F16", test.value))
 		val, err := f.GetCellValue("Sheet2", "F16")
 		assert.NoError(t, err)
 		assert.Equal(t, test.expected, val)
+		val, err = f.GetCellValue("Sheet2", "F16", Options{RawCellValue: true})
+		assert.NoError(t, err)
+		assert.Equal(t, map[bool]string{false: "0", true: "1"}[test.value], val)
 	}
 
 	assert.NoError(t, f.SetCellValue("Sheet2", "G2", nil))
@@ -14,10 +14,14 @@ package excelize
 import (
 	"archive/zip"
 	"bytes"
+	"compress/flate"
 	"fmt"
+	"hash"
+	"hash/crc32"
 	"io"
 	"os"
 	"path/filepath"
+	"sort"
 	"sync"
 )
 
@@ -26,8 +30,11 @@ import (
 //
 //    f := NewFile()
 //
-func NewFile() *File {
+func NewFile(opt ...Options) *File {
 	f := newFile()
+	for i := range opt {
+		f.options = &opt[i]
+	}
 	f.Pkg.Store("_rels/.rels", []byte(XMLHeader+templateRels))
 	f.Pkg.Store("docProps/app.xml", []byte(XMLHeader+templateDocpropsApp))
 	f.Pkg.Store("docProps/core.xml", []byte(XMLHeader+templateDocpropsCore))
@@ -82,6 +89,54 @@ func (f *File) SaveAs(name string, opt ...Options) error {
 	return f.Write(file)
 }
 
+// SaveAsStreaming provides a function to create or update the spreadsheet at
+// the provided path like SaveAs, with the worksheet of the given DirectWriter
+// written to the file while its rows are being added by the given function,
+// without buffering the whole worksheet. The function is called in the
+// current goroutine while the file is written by another one, and the
+// DirectWriter is closed when the function returns, also on error, so the
+// function must not close it. It blocks until the workbook is written, that
+// is until the other direct writers of the File are closed as well. It
+// returns the error of the function, or else the first error of closing the
+// DirectWriter or of writing the file. For example:
+//
+//    dw, err := f.NewDirectWriter("Sheet1", 1<<20)
+//    if err != nil {
+//        fmt.Println(err)
+//        return
+//    }
+//    if err := f.SaveAsStreaming("Book1.xlsx", dw, func(dw *excelize.DirectWriter) error {
+//        for _, row := range rows {
+//            if _, err := dw.AddRow(row); err != nil {
+//                return err
+//            }
+//        }
+//        return nil
+//    }); err != nil {
+//        fmt.Println(err)
+//    }
+//
+func (f *File) SaveAsStreaming(name string, dw *DirectWriter, fn func(dw *DirectWriter) error, opt ...Options) error {
+	if dw == nil || fn == nil || dw.File != f || !f.isDirectWriter(dw) {
+		return ErrParameterInvalid
+	}
+	if len(name) > MaxFileNameLength {
+		return ErrMaxFileNameLength
+	}
+	saveErr := make(chan error, 1)
+	go func() {
+		saveErr <- f.SaveAs(name, opt...)
+	}()
+	err := fn(dw)
+	if closeErr := dw.Close(); err == nil && closeErr != ErrDirectWriterClosed {
+		err = closeErr
+	}
+	if writeErr := <-saveErr; err == nil {
+		err = writeErr
+	}
+	return err
+}
+
 // Close closes and cleanup the open temporary file for the spreadsheet.
 func (f *File) Close() error {
 	var err error
@@ -101,7 +156,17 @@ func (f *File) Write(w io.Writer) error {
 }
 
 // WriteTo implements io.WriterTo to write the file.
-func (f *File) WriteTo(w io.Writer) (int64, error) {
+func (f *File) WriteTo(w io.Writer) (n int64, err error) {
+	if f.options != nil && f.options.WriteTransform != nil {
+		w = f.options.WriteTransform(w)
+		if c, ok := w.(io.Closer); ok {
+			defer func() {
+				if closeErr := c.Close(); err == nil {
+					err = closeErr
+				}
+			}()
+		}
+	}
 	if f.options != nil && f.options.Password != "" {
 		buf, err := f.WriteToBuffer()
 		if err != nil {
@@ -115,11 +180,36 @@ func (f *File) WriteTo(w io.Writer) (int64, error) {
 	return 0, nil
 }
 
+// WriteToChecksum provides a function to write the file to w like WriteTo,
+// and update the given hash with the written bytes at the same time, such as
+// a SHA-256 hash to verify the integrity of the exported file, without a
+// second pass over the output. The bytes are hashed as written to w, after
+// the WriteTransform and the encryption of the options. It returns the given
+// hash for the caller to finalize. For example:
+//
+//    h, err := f.WriteToChecksum(w, sha256.New())
+//    if err != nil {
+//        fmt.Println(err)
+//        return
+//    }
+//    fmt.Printf("%x\n", h.Sum(nil))
+//
+func (f *File) WriteToChecksum(w io.Writer, h hash.Hash) (hash.Hash, error) {
+	if h == nil {
+		return h, ErrParameterInvalid
+	}
+	_, err := f.WriteTo(io.MultiWriter(w, h))
+	return h, err
+}
+
 // WriteToBuffer provides a function to get bytes.Buffer from the saved file,
 // and it allocates space in memory. Be careful when the file size is large.
 func (f *File) WriteToBuffer() (*bytes.Buffer, error) {
 	buf := new(bytes.Buffer)
-	zw := zip.NewWriter(buf)
+	zw, err := f.newZipWriter(buf)
+	if err != nil {
+		return buf, err
+	}
 
 	if err := f.writeToZip(zw); err != nil {
 		return buf, zw.Close()
@@ -142,7 +232,10 @@ func (f *File) WriteToBuffer() (*bytes.Buffer, error) {
 
 // writeDirectToWriter provides a function to write to io.Writer.
 func (f *File) writeDirectToWriter(w io.Writer) error {
-	zw := zip.NewWriter(w)
+	zw, err := f.newZipWriter(w)
+	if err != nil {
+		return err
+	}
 	if err := f.writeToZip(zw); err != nil {
 		_ = zw.Close()
 		return err
@@ -150,63 +243,317 @@ func (f *File) writeDirectToWriter(w io.Writer) error {
 	return zw.Close()
 }
 
+// compressionLevel returns the deflate compression level of the options.
+func (f *File) compressionLevel() int {
+	if f.options == nil || f.options.CompressionLevel == 0 {
+		return flate.DefaultCompression
+	}
+	return f.options.CompressionLevel
+}
+
+// newZipWriter provides a function to create a zip.Writer with the
+// compression level of the options.
+func (f *File) newZipWriter(w io.Writer) (*zip.Writer, error) {
+	level := f.compressionLevel()
+	if level < flate.HuffmanOnly || level > flate.BestCompression {
+		return nil, ErrCompressionLevel
+	}
+	zw := zip.NewWriter(w)
+	if level != flate.DefaultCompression {
+		zw.RegisterCompressor(zip.Deflate, func(out io.Writer) (io.WriteCloser, error) {
+			return flate.NewWriter(out, level)
+		})
+	}
+	return zw, nil
+}
+
 // writeToZip provides a function to write to zip.Writer
 func (f *File) writeToZip(zw *zip.Writer) error {
-	f.calcChainWriter()
-	f.commentsWriter()
-	f.contentTypesWriter()
-	f.drawingsWriter()
-	f.vmlDrawingWriter()
-	f.workBookWriter()
-	f.workSheetWriter()
-	f.relsWriter()
-	f.sharedStringsWriter()
-	f.styleSheetWriter()
+	// the direct writers are looked up by index while they are written, so
+	// they must not be unregistered by DirectWriter.Finalize meanwhile
+	f.Lock()
+	f.directWriting = true
+	direct := len(f.directWriters) > 0
+	f.Unlock()
+	defer func() {
+		f.Lock()
+		f.directWriting = false
+		f.Unlock()
+	}()
+	if direct {
+		// the direct writers may add sheets by DirectWriter.NextSheet while
+		// they are written, so the workbook, the content types, the
+		// relationships and the other shared parts are written by
+		// directWritersWriter once the direct writers are done
+		f.calcChainWriter()
+		f.workSheetWriter()
+	} else {
+		// the shared strings may update the content types and relationships
+		f.sharedStringsWriter()
+		f.calcChainWriter()
+		f.commentsWriter()
+		f.contentTypesWriter()
+		f.drawingsWriter()
+		f.vmlDrawingWriter()
+		f.workBookWriter()
+		f.workSheetWriter()
+		f.relsWriter()
+		f.styleSheetWriter()
+	}
 
+	if f.options != nil && f.options.CompressionConcurrency > 1 && !f.options.StoreOnly && zipCreateRawSupported {
+		return f.writeToZipConcurrently(zw, f.options.CompressionConcurrency)
+	}
 	var pathDone = make(map[string]bool)
-	for _, d := range f.directWriters {
-		fi, err := zw.Create(d.sheetPath)
+	// the direct writers may move on to the next sheet while they are written
+	for i := 0; ; i++ {
+		path, ok := f.directWriterPath(i)
+		if !ok {
+			break
+		}
+		fi, err := f.createZipEntry(zw, path)
 		if err != nil {
-			return err
+			return f.newWriteError(path, err)
 		}
-		if _, err := d.WriteTo(fi); err != nil {
-			return err
+		if err := f.writeDirectWriter(i, fi); err != nil {
+			return f.newWriteError(path, err)
 		}
-		pathDone[d.sheetPath] = true
+		pathDone[path] = true
 	}
-	for path, stream := range f.streams {
-		fi, err := zw.Create(path)
+	f.directWritersWriter()
+	for _, path := range f.streamPaths() {
+		stream := f.streams[path]
+		fi, err := f.createZipEntry(zw, path)
 		if err != nil {
-			return err
+			return f.newWriteError(path, err)
 		}
 		var from io.Reader
 		from, err = stream.rawData.Reader()
 		if err != nil {
 			_ = stream.rawData.Close()
-			return err
+			return f.newWriteError(path, err)
 		}
 		_, err = io.Copy(fi, from)
 		if err != nil {
-			return err
+			return f.newWriteError(path, err)
 		}
 		_ = stream.rawData.Close()
 		pathDone[path] = true
 	}
-	var err error
-	f.Pkg.Range(func(path, content interface{}) bool {
+	for _, path := range f.pkgPaths(pathDone) {
+		content, _ := f.Pkg.Load(path)
+		fi, err := f.createZipEntry(zw, path)
 		if err != nil {
-			return false
+			return f.newWriteError(path, err)
 		}
-		if _, ok := pathDone[path.(string)]; ok {
-			return true
+		if _, err = fi.Write(content.([]byte)); err != nil {
+			return f.newWriteError(path, err)
 		}
-		var fi io.Writer
-		fi, err = zw.Create(path.(string))
-		if err != nil {
-			return false
+	}
+	return nil
+}
+
+// newWriteError returns the WriteError of the part of the given path, with
+// the name of the worksheet if the part is a worksheet.
+func (f *File) newWriteError(path string, err error) error {
+	werr := &WriteError{Part: path, Err: err}
+	f.Lock()
+	defer f.Unlock()
+	for name, sheetPath := range f.sheetMap {
+		if sheetPath == path {
+			werr.Sheet = name
+			break
+		}
+	}
+	return werr
+}
+
+// createZipEntry provides a function to add a compressed entry of the given
+// path to the zip archive, or a stored entry with the StoreOnly option, with
+// the fixed modification time of the options if any.
+func (f *File) createZipEntry(zw *zip.Writer, path string) (io.Writer, error) {
+	return zw.CreateHeader(f.zipFileHeader(path))
+}
+
+// zipFileHeader returns the header of the zip entry of the given path.
+func (f *File) zipFileHeader(path string) *zip.FileHeader {
+	fh := &zip.FileHeader{Name: path, Method: zip.Deflate}
+	if f.options != nil && f.options.StoreOnly {
+		fh.Method = zip.Store
+	}
+	if f.options != nil && !f.options.FixedModTime.IsZero() {
+		// the MS-DOS date and time are also set, since they are not derived
+		// from the modified time by zip.Writer.CreateRaw
+		fh.SetModTime(f.options.FixedModTime)
+	}
+	return fh
+}
+
+// streamPaths returns the worksheet paths of the stream writers ordered by
+// path.
+func (f *File) streamPaths() []string {
+	paths := make([]string, 0, len(f.streams))
+	for path := range f.streams {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// pkgPaths returns the paths of the package parts which are not yet written
+// ordered by path.
+func (f *File) pkgPaths(pathDone map[string]bool) []string {
+	var paths []string
+	f.Pkg.Range(func(path, content interface{}) bool {
+		if !pathDone[path.(string)] {
+			paths = append(paths, path.(string))
 		}
-		_, err = fi.Write(content.([]byte))
 		return true
 	})
+	sort.Strings(paths)
+	return paths
+}
+
+// directWritersWriter provides a function to update the workbook,
+// relationships, styles, comments, drawings and content types parts, which may be
+// changed by direct writers while streaming, after all direct writers are
+// done. The deferred defined names are resolved at this time as well.
+func (f *File) directWritersWriter() {
+	if len(f.directWriters) == 0 {
+		if len(f.deferredNames) > 0 {
+			f.resolveDeferredNames()
+			f.workBookWriter()
+		}
+		return
+	}
+	for _, d := range f.directWriters {
+		d.setSheetState()
+		d.setPrintArea()
+		d.setFilterDatabase()
+		d.writeComments()
+	}
+	f.resolveDeferredNames()
+	f.sharedStringsWriter()
+	f.commentsWriter()
+	f.vmlDrawingWriter()
+	f.drawingsWriter()
+	f.contentTypesWriter()
+	f.workBookWriter()
+	f.relsWriter()
+	f.styleSheetWriter()
+}
+
+// writeToZipConcurrently provides a function to write to zip.Writer, the
+// entries are compressed concurrently by the given number of workers, and
+// written in a deterministic order: the direct writers, the streams, and the
+// other parts of the package ordered by path. Each compressed entry is
+// buffered in memory until it is written to the zip archive.
+func (f *File) writeToZipConcurrently(zw *zip.Writer, concurrency int) error {
+	var (
+		wg       sync.WaitGroup
+		sem      = make(chan struct{}, concurrency)
+		entries  []*compressedEntry
+		pathDone = make(map[string]bool)
+	)
+	compress := func(path string, fn func(e *compressedEntry) error) {
+		e := newCompressedEntry(f.zipFileHeader(path), f.compressionLevel())
+		entries = append(entries, e)
+		pathDone[path] = true
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			if e.err = fn(e); e.err == nil {
+				e.err = e.fw.Close()
+			}
+		}()
+	}
+	// the direct writers may move on to the next sheet while they are written,
+	// the next sheets are registered before the current ones are done
+	for i := 0; ; i++ {
+		path, ok := f.directWriterPath(i)
+		if !ok {
+			wg.Wait()
+			if path, ok = f.directWriterPath(i); !ok {
+				break
+			}
+		}
+		i := i
+		compress(path, func(e *compressedEntry) error {
+			return f.writeDirectWriter(i, e)
+		})
+	}
+	// the workbook, relationships and styles parts can only be compressed
+	// after the direct writers are done
+	wg.Wait()
+	f.directWritersWriter()
+	for _, path := range f.streamPaths() {
+		stream := f.streams[path]
+		compress(path, func(e *compressedEntry) error {
+			defer stream.rawData.Close()
+			from, err := stream.rawData.Reader()
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(e, from)
+			return err
+		})
+	}
+	for _, path := range f.pkgPaths(pathDone) {
+		content, _ := f.Pkg.Load(path)
+		compress(path, func(e *compressedEntry) error {
+			_, err := e.Write(content.([]byte))
+			return err
+		})
+	}
+	wg.Wait()
+	for _, e := range entries {
+		if e.err != nil {
+			return f.newWriteError(e.fh.Name, e.err)
+		}
+		if err := e.writeTo(zw); err != nil {
+			return f.newWriteError(e.fh.Name, err)
+		}
+	}
+	return nil
+}
+
+// compressedEntry is an entry of the zip archive which is compressed ahead of
+// writing, so that the entries can be compressed concurrently.
+type compressedEntry struct {
+	fh   *zip.FileHeader
+	buf  bytes.Buffer
+	fw   *flate.Writer
+	crc  hash.Hash32
+	size uint64
+	err  error
+}
+
+// newCompressedEntry provides a function to create a compressed entry by
+// given zip file header and compression level.
+func newCompressedEntry(fh *zip.FileHeader, level int) *compressedEntry {
+	e := &compressedEntry{fh: fh, crc: crc32.NewIEEE()}
+	e.fw, _ = flate.NewWriter(&e.buf, level)
+	return e
+}
+
+// Write compresses the given uncompressed data into the entry.
+func (e *compressedEntry) Write(p []byte) (int, error) {
+	_, _ = e.crc.Write(p)
+	e.size += uint64(len(p))
+	return e.fw.Write(p)
+}
+
+// writeTo writes the compressed data of the entry to the zip archive.
+func (e *compressedEntry) writeTo(zw *zip.Writer) error {
+	e.fh.CRC32 = e.crc.Sum32()
+	e.fh.CompressedSize64 = uint64(e.buf.Len())
+	e.fh.UncompressedSize64 = e.size
+	fi, err := zipCreateRaw(zw, e.fh)
+	if err != nil {
+		return err
+	}
+	_, err = e.buf.WriteTo(fi)
 	return err
 }
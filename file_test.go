@@ -1,12 +1,20 @@
 package excelize
 
 import (
+	"archive/zip"
 	"bufio"
 	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/ioutil"
 	"os"
 	"strings"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -41,16 +49,17 @@ func TestWriteTo(t *testing.T) {
 		f, buf := File{Pkg: sync.Map{}}, bytes.Buffer{}
 		f.Pkg.Store("/d/", []byte("s"))
 		_, err := f.WriteTo(bufio.NewWriter(&buf))
-		assert.EqualError(t, err, "zip: write to directory")
+		assert.EqualError(t, err, "failed to write /d/: zip: write to directory")
 		f.Pkg.Delete("/d/")
 	}
 	// Test file path overflow
 	{
 		f, buf := File{Pkg: sync.Map{}}, bytes.Buffer{}
 		const maxUint16 = 1<<16 - 1
-		f.Pkg.Store(strings.Repeat("s", maxUint16+1), nil)
+		name := strings.Repeat("s", maxUint16+1)
+		f.Pkg.Store(name, nil)
 		_, err := f.WriteTo(bufio.NewWriter(&buf))
-		assert.EqualError(t, err, "zip: FileHeader.Name too long")
+		assert.EqualError(t, err, "failed to write "+name+": zip: FileHeader.Name too long")
 	}
 	// Test StreamsWriter err
 	{
@@ -64,6 +73,292 @@ func TestWriteTo(t *testing.T) {
 	}
 }
 
+func BenchmarkWriteDirectWriters(b *testing.B) {
+	for _, concurrency := range []int{1, 4, 8} {
+		b.Run(fmt.Sprintf("concurrency-%d", concurrency), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				f := NewFile(Options{CompressionConcurrency: concurrency})
+				dws := make([]*DirectWriter, 100)
+				for s := range dws {
+					dw, err := f.NewDirectWriter(fmt.Sprintf("Sheet%d", s+1), 1<<16)
+					require.NoError(b, err)
+					for row := 0; row < 1000; row++ {
+						_, err = dw.AddRow([]Cell{{Value: "This is test data"}, {Value: row}, {Value: float64(row) / 3}})
+						require.NoError(b, err)
+					}
+					require.NoError(b, dw.Close())
+					dws[s] = dw
+				}
+				_, err := f.WriteTo(io.Discard)
+				require.NoError(b, err)
+			}
+		})
+	}
+}
+
+func TestWriteToConcurrently(t *testing.T) {
+	f := NewFile(Options{CompressionConcurrency: 4})
+	assert.NoError(t, f.SetCellValue("Sheet1", "A1", "foo"))
+	f.NewSheet("Sheet2")
+	sw, err := f.NewStreamWriter("Sheet2")
+	require.NoError(t, err)
+	require.NoError(t, sw.SetRow("A1", []interface{}{"stream"}))
+	require.NoError(t, sw.Flush())
+	dws := make([]*DirectWriter, 10)
+	for i := range dws {
+		dws[i], err = f.NewDirectWriter(fmt.Sprintf("Direct%d", i), 1)
+		require.NoError(t, err)
+	}
+	var out bytes.Buffer
+	ch := make(chan error)
+	go func() {
+		_, err := f.WriteTo(&out)
+		ch <- err
+	}()
+	// close the direct writers in reverse order, while the entries are
+	// compressed concurrently
+	for i := len(dws) - 1; i >= 0; i-- {
+		_, err = dws[i].AddRow([]Cell{{Value: fmt.Sprintf("direct %d", i)}})
+		require.NoError(t, err)
+		require.NoError(t, dws[i].Close())
+	}
+	require.NoError(t, <-ch)
+
+	// the direct writers are written first, in order
+	z, err := zip.NewReader(bytes.NewReader(out.Bytes()), int64(out.Len()))
+	require.NoError(t, err)
+	for i, dw := range dws {
+		assert.Equal(t, dw.sheetPath, z.File[i].Name)
+	}
+	f2, err := OpenReader(&out)
+	require.NoError(t, err)
+	for cell, expected := range map[[2]string]string{{"Sheet1", "A1"}: "foo", {"Sheet2", "A1"}: "stream", {"Direct3", "A1"}: "direct 3"} {
+		val, err := f2.GetCellValue(cell[0], cell[1])
+		assert.NoError(t, err)
+		assert.Equal(t, expected, val)
+	}
+
+	// test the output is deterministic
+	f = NewFile(Options{CompressionConcurrency: 4})
+	for i := 2; i <= 10; i++ {
+		f.NewSheet(fmt.Sprintf("Sheet%d", i))
+		assert.NoError(t, f.SetCellValue(fmt.Sprintf("Sheet%d", i), "A1", i))
+	}
+	buf1, err := f.WriteToBuffer()
+	require.NoError(t, err)
+	buf2, err := f.WriteToBuffer()
+	require.NoError(t, err)
+	assert.Equal(t, buf1.Bytes(), buf2.Bytes())
+
+	// test write compressed entry with invalid path
+	f = NewFile(Options{CompressionConcurrency: 4})
+	f.Pkg.Store("/d/", []byte("s"))
+	_, err = f.WriteTo(io.Discard)
+	assert.EqualError(t, err, "failed to write /d/: zip: write to directory")
+}
+
+func TestCompressionLevel(t *testing.T) {
+	write := func(opts Options) *bytes.Buffer {
+		f := NewFile(opts)
+		for row := 1; row <= 1000; row++ {
+			assert.NoError(t, f.SetSheetRow("Sheet1", fmt.Sprintf("A%d", row), &[]interface{}{row, row * row, fmt.Sprintf("row %d", row*7919%1000)}))
+		}
+		buf, err := f.WriteToBuffer()
+		require.NoError(t, err)
+		return buf
+	}
+	bestSpeed, bestCompression := write(Options{CompressionLevel: flate.BestSpeed}), write(Options{CompressionLevel: flate.BestCompression})
+	assert.Greater(t, bestSpeed.Len(), bestCompression.Len())
+	huffmanOnly := write(Options{CompressionLevel: flate.HuffmanOnly, CompressionConcurrency: 2})
+	assert.Greater(t, huffmanOnly.Len(), bestSpeed.Len())
+
+	var expected [][]string
+	for i, buf := range []*bytes.Buffer{bestSpeed, bestCompression, huffmanOnly} {
+		f, err := OpenReader(buf)
+		require.NoError(t, err)
+		rows, err := f.GetRows("Sheet1")
+		require.NoError(t, err)
+		if i == 0 {
+			expected = rows
+			assert.Len(t, expected, 1000)
+			continue
+		}
+		assert.Equal(t, expected, rows)
+	}
+
+	for _, level := range []int{flate.HuffmanOnly - 1, flate.BestCompression + 1} {
+		f := NewFile(Options{CompressionLevel: level})
+		_, err := f.WriteToBuffer()
+		assert.EqualError(t, err, ErrCompressionLevel.Error())
+		_, err = f.WriteTo(io.Discard)
+		assert.EqualError(t, err, ErrCompressionLevel.Error())
+	}
+}
+
+func TestFixedModTime(t *testing.T) {
+	modTime := time.Date(2021, time.September, 1, 12, 0, 0, 0, time.UTC)
+	write := func(opts Options) [sha256.Size]byte {
+		f := NewFile(opts)
+		for i := 1; i <= 10; i++ {
+			sheet := fmt.Sprintf("Sheet%d", i)
+			f.NewSheet(sheet)
+			assert.NoError(t, f.SetSheetRow(sheet, "A1", &[]interface{}{i, "foo", true}))
+		}
+		f.NewSheet("Stream")
+		sw, err := f.NewStreamWriter("Stream")
+		require.NoError(t, err)
+		require.NoError(t, sw.SetRow("A1", []interface{}{1, "bar"}))
+		require.NoError(t, sw.Flush())
+		dw, err := f.NewDirectWriter("Direct", 1)
+		require.NoError(t, err)
+		_, err = dw.AddRow([]Cell{{Value: 1}, {Value: "baz"}})
+		require.NoError(t, err)
+		require.NoError(t, dw.Close())
+
+		var out bytes.Buffer
+		require.NoError(t, f.Write(&out))
+		zr, err := zip.NewReader(bytes.NewReader(out.Bytes()), int64(out.Len()))
+		require.NoError(t, err)
+		for _, file := range zr.File {
+			assert.Equal(t, modTime.String(), file.Modified.UTC().String(), file.Name)
+		}
+		return sha256.Sum256(out.Bytes())
+	}
+	for _, opts := range []Options{{FixedModTime: modTime}, {FixedModTime: modTime, CompressionConcurrency: 4}} {
+		assert.Equal(t, write(opts), write(opts))
+	}
+}
+
+// xorWriter is a io.WriteCloser applying a XOR of the given key to the written
+// bytes.
+type xorWriter struct {
+	w      io.Writer
+	key    byte
+	closed int
+}
+
+func (x *xorWriter) Write(p []byte) (int, error) {
+	b := make([]byte, len(p))
+	for i := range p {
+		b[i] = p[i] ^ x.key
+	}
+	return x.w.Write(b)
+}
+
+func (x *xorWriter) Close() error {
+	x.closed++
+	return nil
+}
+
+func TestWriteTransform(t *testing.T) {
+	for _, opts := range []Options{{}, {CompressionConcurrency: 4}} {
+		var xw *xorWriter
+		opts.WriteTransform = func(w io.Writer) io.Writer {
+			xw = &xorWriter{w: w, key: 0x5a}
+			return xw
+		}
+		f := NewFile(opts)
+		dw, err := f.NewDirectWriter("Sheet1", 1)
+		require.NoError(t, err)
+		var out bytes.Buffer
+		ch := make(chan error)
+		go func() {
+			_, err := f.WriteTo(&out)
+			ch <- err
+		}()
+		waitDirectWriterOut(dw)
+		_, err = dw.AddRow([]Cell{{Value: "foo"}, {Value: 1}})
+		require.NoError(t, err)
+		require.NoError(t, dw.Close())
+		require.NoError(t, <-ch)
+		assert.Equal(t, 1, xw.closed)
+
+		_, err = OpenReader(bytes.NewReader(out.Bytes()))
+		assert.Error(t, err)
+		var plain bytes.Buffer
+		_, err = (&xorWriter{w: &plain, key: 0x5a}).Write(out.Bytes())
+		require.NoError(t, err)
+		f, err = OpenReader(&plain)
+		require.NoError(t, err)
+		rows, err := f.GetRows("Sheet1")
+		assert.NoError(t, err)
+		assert.Equal(t, [][]string{{"foo", "1"}}, rows)
+	}
+}
+
+func TestWriteToChecksum(t *testing.T) {
+	for _, opts := range []Options{{}, {CompressionConcurrency: 4}, {Password: "password"}} {
+		f := NewFile(opts)
+		dw, err := f.NewDirectWriter("Sheet1", 1)
+		require.NoError(t, err)
+		var out bytes.Buffer
+		ch := make(chan error)
+		go func() {
+			h, err := f.WriteToChecksum(&out, sha256.New())
+			if err == nil {
+				assert.Equal(t, sha256.Size, len(h.Sum(nil)))
+				// the checksum of the streamed bytes is the checksum of the buffered bytes
+				expected := sha256.Sum256(out.Bytes())
+				assert.Equal(t, expected[:], h.Sum(nil))
+			}
+			ch <- err
+		}()
+		if opts.Password == "" {
+			waitDirectWriterOut(dw)
+		}
+		_, err = dw.AddRow([]Cell{{Value: "foo"}, {Value: 1}})
+		require.NoError(t, err)
+		require.NoError(t, dw.Close())
+		require.NoError(t, <-ch)
+		assert.NotZero(t, out.Len())
+	}
+	_, err := NewFile().WriteToChecksum(io.Discard, nil)
+	assert.EqualError(t, err, ErrParameterInvalid.Error())
+}
+
+func TestStoreOnly(t *testing.T) {
+	write := func(opts Options) []byte {
+		opts.WriteTransform = func(w io.Writer) io.Writer {
+			return gzip.NewWriter(w)
+		}
+		f := NewFile(opts)
+		dw, err := f.NewDirectWriter("Sheet1", 1<<16)
+		require.NoError(t, err)
+		for i := 0; i < 2000; i++ {
+			_, err = dw.AddRow([]Cell{{Value: fmt.Sprintf("Product %d", i%50)}, {Value: "In stock"}, {Value: i}})
+			require.NoError(t, err)
+		}
+		require.NoError(t, dw.Close())
+		var out bytes.Buffer
+		_, err = f.WriteTo(&out)
+		require.NoError(t, err)
+		return out.Bytes()
+	}
+	gunzip := func(b []byte) []byte {
+		zr, err := gzip.NewReader(bytes.NewReader(b))
+		require.NoError(t, err)
+		plain, err := ioutil.ReadAll(zr)
+		require.NoError(t, err)
+		return plain
+	}
+	for _, opts := range []Options{{StoreOnly: true}, {StoreOnly: true, CompressionConcurrency: 4}} {
+		plain := gunzip(write(opts))
+		z, err := zip.NewReader(bytes.NewReader(plain), int64(len(plain)))
+		require.NoError(t, err)
+		for _, zf := range z.File {
+			assert.Equal(t, zip.Store, zf.Method, zf.Name)
+		}
+		f, err := OpenReader(bytes.NewReader(plain))
+		require.NoError(t, err)
+		assert.Empty(t, f.validateParts())
+		rows, err := f.GetRows("Sheet1")
+		require.NoError(t, err)
+		assert.Len(t, rows, 2000)
+		assert.Equal(t, []string{"Product 49", "In stock", "1999"}, rows[1999])
+		require.NoError(t, f.Close())
+	}
+}
+
 func TestClose(t *testing.T) {
 	f := NewFile()
 	f.tempFiles.Store("/d/", "/d/")
@@ -327,12 +327,45 @@ func TestBstrMarshal(t *testing.T) {
 		"*_x005F_*":       "*_x005F_x005F_*",
 		"*_x005F_xG006_*": "*_x005F_x005F_xG006_*",
 		"*_x005F_x0006_*": "*_x005F_x005F_x005F_x0006_*",
+		"plain":           "plain",
+		"_x_":             "_x_",
 	}
 	for bstr, expected := range bstrs {
 		assert.Equal(t, expected, bstrMarshal(bstr))
 	}
 }
 
+func TestAppendEscapedString(t *testing.T) {
+	for _, s := range []string{
+		"", "plain text, 123", "a<b", "<>&'\"", "trailing\n", "tab\tin", "caf\u00e9",
+		"\u00e9 first", "invalid \xff byte", "control \x01 char", "\U0001F600",
+	} {
+		var expected bytes.Buffer
+		assert.NoError(t, xml.EscapeText(&expected, []byte(s)))
+		assert.Equal(t, expected.String(), string(appendEscapedString([]byte("*"), s, true))[1:], s)
+	}
+	assert.Equal(t, "a\nb", string(appendEscapedString(nil, "a\nb", false)))
+	for mode, expected := range map[ControlCharMode]string{
+		ControlCharReplace: "nul \uFFFD, bell \uFFFD, vt \uFFFD&#x9;&lt;\uFFFD",
+		ControlCharStrip:   "nul , bell , vt &#x9;&lt;",
+		ControlCharEscape:  "nul _x0000_, bell _x0007_, vt _x000B_&#x9;&lt;\uFFFD",
+	} {
+		assert.Equal(t, expected, string(appendEscapedText(nil, "nul \x00, bell \a, vt \v\t<\xff", true, mode)), mode)
+	}
+	// the literal escape sequences are escaped in the escape mode only
+	for s, expected := range map[string]string{
+		"\x00_x0041_":       "_x0000__x005F_x0041_",
+		"_x005F_x0006_":     "_x005F_x005F_x005F_x0006_",
+		"a_x00_ _xG041_ _x": "a_x00_ _xG041_ _x",
+	} {
+		escaped := string(appendEscapedText(nil, s, true, ControlCharEscape))
+		assert.Equal(t, expected, escaped, s)
+		assert.Equal(t, s, bstrUnmarshal(escaped), s)
+	}
+	assert.Equal(t, "_x005F_x0041_&lt;", string(appendEscapedText(nil, "_x0041_<", true, ControlCharEscape)))
+	assert.Equal(t, "_x0041_", string(appendEscapedText(nil, "_x0041_", true, ControlCharReplace)))
+}
+
 func TestReadBytes(t *testing.T) {
 	f := &File{tempFiles: sync.Map{}}
 	sheet := "xl/worksheets/sheet1.xml"
@@ -0,0 +1,103 @@
+// Copyright 2016 - 2021 The excelize Authors. All rights reserved. Use of
+// this source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+//
+// Package excelize providing a set of functions that allow you to write to
+// and read from XLSX / XLSM / XLTM files. Supports reading and writing
+// spreadsheet documents generated by Microsoft Excel™ 2007 and later. Supports
+// complex components by high compatibility, and provided streaming API for
+// generating or reading data from a worksheet with huge amounts of data. This
+// library needs Go version 1.15 or later.
+
+package excelize
+
+import (
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// RateLimitedWriter is an io.Writer which limits the throughput of the
+// underlying writer to a maximum number of bytes per second with a token
+// bucket, and counts the bytes written, so that an export served over a slow
+// connection doesn't saturate the other traffic. The bucket holds the bytes
+// of one second, so a burst of up to one second of data is written at once
+// after the writer was idle, and the larger writes are split in chunks of
+// that size. It is safe for concurrent use.
+type RateLimitedWriter struct {
+	// written is accessed atomically, so that Written doesn't wait for a
+	// throttled write, and is the first field to be 64-bit aligned
+	written int64
+	sync.Mutex
+	w      io.Writer
+	rate   int
+	tokens float64
+	last   time.Time
+}
+
+// RateLimit provides a function to wrap the given writer in a
+// RateLimitedWriter, which writes at most the given number of bytes per
+// second. A zero or negative rate disables the limit, and only counts the
+// bytes written. For example, serve a workbook at 1 MiB per second:
+//
+//    if _, err := dw.WriteTo(excelize.RateLimit(conn, 1<<20)); err != nil {
+//        fmt.Println(err)
+//    }
+//
+func RateLimit(w io.Writer, bytesPerSecond int) *RateLimitedWriter {
+	return &RateLimitedWriter{w: w, rate: bytesPerSecond, tokens: float64(bytesPerSecond), last: time.Now()}
+}
+
+// Write writes the given bytes to the underlying writer, and blocks until
+// the rate limit allows each chunk to be written.
+func (rw *RateLimitedWriter) Write(p []byte) (n int, err error) {
+	rw.Lock()
+	defer rw.Unlock()
+	for len(p) > 0 {
+		chunk := p
+		if rw.rate > 0 {
+			if len(chunk) > rw.rate {
+				chunk = chunk[:rw.rate]
+			}
+			rw.wait(len(chunk))
+		}
+		var written int
+		written, err = rw.w.Write(chunk)
+		n += written
+		atomic.AddInt64(&rw.written, int64(written))
+		if err != nil {
+			return
+		}
+		if written < len(chunk) {
+			return n, io.ErrShortWrite
+		}
+		p = p[written:]
+	}
+	return
+}
+
+// wait refills the token bucket by the time elapsed since the last write,
+// and sleeps until it holds the given number of bytes, which are taken from
+// the bucket. The caller must hold the lock.
+func (rw *RateLimitedWriter) wait(size int) {
+	now := time.Now()
+	rw.tokens += now.Sub(rw.last).Seconds() * float64(rw.rate)
+	if rw.tokens > float64(rw.rate) {
+		rw.tokens = float64(rw.rate)
+	}
+	rw.last = now
+	if rw.tokens < float64(size) {
+		d := time.Duration((float64(size) - rw.tokens) / float64(rw.rate) * float64(time.Second))
+		time.Sleep(d)
+		// the tokens of an oversleep are added by the next refill
+		rw.last, rw.tokens = now.Add(d), float64(size)
+	}
+	rw.tokens -= float64(size)
+}
+
+// Written returns the number of bytes written to the underlying writer. It
+// doesn't block while a write is throttled.
+func (rw *RateLimitedWriter) Written() int64 {
+	return atomic.LoadInt64(&rw.written)
+}
@@ -0,0 +1,72 @@
+package excelize
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimit(t *testing.T) {
+	const rate = 1 << 20
+	var out bytes.Buffer
+	rw := RateLimit(&out, rate)
+	payload := bytes.Repeat([]byte("a"), rate/4)
+	start := time.Now()
+	// the first second of data is written at once from the full bucket
+	for i := 0; i < 4; i++ {
+		n, err := rw.Write(payload)
+		require.NoError(t, err)
+		assert.Equal(t, len(payload), n)
+	}
+	assert.Less(t, int64(time.Since(start)), int64(100*time.Millisecond))
+	// the next burst waits for the bucket to be refilled
+	n, err := rw.Write(append(payload, payload...))
+	require.NoError(t, err)
+	assert.Equal(t, 2*len(payload), n)
+	elapsed := time.Since(start)
+	assert.GreaterOrEqual(t, int64(elapsed), int64(450*time.Millisecond))
+	assert.Less(t, int64(elapsed), int64(time.Second))
+	assert.Equal(t, int64(6*len(payload)), rw.Written())
+	assert.Equal(t, 6*len(payload), out.Len())
+
+	// the progress is returned while a write is throttled
+	rw = RateLimit(io.Discard, 64<<10)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, _ = rw.Write(make([]byte, 96<<10))
+	}()
+	for rw.Written() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	start = time.Now()
+	assert.Equal(t, int64(64<<10), rw.Written())
+	assert.Less(t, int64(time.Since(start)), int64(100*time.Millisecond))
+	<-done
+	assert.Equal(t, int64(96<<10), rw.Written())
+
+	// a write larger than the bucket is split in chunks
+	rw = RateLimit(io.Discard, 64<<10)
+	start = time.Now()
+	n, err = rw.Write(make([]byte, 80<<10))
+	require.NoError(t, err)
+	assert.Equal(t, 80<<10, n)
+	assert.GreaterOrEqual(t, int64(time.Since(start)), int64(200*time.Millisecond))
+
+	// the bytes are only counted without limit
+	rw = RateLimit(io.Discard, 0)
+	n, err = rw.Write(make([]byte, 4<<20))
+	require.NoError(t, err)
+	assert.Equal(t, 4<<20, n)
+	assert.Equal(t, int64(4<<20), rw.Written())
+
+	// the error of the underlying writer is returned
+	rw = RateLimit(&failingWriter{limit: 100}, 1<<20)
+	n, err = rw.Write(make([]byte, 4096))
+	assert.EqualError(t, err, errFailingWriter.Error())
+	assert.Equal(t, int64(n), rw.Written())
+}
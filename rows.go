@@ -69,7 +69,7 @@ func (f *File) GetRows(sheet string, opts ...Options) ([][]string, error) {
 type Rows struct {
 	err                         error
 	curRow, totalRows, stashRow int
-	rawCellValue                bool
+	rawCellValue, assumeDense   bool
 	sheet                       string
 	f                           *File
 	tempFile                    *os.File
@@ -112,7 +112,8 @@ func (rows *Rows) Columns(opts ...Options) ([]string, error) {
 	if rows.stashRow >= rows.curRow {
 		return rowIterator.columns, rowIterator.err
 	}
-	rows.rawCellValue = parseOptions(opts...).RawCellValue
+	options := parseOptions(opts...)
+	rows.rawCellValue, rows.assumeDense = options.RawCellValue, options.AssumeDense
 	rowIterator.rows = rows
 	rowIterator.d = rows.f.sharedStringsReader()
 	for {
@@ -194,7 +195,7 @@ func rowXMLHandler(rowIterator *rowXMLIterator, xmlElement *xml.StartElement, ra
 		}
 		blank := rowIterator.cellCol - len(rowIterator.columns)
 		val, _ := colCell.getValueFrom(rowIterator.rows.f, rowIterator.d, raw)
-		if val != "" || colCell.F != nil {
+		if val != "" || colCell.F != nil || rowIterator.rows.assumeDense {
 			rowIterator.columns = append(appendSpace(blank, rowIterator.columns), val)
 		}
 	}
@@ -227,14 +228,7 @@ func (f *File) Rows(sheet string) (*Rows, error) {
 	if !ok {
 		return nil, ErrSheetNotExist{sheet}
 	}
-	if ws, ok := f.Sheet.Load(name); ok && ws != nil {
-		worksheet := ws.(*xlsxWorksheet)
-		worksheet.Lock()
-		defer worksheet.Unlock()
-		// flush data
-		output, _ := xml.Marshal(worksheet)
-		f.saveFileList(name, f.replaceNameSpaceBytes(name, output))
-	}
+	f.flushWorksheet(name)
 	var (
 		err       error
 		inElement string
@@ -279,6 +273,19 @@ func (f *File) Rows(sheet string) (*Rows, error) {
 	return &rows, nil
 }
 
+// flushWorksheet provides a function to serialize the worksheet by given path
+// in the zip if it has been loaded, so that the XML decoder reads the latest
+// data.
+func (f *File) flushWorksheet(name string) {
+	if ws, ok := f.Sheet.Load(name); ok && ws != nil {
+		worksheet := ws.(*xlsxWorksheet)
+		worksheet.Lock()
+		defer worksheet.Unlock()
+		output, _ := xml.Marshal(worksheet)
+		f.saveFileList(name, f.replaceNameSpaceBytes(name, output))
+	}
+}
+
 // sheetDecoder creates XML decoder by given path in the zip from memory data
 // or system temporary file.
 func (f *File) sheetDecoder(name string) (bool, *xml.Decoder, *os.File, error) {
@@ -411,6 +418,16 @@ func (c *xlsxC) getValueFrom(f *File, d *xlsxSST, raw bool) (string, error) {
 	f.Lock()
 	defer f.Unlock()
 	switch c.T {
+	case "b":
+		if !raw {
+			if c.V == "1" {
+				return "TRUE", nil
+			}
+			if c.V == "0" {
+				return "FALSE", nil
+			}
+		}
+		return f.formattedValue(c.S, c.V, raw), nil
 	case "s":
 		if c.V != "" {
 			xlsxSI := 0
@@ -97,6 +97,21 @@ func TestRowsIterator(t *testing.T) {
 	assert.Equal(t, expectedNumRow, rowCount)
 }
 
+func TestRowsWithoutCellReference(t *testing.T) {
+	// Test the cells without reference are placed in the column after the
+	// previous cell, without the AssumeDense option.
+	f := NewFile()
+	f.Sheet.Delete("xl/worksheets/sheet1.xml")
+	delete(f.checked, "xl/worksheets/sheet1.xml")
+	f.Pkg.Store("xl/worksheets/sheet1.xml", []byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?><worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData><row><c t="inlineStr"><is><t>a</t></is></c><c/><c><v>3</v></c></row><row><c/><c r="C2"><v>2</v></c><c><v>4</v></c><c/></row></sheetData></worksheet>`))
+	rows, err := f.GetRows("Sheet1")
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{{"a", "", "3"}, {"", "", "2", "4"}}, rows)
+	rows, err = f.GetRows("Sheet1", Options{AssumeDense: true})
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{{"a", "", "3"}, {"", "", "2", "4", ""}}, rows)
+}
+
 func TestRowsError(t *testing.T) {
 	f, err := OpenFile(filepath.Join("test", "Book1.xlsx"))
 	if !assert.NoError(t, err) {
@@ -88,7 +88,9 @@ func (f *File) contentTypesReader() *xlsxTypes {
 // serialize structure.
 func (f *File) contentTypesWriter() {
 	if f.ContentTypes != nil {
+		f.ContentTypes.Lock()
 		output, _ := xml.Marshal(f.ContentTypes)
+		f.ContentTypes.Unlock()
 		f.saveFileList("[Content_Types].xml", output)
 	}
 }
@@ -202,7 +204,12 @@ func (f *File) workSheetWriter() {
 	buffer := bytes.NewBuffer(arr)
 	encoder := xml.NewEncoder(buffer)
 	f.Sheet.Range(func(p, ws interface{}) bool {
-		if ws != nil {
+		// the worksheets of direct writers are written by the direct writers,
+		// and may still be updated while streaming, and the sheets created by
+		// DirectWriter.NextSheet meanwhile are added under the lock
+		f.Lock()
+		defer f.Unlock()
+		if ws != nil && !f.hasDirectWriter(p.(string)) {
 			sheet := ws.(*xlsxWorksheet)
 			if sheet.MergeCells != nil && len(sheet.MergeCells.Cells) > 0 {
 				_ = f.mergeOverlapCells(sheet)
@@ -219,8 +226,7 @@ func (f *File) workSheetWriter() {
 			// reusing buffer
 			_ = encoder.Encode(sheet)
 			f.saveFileList(p.(string), replaceRelationshipsBytes(f.replaceNameSpaceBytes(p.(string), buffer.Bytes())))
-			ok := f.checked[p.(string)]
-			if ok {
+			if f.checked[p.(string)] {
 				f.Sheet.Delete(p.(string))
 				f.checked[p.(string)] = false
 			}
@@ -812,11 +818,18 @@ func parseFormatPanesSet(formatSet string) (*formatPanes, error) {
 //    f.SetPanes("Sheet1", `{"freeze":false,"split":false}`)
 //
 func (f *File) SetPanes(sheet, panes string) error {
-	fs, _ := parseFormatPanesSet(panes)
 	ws, err := f.workSheetReader(sheet)
 	if err != nil {
 		return err
 	}
+	ws.setPanes(panes)
+	return err
+}
+
+// setPanes provides a function to set the panes of the worksheet by given
+// panes format set.
+func (ws *xlsxWorksheet) setPanes(panes string) {
+	fs, _ := parseFormatPanesSet(panes)
 	p := &xlsxPane{
 		ActivePane:  fs.ActivePane,
 		TopLeftCell: fs.TopLeftCell,
@@ -841,7 +854,6 @@ func (f *File) SetPanes(sheet, panes string) error {
 		})
 	}
 	ws.SheetViews.SheetView[len(ws.SheetViews.SheetView)-1].Selection = s
-	return err
 }
 
 // GetSheetVisible provides a function to get worksheet visible by given worksheet
@@ -1562,6 +1574,76 @@ func (f *File) SetDefinedName(definedName *DefinedName) error {
 	return nil
 }
 
+// deferredDefinedName is a workbook defined name whose reference is resolved
+// on saving the spreadsheet.
+type deferredDefinedName struct {
+	name     string
+	resolver func() string
+}
+
+// SetDefinedNameDeferred provides a function to set a defined name of the
+// workbook, whose reference is returned by the given resolver on saving the
+// spreadsheet, after all direct writers are closed, so that it may refer to a
+// range whose extent is only known after streaming, such as from the row
+// count of a DirectWriter. The defined name is not written if the resolver
+// returns an empty string, and it is updated by each save. For example:
+//
+//    err := f.SetDefinedNameDeferred("SalesData", func() string {
+//        rows, _, _ := dw.Stats()
+//        return fmt.Sprintf("Sheet1!$A$1:$D$%d", rows)
+//    })
+//
+func (f *File) SetDefinedNameDeferred(name string, resolver func() string) error {
+	if name == "" || resolver == nil {
+		return ErrParameterRequired
+	}
+	if wb := f.workbookReader(); wb.DefinedNames != nil {
+		for _, dn := range wb.DefinedNames.DefinedName {
+			if dn.LocalSheetID == nil && dn.Name == name {
+				return ErrDefinedNameduplicate
+			}
+		}
+	}
+	for _, d := range f.deferredNames {
+		if d.name == name {
+			return ErrDefinedNameduplicate
+		}
+	}
+	f.deferredNames = append(f.deferredNames, deferredDefinedName{name: name, resolver: resolver})
+	return nil
+}
+
+// resolveDeferredNames provides a function to set the references of the
+// deferred defined names to the workbook.
+func (f *File) resolveDeferredNames() {
+	if len(f.deferredNames) == 0 {
+		return
+	}
+	wb := f.workbookReader()
+	for _, d := range f.deferredNames {
+		refersTo, idx := d.resolver(), -1
+		if wb.DefinedNames == nil {
+			wb.DefinedNames = &xlsxDefinedNames{}
+		}
+		for i, dn := range wb.DefinedNames.DefinedName {
+			if dn.LocalSheetID == nil && dn.Name == d.name {
+				idx = i
+			}
+		}
+		switch {
+		case idx == -1 && refersTo != "":
+			wb.DefinedNames.DefinedName = append(wb.DefinedNames.DefinedName, xlsxDefinedName{Name: d.name, Data: refersTo})
+		case idx != -1 && refersTo != "":
+			wb.DefinedNames.DefinedName[idx].Data = refersTo
+		case idx != -1:
+			wb.DefinedNames.DefinedName = append(wb.DefinedNames.DefinedName[:idx], wb.DefinedNames.DefinedName[idx+1:]...)
+		}
+		if len(wb.DefinedNames.DefinedName) == 0 {
+			wb.DefinedNames = nil
+		}
+	}
+}
+
 // DeleteDefinedName provides a function to delete the defined names of the
 // workbook or worksheet. If not specified scope, the default scope is
 // workbook. For example:
@@ -271,6 +271,47 @@ func TestDefinedName(t *testing.T) {
 	assert.NoError(t, f.SaveAs(filepath.Join("test", "TestDefinedName.xlsx")))
 }
 
+func TestSetDefinedNameDeferred(t *testing.T) {
+	f := NewFile()
+	dw, err := f.NewDirectWriter("Sheet1", 1)
+	assert.NoError(t, err)
+	assert.NoError(t, f.SetDefinedName(&DefinedName{Name: "Amount", RefersTo: "Sheet1!$A$1"}))
+	resolver := func() string {
+		rows, _, _ := dw.Stats()
+		return fmt.Sprintf("Sheet1!$A$1:$B$%d", rows)
+	}
+	assert.EqualError(t, f.SetDefinedNameDeferred("", resolver), ErrParameterRequired.Error())
+	assert.EqualError(t, f.SetDefinedNameDeferred("SalesData", nil), ErrParameterRequired.Error())
+	assert.EqualError(t, f.SetDefinedNameDeferred("Amount", resolver), ErrDefinedNameduplicate.Error())
+	assert.NoError(t, f.SetDefinedNameDeferred("SalesData", resolver))
+	assert.EqualError(t, f.SetDefinedNameDeferred("SalesData", resolver), ErrDefinedNameduplicate.Error())
+	var empty string
+	assert.NoError(t, f.SetDefinedNameDeferred("Empty", func() string { return empty }))
+	for row := 1; row <= 42; row++ {
+		_, err = dw.AddRow([]Cell{{Value: row}, {Value: "Sales"}})
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, dw.Close())
+	buf, err := f.WriteToBuffer()
+	assert.NoError(t, err)
+	f, err = OpenReader(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, []DefinedName{
+		{Name: "Amount", RefersTo: "Sheet1!$A$1", Scope: "Workbook"},
+		{Name: "SalesData", RefersTo: "Sheet1!$A$1:$B$42", Scope: "Workbook"},
+	}, f.GetDefinedName())
+
+	// the deferred names are resolved on each save without direct writers
+	f = NewFile()
+	assert.NoError(t, f.SetDefinedNameDeferred("Empty", func() string { return empty }))
+	for _, empty = range []string{"Sheet1!$A$1", "Sheet1!$A$2", ""} {
+		buf, err = f.WriteToBuffer()
+		assert.NoError(t, err)
+		assert.Equal(t, empty != "", strings.Contains(readZipEntry(t, buf.Bytes(), "xl/workbook.xml"), `<definedName name="Empty">`+empty+`</definedName>`))
+	}
+	assert.Nil(t, f.WorkBook.DefinedNames)
+}
+
 func TestGroupSheets(t *testing.T) {
 	f := NewFile()
 	sheets := []string{"Sheet2", "Sheet3"}
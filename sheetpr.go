@@ -186,16 +186,21 @@ func (f *File) SetSheetPrOptions(name string, opts ...SheetPrOption) error {
 	if err != nil {
 		return err
 	}
+	ws.setSheetPrOptions(opts...)
+	return err
+}
+
+// setSheetPrOptions provides a function to set the sheet properties of the
+// worksheet by given options.
+func (ws *xlsxWorksheet) setSheetPrOptions(opts ...SheetPrOption) {
 	pr := ws.SheetPr
 	if pr == nil {
 		pr = new(xlsxSheetPr)
 		ws.SheetPr = pr
 	}
-
 	for _, opt := range opts {
 		opt.setSheetPrOption(pr)
 	}
-	return err
 }
 
 // GetSheetPrOptions provides a function to gets worksheet properties.
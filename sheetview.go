@@ -145,6 +145,12 @@ func (f *File) getSheetView(sheet string, viewIndex int) (*xlsxSheetView, error)
 	if err != nil {
 		return nil, err
 	}
+	return ws.getSheetView(viewIndex)
+}
+
+// getSheetView returns the SheetView object of the worksheet by given view
+// index.
+func (ws *xlsxWorksheet) getSheetView(viewIndex int) (*xlsxSheetView, error) {
 	if viewIndex < 0 {
 		if viewIndex < -len(ws.SheetViews.SheetView) {
 			return nil, fmt.Errorf("view index %d out of range", viewIndex)
@@ -154,7 +160,7 @@ func (f *File) getSheetView(sheet string, viewIndex int) (*xlsxSheetView, error)
 		return nil, fmt.Errorf("view index %d out of range", viewIndex)
 	}
 
-	return &(ws.SheetViews.SheetView[viewIndex]), err
+	return &(ws.SheetViews.SheetView[viewIndex]), nil
 }
 
 // SetSheetViewOptions sets sheet view options. The viewIndex may be negative
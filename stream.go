@@ -13,10 +13,13 @@ package excelize
 
 import (
 	"bytes"
+	"encoding/json"
 	"encoding/xml"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"math"
+	"math/big"
 	"os"
 	"reflect"
 	"strconv"
@@ -30,12 +33,14 @@ type StreamWriter struct {
 	Sheet           string
 	SheetID         int
 	sheetWritten    bool
-	cols            string
+	cols            []streamCol
 	worksheet       *xlsxWorksheet
 	rawData         bufferedWriter
 	mergeCellsCount int
 	mergeCells      string
 	tableParts      string
+	sharedFormulas  [][]int
+	comments        []Comment
 }
 
 // NewStreamWriter return stream writer struct by given worksheet name for
@@ -111,10 +116,74 @@ func (f *File) NewStreamWriter(sheet string) (*StreamWriter, error) {
 		f.streams = make(map[string]*StreamWriter)
 	}
 	f.streams[sheetPath] = sw
+	return sw, err
+}
+
+// streamCol directly maps the width and the style of a column of the
+// StreamWriter, the columns are merged into the ranges of the cols element
+// when the worksheet header is written.
+type streamCol struct {
+	width       float64
+	customWidth bool
+	style       int
+}
+
+// setCols provides a function to update the columns min to max of the
+// StreamWriter by the given function.
+func (sw *StreamWriter) setCols(min, max int, fn func(col *streamCol)) {
+	if len(sw.cols) < max {
+		sw.cols = append(sw.cols, make([]streamCol, max-len(sw.cols))...)
+	}
+	for col := min; col <= max; col++ {
+		fn(&sw.cols[col-1])
+	}
+}
+
+// colsXML provides a function to build the cols element of the StreamWriter
+// with sorted, non-overlapping ranges of the adjacent columns which have the
+// same width and style.
+func (sw *StreamWriter) colsXML() string {
+	var cols strings.Builder
+	for min := 1; min <= len(sw.cols); {
+		col, max := sw.cols[min-1], min
+		for max < len(sw.cols) && sw.cols[max] == col {
+			max++
+		}
+		if col != (streamCol{}) {
+			width := defaultColWidth
+			if col.customWidth {
+				width = col.width
+			}
+			cols.WriteString(fmt.Sprintf(`<col min="%d" max="%d" width="%f"`, min, max, width))
+			if col.customWidth {
+				cols.WriteString(` customWidth="1"`)
+			}
+			if col.style != 0 {
+				cols.WriteString(fmt.Sprintf(` style="%d"`, col.style))
+			}
+			cols.WriteString(`/>`)
+		}
+		min = max + 1
+	}
+	if cols.Len() == 0 {
+		return ""
+	}
+	return "<cols>" + cols.String() + "</cols>"
+}
 
+// writeSheetData provides a function to write the worksheet header, the
+// columns and the start of the sheet data on the first row or on Flush, so
+// that the sheet properties and views may be set after the stream writer is
+// created.
+func (sw *StreamWriter) writeSheetData() {
+	if sw.sheetWritten {
+		return
+	}
 	_, _ = sw.rawData.WriteString(XMLHeader + `<worksheet` + templateNamespaceIDMap)
 	bulkAppendFields(&sw.rawData, sw.worksheet, 2, 5)
-	return sw, err
+	_, _ = sw.rawData.WriteString(sw.colsXML())
+	_, _ = sw.rawData.WriteString(`<sheetData>`)
+	sw.sheetWritten = true
 }
 
 // AddTable creates an Excel table for the StreamWriter using the given
@@ -287,20 +356,84 @@ func getRowElement(token xml.Token, hrow int) (startElement xml.StartElement, ok
 	return
 }
 
-// Cell can be used directly in StreamWriter.SetRow to specify a style and
-// a value.
+// Cell can be used directly in StreamWriter.SetRow to specify a style and a
+// value. If both Formula and Value are set, the value is written as the cached
+// result of the formula, which is shown by the applications that don't
+// recalculate the formulas on load. The FormulaOpts can be used to set other
+// formula types, see StreamWriter.SetRow for shared formulas. If RawValue is
+// not nil, it is written verbatim as the numeric value of the cell instead of
+// Value, without conversion or escaping, so it must contain a pre-formatted
+// number such as []byte("42"). If NumFmt is not empty, the cell is styled with
+// the given number format code, such as "0.00%", instead of StyleID. The style
+// of each number format code is created on first use and cached by the File. If
+// Hyperlink is not nil, the hyperlink is set on the cell, and its display text
+// is written as the value of the cell if the cell has no value or formula.
+// CachedValue is only set by the StreamReader, for the cells with a formula it
+// is the unformatted cached result of the formula, and it is ignored by the
+// writers. Boolean results are returned raw as "0" or "1" in CachedValue,
+// while Value is "FALSE" or "TRUE".
 type Cell struct {
-	StyleID int
-	Formula string
-	Value   interface{}
+	StyleID     int
+	Formula     string
+	FormulaOpts *FormulaOpts
+	Value       interface{}
+	CachedValue string
+	RawValue    []byte
+	NumFmt      string
+	Hyperlink   *CellHyperlink
+}
+
+// CellHyperlink directly maps the hyperlink of a Cell. LinkType defines the
+// types of hyperlink as File.SetCellHyperLink, "External" for web site, which
+// is the default, or "Location" for moving to one of cell in this workbook
+// such as "Sheet1!A40". Display and Tooltip are optional, the tooltip is shown
+// when hovering over the cell.
+type CellHyperlink struct {
+	Link     string
+	LinkType string
+	Display  string
+	Tooltip  string
+}
+
+// options returns the link type and the options of the hyperlink for
+// File.SetCellHyperLink.
+func (h *CellHyperlink) options() (linkType string, opts HyperlinkOpts, err error) {
+	switch linkType = h.LinkType; linkType {
+	case "":
+		linkType = "External"
+	case "External", "Location":
+	default:
+		return linkType, opts, fmt.Errorf("invalid link type %q", linkType)
+	}
+	if h.Display != "" {
+		opts.Display = &h.Display
+	}
+	if h.Tooltip != "" {
+		opts.Tooltip = &h.Tooltip
+	}
+	return
+}
+
+// value returns the given value of a cell, or the display text of the
+// hyperlink if the cell has no value or formula.
+func (h *CellHyperlink) value(c Cell) interface{} {
+	if h != nil && h.Display != "" && c.Value == nil && c.RawValue == nil && c.Formula == "" {
+		return h.Display
+	}
+	return c.Value
 }
 
 // RowOpts define the options for the set row, it can be used directly in
-// StreamWriter.SetRow to specify the style and properties of the row.
+// StreamWriter.SetRow and DirectWriter.AddRow to specify the style and
+// properties of the row. The value of OutlineLevel is 1-7, and zero means the
+// row is not outlined. Collapsed marks the summary row of a collapsed group of
+// outlined rows, which are usually hidden.
 type RowOpts struct {
-	Height  float64
-	Hidden  bool
-	StyleID int
+	Height       float64
+	Hidden       bool
+	StyleID      int
+	OutlineLevel uint8
+	Collapsed    bool
 }
 
 // SetRow writes an array to stream rows by giving a worksheet name, starting
@@ -309,18 +442,28 @@ type RowOpts struct {
 //
 // As a special case, if Cell is used as a value, then the Cell.StyleID will be
 // applied to that cell.
+//
+// A value of type []RichTextRun is written as an inline rich string, with the
+// fonts of the runs as File.SetCellRichText does, and a value of type
+// ErrorValue is written as an error cell.
+//
+// A formula can be shared across a range by setting the shared formula type
+// and the range on the master cell, which must be the top-left cell of the
+// range. The cells in the range set by subsequent calls with a nil value and
+// without formula only refer to the shared formula, the cells with a value
+// are written as is. For example, share the formula "A1+B1" across C1:C100:
+//
+//    formulaType, ref := excelize.STCellFormulaTypeShared, "C1:C100"
+//    err := streamWriter.SetRow("A1", []interface{}{1, 2, excelize.Cell{
+//        Formula: "A1+B1", FormulaOpts: &excelize.FormulaOpts{Type: &formulaType, Ref: &ref},
+//    }})
+//
 func (sw *StreamWriter) SetRow(axis string, values []interface{}, opts ...RowOpts) error {
 	col, row, err := CellNameToCoordinates(axis)
 	if err != nil {
 		return err
 	}
-	if !sw.sheetWritten {
-		if len(sw.cols) > 0 {
-			_, _ = sw.rawData.WriteString("<cols>" + sw.cols + "</cols>")
-		}
-		_, _ = sw.rawData.WriteString(`<sheetData>`)
-		sw.sheetWritten = true
-	}
+	sw.writeSheetData()
 	attrs, err := marshalRowAttrs(opts...)
 	if err != nil {
 		return err
@@ -332,25 +475,68 @@ func (sw *StreamWriter) SetRow(axis string, values []interface{}, opts ...RowOpt
 			return err
 		}
 		c := xlsxC{R: axis}
+		var formulaOpts *FormulaOpts
+		var rawValue []byte
+		var numFmt string
+		var hyperlink *CellHyperlink
 		if v, ok := val.(Cell); ok {
 			c.S = v.StyleID
-			val = v.Value
+			val = v.Hyperlink.value(v)
 			setCellFormula(&c, v.Formula)
+			formulaOpts = v.FormulaOpts
+			rawValue = v.RawValue
+			numFmt = v.NumFmt
+			hyperlink = v.Hyperlink
 		} else if v, ok := val.(*Cell); ok && v != nil {
 			c.S = v.StyleID
-			val = v.Value
+			val = v.Hyperlink.value(*v)
 			setCellFormula(&c, v.Formula)
+			formulaOpts = v.FormulaOpts
+			rawValue = v.RawValue
+			numFmt = v.NumFmt
+			hyperlink = v.Hyperlink
+		}
+		if c.S == 0 && col+i <= len(sw.cols) {
+			c.S = sw.cols[col+i-1].style
+		}
+		if numFmt != "" {
+			if c.S, err = sw.File.numFmtStyleID(numFmt); err != nil {
+				_, _ = sw.rawData.WriteString(`</row>`)
+				return err
+			}
 		}
-		if err = setCellValFunc(&c, val); err != nil {
+		if err = sw.setCellFormulaOpts(&c, col+i, row, formulaOpts, val == nil && rawValue == nil); err != nil {
+			_, _ = sw.rawData.WriteString(`</row>`)
+			return err
+		}
+		if rawValue != nil {
+			c.V = string(rawValue)
+		} else if err = sw.File.setStreamCellValFunc(&c, val); err != nil {
 			_, _ = sw.rawData.WriteString(`</row>`)
 			return err
 		}
 		writeCell(&sw.rawData, c)
+		if hyperlink != nil {
+			if err = sw.setCellHyperlink(axis, hyperlink); err != nil {
+				_, _ = sw.rawData.WriteString(`</row>`)
+				return err
+			}
+		}
 	}
 	_, _ = sw.rawData.WriteString(`</row>`)
 	return sw.rawData.Sync()
 }
 
+// setCellHyperlink sets the hyperlink of the given cell, which is written
+// after the sheet data by Flush.
+func (sw *StreamWriter) setCellHyperlink(axis string, hyperlink *CellHyperlink) error {
+	linkType, opts, err := hyperlink.options()
+	if err != nil {
+		return err
+	}
+	return sw.File.SetCellHyperLink(sw.Sheet, axis, hyperlink.Link, linkType, opts)
+}
+
 // marshalRowAttrs prepare attributes of the row by given options.
 func marshalRowAttrs(opts ...RowOpts) (attrs string, err error) {
 	var opt *RowOpts
@@ -364,15 +550,25 @@ func marshalRowAttrs(opts ...RowOpts) (attrs string, err error) {
 		err = ErrMaxRowHeight
 		return
 	}
+	if opt.OutlineLevel > 7 {
+		err = ErrOutlineLevel
+		return
+	}
 	if opt.StyleID > 0 {
 		attrs += fmt.Sprintf(` s="%d" customFormat="true"`, opt.StyleID)
 	}
 	if opt.Height > 0 {
-		attrs += fmt.Sprintf(` ht="%v" customHeight="true"`, opt.Height)
+		attrs += fmt.Sprintf(` ht="%v" customHeight="1"`, opt.Height)
 	}
 	if opt.Hidden {
 		attrs += ` hidden="true"`
 	}
+	if opt.OutlineLevel > 0 {
+		attrs += fmt.Sprintf(` outlineLevel="%d"`, opt.OutlineLevel)
+	}
+	if opt.Collapsed {
+		attrs += ` collapsed="true"`
+	}
 	return
 }
 
@@ -399,10 +595,95 @@ func (sw *StreamWriter) SetColWidth(min, max int, width float64) error {
 	if min > max {
 		min, max = max, min
 	}
-	sw.cols += fmt.Sprintf(`<col min="%d" max="%d" width="%f" customWidth="1"/>`, min, max, width)
+	sw.setCols(min, max, func(col *streamCol) {
+		col.width, col.customWidth = width, true
+	})
 	return nil
 }
 
+// SetColStyle provides a function to set the default style of a single
+// column or multiple columns for the StreamWriter. The cells of the columns
+// written by the 'SetRow' function without a style ID get the style of the
+// column, and the empty cells of the columns are displayed with it. The
+// columns may overlap the columns of the 'SetColWidth' function or of the
+// earlier calls, the later call takes precedence for the style. Note that you
+// must call the 'SetColStyle' function before the 'SetRow' function. For
+// example set the style of column B:C:
+//
+//    err := streamWriter.SetColStyle(2, 3, styleID)
+//
+func (sw *StreamWriter) SetColStyle(min, max, styleID int) error {
+	if sw.sheetWritten {
+		return ErrStreamSetColStyle
+	}
+	if min > TotalColumns || max > TotalColumns {
+		return ErrColumnNumber
+	}
+	if min < 1 || max < 1 {
+		return ErrColumnNumber
+	}
+	if styleID < 0 {
+		return newInvalidStyleID(styleID)
+	}
+	if min > max {
+		min, max = max, min
+	}
+	sw.setCols(min, max, func(col *streamCol) {
+		col.style = styleID
+	})
+	return nil
+}
+
+// AddComment provides a function to add a comment to the given cell of the
+// StreamWriter. The author and text of the comment default to the same values
+// as for File.AddComment. The comments are buffered, and the comments and VML
+// drawing parts and the legacy drawing of the worksheet are added by the
+// 'Flush' function, so comments may be added to rows which have already been
+// written. For example, add a comment in A1:
+//
+//    err := streamWriter.AddComment("A1", excelize.Comment{Author: "Excelize: ", Text: "This is a comment."})
+//
+func (sw *StreamWriter) AddComment(cell string, comment Comment) error {
+	if _, _, err := CellNameToCoordinates(cell); err != nil {
+		return err
+	}
+	if comment.Author == "" {
+		comment.Author = "Author:"
+	}
+	if comment.Text == "" {
+		comment.Text = " "
+	}
+	comment.Ref = cell
+	sw.comments = append(sw.comments, comment)
+	return nil
+}
+
+// SetPanes provides a function to create and remove freeze panes and split
+// panes for the StreamWriter by given panes format set, see File.SetPanes for
+// details on the format. Note that you must call the 'SetPanes' function
+// before the 'SetRow' function. For example, freeze the first row:
+//
+//    err := streamWriter.SetPanes(`{"freeze":true,"split":false,"x_split":0,"y_split":1,"top_left_cell":"A2","active_pane":"bottomLeft"}`)
+//
+func (sw *StreamWriter) SetPanes(panes string) error {
+	if sw.sheetWritten {
+		return ErrStreamSetPanes
+	}
+	return sw.File.SetPanes(sw.Sheet, panes)
+}
+
+// AutoFilter provides a function to create an auto filter for the
+// StreamWriter by given coordinate area and format set, see File.AutoFilter
+// for details on the format. The auto filter is written after the sheet data
+// on Flush, so it may be called at any time before Flush. For example, create
+// an auto filter on the header row A1:F1:
+//
+//    err := streamWriter.AutoFilter("A1", "F1", "")
+//
+func (sw *StreamWriter) AutoFilter(hcell, vcell, format string) error {
+	return sw.File.AutoFilter(sw.Sheet, hcell, vcell, format)
+}
+
 // MergeCell provides a function to merge cells by a given coordinate area for
 // the StreamWriter. Don't create a merged cell that overlaps with another
 // existing merged cell.
@@ -423,15 +704,84 @@ func setCellFormula(c *xlsxC, formula string) {
 	}
 }
 
-// setCellValFunc provides a function to set value of a cell.
+// setCellFormulaOpts provides a function to set formula options of a cell by
+// given column and row number. A blank cell without formula in the range of a
+// shared formula refers to that shared formula.
+func (sw *StreamWriter) setCellFormulaOpts(c *xlsxC, col, row int, opts *FormulaOpts, blank bool) error {
+	if c.F == nil {
+		if !blank {
+			return nil
+		}
+		for si, rect := range sw.sharedFormulas {
+			if cellInRef([]int{col, row}, rect) {
+				si := si
+				c.F = &xlsxF{T: STCellFormulaTypeShared, Si: &si}
+				break
+			}
+		}
+		return nil
+	}
+	if opts == nil {
+		return nil
+	}
+	if opts.Type != nil {
+		c.F.T = *opts.Type
+	}
+	if opts.Ref != nil {
+		c.F.Ref = *opts.Ref
+	}
+	if c.F.T != STCellFormulaTypeShared {
+		return nil
+	}
+	if opts.Ref == nil {
+		return ErrParameterRequired
+	}
+	rect, err := areaRefToCoordinates(*opts.Ref)
+	if err != nil {
+		return err
+	}
+	_ = sortCoordinates(rect)
+	if rect[0] != col || rect[1] != row {
+		return ErrParameterInvalid
+	}
+	si := len(sw.sharedFormulas)
+	c.F.Si = &si
+	sw.sharedFormulas = append(sw.sharedFormulas, rect)
+	return nil
+}
+
+// ErrorValue can be used as a value of the stream writers to write an error
+// cell, such as ErrorValue("#N/A"). The value must be one of the error
+// literals of the spreadsheet: #NULL!, #DIV/0!, #VALUE!, #REF!, #NAME?,
+// #NUM!, #N/A or #GETTING_DATA.
+type ErrorValue string
+
+// setCellError provides a function to set the error value of a cell.
+func setCellError(c *xlsxC, val ErrorValue) error {
+	switch val {
+	case formulaErrorNULL, formulaErrorDIV, formulaErrorVALUE, formulaErrorREF,
+		formulaErrorNAME, formulaErrorNUM, formulaErrorNA, formulaErrorGETTINGDATA:
+		c.T, c.V = "e", string(val)
+		return nil
+	}
+	return newInvalidErrorValue(string(val))
+}
+
+// setCellValFunc provides a function to set value of a cell. A NaN or
+// infinite number is set as the #NUM! error, and ErrNonFiniteNumber is
+// returned.
 func setCellValFunc(c *xlsxC, val interface{}) (err error) {
 	switch val := val.(type) {
 	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
 		err = setCellIntFunc(c, val)
 	case float32:
-		c.T, c.V = setCellFloat(float64(val), -1, 32)
+		if err = setCellNonFinite(c, float64(val)); err == nil {
+			c.T, c.V = setCellFloat(float64(val), -1, 32)
+		}
 	case float64:
-		c.T, c.V = setCellFloat(val, -1, 64)
+		if err = setCellNonFinite(c, val); err == nil {
+			c.T, c.V = setCellFloat(val, -1, 64)
+		}
 	case string:
 		c.T, c.V, c.XMLSpace = setCellStr(val)
 	case []byte:
@@ -442,6 +792,17 @@ func setCellValFunc(c *xlsxC, val interface{}) (err error) {
 		c.T, c.V, _, err = setCellTime(val)
 	case bool:
 		c.T, c.V = setCellBool(val)
+	case *big.Int:
+		c.T, c.V = setCellBigInt(val)
+	case *big.Rat:
+		c.T, c.V = setCellBigRat(val)
+	case json.Number:
+		c.T, c.V, c.XMLSpace = setCellJSONNumber(val)
+	case []RichTextRun:
+		c.T, c.IS = "inlineStr", &xlsxSI{}
+		c.IS.R, err = newRichTextRuns(val)
+	case ErrorValue:
+		err = setCellError(c, val)
 	case nil:
 		c.T, c.V, c.XMLSpace = setCellStr("")
 	default:
@@ -450,6 +811,27 @@ func setCellValFunc(c *xlsxC, val interface{}) (err error) {
 	return err
 }
 
+// setCellNonFinite provides a function to set the #NUM! error to the cell if
+// the given number is NaN or infinite, and returns ErrNonFiniteNumber.
+func setCellNonFinite(c *xlsxC, val float64) error {
+	if !math.IsNaN(val) && !math.IsInf(val, 0) {
+		return nil
+	}
+	c.T, c.V = "e", "#NUM!"
+	return ErrNonFiniteNumber
+}
+
+// setStreamCellValFunc provides a function to set value of a cell of the
+// stream writers, the NaN and infinite numbers are written as the #NUM!
+// error unless they are rejected by the options.
+func (f *File) setStreamCellValFunc(c *xlsxC, val interface{}) error {
+	err := setCellValFunc(c, val)
+	if err == ErrNonFiniteNumber && (f.options == nil || !f.options.RejectNonFinite) {
+		return nil
+	}
+	return err
+}
+
 // setCellIntFunc is a wrapper of SetCellInt.
 func setCellIntFunc(c *xlsxC, val interface{}) (err error) {
 	switch val := val.(type) {
@@ -492,7 +874,21 @@ func writeCell(buf *bufferedWriter, c xlsxC) {
 	}
 	_, _ = buf.WriteString(`>`)
 	if c.F != nil {
-		_, _ = buf.WriteString(`<f>`)
+		_, _ = buf.WriteString(`<f`)
+		if c.F.T != "" {
+			_, _ = buf.WriteString(` t="`)
+			_ = xml.EscapeText(buf, []byte(c.F.T))
+			_, _ = buf.WriteString(`"`)
+		}
+		if c.F.Ref != "" {
+			_, _ = buf.WriteString(` ref="`)
+			_ = xml.EscapeText(buf, []byte(c.F.Ref))
+			_, _ = buf.WriteString(`"`)
+		}
+		if c.F.Si != nil {
+			fmt.Fprintf(buf, ` si="%d"`, *c.F.Si)
+		}
+		_, _ = buf.WriteString(`>`)
 		_ = xml.EscapeText(buf, []byte(c.F.Content))
 		_, _ = buf.WriteString(`</f>`)
 	}
@@ -501,15 +897,21 @@ func writeCell(buf *bufferedWriter, c xlsxC) {
 		_ = xml.EscapeText(buf, []byte(c.V))
 		_, _ = buf.WriteString(`</v>`)
 	}
+	if c.IS != nil {
+		_ = xml.NewEncoder(buf).EncodeElement(c.IS, xml.StartElement{Name: xml.Name{Local: "is"}})
+	}
 	_, _ = buf.WriteString(`</c>`)
 }
 
 // Flush ending the streaming writing process.
 func (sw *StreamWriter) Flush() error {
-	if !sw.sheetWritten {
-		_, _ = sw.rawData.WriteString(`<sheetData>`)
-		sw.sheetWritten = true
+	for _, c := range sw.comments {
+		if err := sw.File.addSheetComment(sw.Sheet, c.Ref, &formatComment{Author: c.Author, Text: c.Text}); err != nil {
+			return err
+		}
 	}
+	sw.comments = nil
+	sw.writeSheetData()
 	_, _ = sw.rawData.WriteString(`</sheetData>`)
 	bulkAppendFields(&sw.rawData, sw.worksheet, 8, 15)
 	if sw.mergeCellsCount > 0 {
@@ -532,6 +934,150 @@ func (sw *StreamWriter) Flush() error {
 	return nil
 }
 
+// StreamReader defined the type of stream reader, which decodes the rows of a
+// worksheet one by one into the Cell type used by the StreamWriter and the
+// DirectWriter.
+type StreamReader struct {
+	f        *File
+	decoder  *xml.Decoder
+	tempFile *os.File
+	sst      *xlsxSST
+	curRow   int
+	row      []Cell
+	err      error
+}
+
+// StreamRows returns a stream reader by given worksheet name, used for
+// reading a worksheet with huge amounts of data row by row without
+// unmarshalling the whole worksheet. The value of each cell is the formatted
+// cell value string. For the cells with a formula, the formula and the
+// unformatted cached result are returned in Formula and CachedValue, so the
+// cached results can be read without recalculation. Note that the rows
+// without any cell are skipped, use CurrentRow to get the row number. For
+// example:
+//
+//    sr, err := f.StreamRows("Sheet1")
+//    if err != nil {
+//        fmt.Println(err)
+//        return
+//    }
+//    for sr.Next() {
+//        for _, cell := range sr.Row() {
+//            fmt.Print(cell.Value, "\t")
+//        }
+//        fmt.Println()
+//    }
+//    if err = sr.Err(); err != nil {
+//        fmt.Println(err)
+//    }
+//    if err = sr.Close(); err != nil {
+//        fmt.Println(err)
+//    }
+//
+func (f *File) StreamRows(sheet string) (*StreamReader, error) {
+	name, ok := f.sheetMap[trimSheetName(sheet)]
+	if !ok {
+		return nil, ErrSheetNotExist{sheet}
+	}
+	f.flushWorksheet(name)
+	sr := &StreamReader{f: f, sst: f.sharedStringsReader()}
+	var err error
+	_, sr.decoder, sr.tempFile, err = f.sheetDecoder(name)
+	return sr, err
+}
+
+// Next will decode the next row of the worksheet and return true if found.
+func (sr *StreamReader) Next() bool {
+	if sr.err != nil {
+		return false
+	}
+	for {
+		token, err := sr.decoder.Token()
+		if err != nil {
+			if err != io.EOF {
+				sr.err = err
+			}
+			return false
+		}
+		switch xmlElement := token.(type) {
+		case xml.StartElement:
+			if xmlElement.Name.Local != "row" {
+				continue
+			}
+			var row xlsxRow
+			if sr.err = sr.decoder.DecodeElement(&row, &xmlElement); sr.err != nil {
+				return false
+			}
+			sr.curRow++
+			if row.R != 0 {
+				sr.curRow = row.R
+			}
+			sr.err = sr.decodeCells(row.C)
+			return sr.err == nil
+		case xml.EndElement:
+			if xmlElement.Name.Local == "sheetData" {
+				return false
+			}
+		}
+	}
+}
+
+// decodeCells converts the given cells of the current row to Cell, the gaps
+// between the cells are filled with empty Cell with nil value.
+func (sr *StreamReader) decodeCells(cells []xlsxC) error {
+	sr.row = sr.row[:0]
+	for _, c := range cells {
+		col := len(sr.row) + 1
+		if c.R != "" {
+			var err error
+			if col, _, err = CellNameToCoordinates(c.R); err != nil {
+				return err
+			}
+		}
+		for len(sr.row) < col-1 {
+			sr.row = append(sr.row, Cell{})
+		}
+		val, err := c.getValueFrom(sr.f, sr.sst, false)
+		if err != nil {
+			return err
+		}
+		cell := Cell{StyleID: c.S, Value: val}
+		if c.F != nil {
+			cell.Formula = c.F.Content
+			if cell.CachedValue, err = c.getValueFrom(sr.f, sr.sst, true); err != nil {
+				return err
+			}
+		}
+		sr.row = append(sr.row, cell)
+	}
+	return nil
+}
+
+// CurrentRow returns the row number of the current row.
+func (sr *StreamReader) CurrentRow() int {
+	return sr.curRow
+}
+
+// Row returns the cells of the current row. The returned slice is only valid
+// until the next call of Next.
+func (sr *StreamReader) Row() []Cell {
+	return sr.row
+}
+
+// Err returns the error occurred while reading the rows, if any.
+func (sr *StreamReader) Err() error {
+	return sr.err
+}
+
+// Close closes the open worksheet XML file in the system temporary
+// directory.
+func (sr *StreamReader) Close() error {
+	if sr.tempFile != nil {
+		return sr.tempFile.Close()
+	}
+	return nil
+}
+
 // bulkAppendFields bulk-appends fields in a worksheet by specified field
 // names order range.
 func bulkAppendFields(w io.Writer, ws *xlsxWorksheet, from, to int) {
@@ -539,7 +1085,8 @@ func bulkAppendFields(w io.Writer, ws *xlsxWorksheet, from, to int) {
 	enc := xml.NewEncoder(w)
 	for i := 0; i < s.NumField(); i++ {
 		if from <= i && i <= to {
-			_ = enc.Encode(s.Field(i).Interface())
+			name := strings.Split(s.Type().Field(i).Tag.Get("xml"), ",")[0]
+			_ = enc.EncodeElement(s.Field(i).Interface(), xml.StartElement{Name: xml.Name{Local: name}})
 		}
 	}
 }
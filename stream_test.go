@@ -1,9 +1,11 @@
 package excelize
 
 import (
+	"bytes"
 	"encoding/xml"
 	"fmt"
 	"io/ioutil"
+	"math"
 	"math/rand"
 	"os"
 	"path/filepath"
@@ -144,6 +146,64 @@ func TestStreamSetColWidth(t *testing.T) {
 	assert.EqualError(t, streamWriter.SetColWidth(2, 3, 20), ErrStreamSetColWidth.Error())
 }
 
+func TestStreamSetColStyle(t *testing.T) {
+	file := NewFile()
+	styleID, err := file.NewStyle(`{"font":{"bold":true}}`)
+	assert.NoError(t, err)
+	cellStyleID, err := file.NewStyle(`{"font":{"italic":true}}`)
+	assert.NoError(t, err)
+	streamWriter, err := file.NewStreamWriter("Sheet1")
+	assert.NoError(t, err)
+	assert.NoError(t, streamWriter.SetColStyle(3, 2, styleID))
+	assert.EqualError(t, streamWriter.SetColStyle(0, 3, styleID), ErrColumnNumber.Error())
+	assert.EqualError(t, streamWriter.SetColStyle(TotalColumns+1, 3, styleID), ErrColumnNumber.Error())
+	assert.EqualError(t, streamWriter.SetColStyle(1, 3, -1), newInvalidStyleID(-1).Error())
+	assert.NoError(t, streamWriter.SetRow("A1", []interface{}{"A", "B", Cell{StyleID: cellStyleID, Value: "C"}, "D"}))
+	assert.NoError(t, streamWriter.SetRow("B2", []interface{}{1, &Cell{Value: 2}}))
+	assert.EqualError(t, streamWriter.SetColStyle(2, 3, styleID), ErrStreamSetColStyle.Error())
+	assert.NoError(t, streamWriter.Flush())
+
+	var buf bytes.Buffer
+	assert.NoError(t, file.Write(&buf))
+	sheet := readZipEntry(t, buf.Bytes(), "xl/worksheets/sheet1.xml")
+	assert.Contains(t, sheet, fmt.Sprintf(`<cols><col min="2" max="3" width="9.140625" style="%d"/></cols>`, styleID))
+
+	// Test the cells without style ID get the style of the column.
+	f, err := OpenReader(&buf)
+	assert.NoError(t, err)
+	for cell, expected := range map[string]int{"A1": 0, "B1": styleID, "C1": cellStyleID, "D1": 0, "B2": styleID, "C2": styleID} {
+		style, err := f.GetCellStyle("Sheet1", cell)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, style, cell)
+	}
+
+	// Test merge the overlapping columns of the width and the style.
+	file = NewFile()
+	streamWriter, err = file.NewStreamWriter("Sheet1")
+	assert.NoError(t, err)
+	assert.NoError(t, streamWriter.SetColWidth(2, 5, 20))
+	assert.NoError(t, streamWriter.SetColStyle(4, 7, styleID))
+	assert.NoError(t, streamWriter.SetColStyle(6, 6, cellStyleID))
+	assert.NoError(t, streamWriter.SetColWidth(9, 9, 30))
+	assert.NoError(t, streamWriter.Flush())
+	buf.Reset()
+	assert.NoError(t, file.Write(&buf))
+	sheet = readZipEntry(t, buf.Bytes(), "xl/worksheets/sheet1.xml")
+	assert.Contains(t, sheet, fmt.Sprintf(`<cols>`+
+		`<col min="2" max="3" width="20.000000" customWidth="1"/>`+
+		`<col min="4" max="5" width="20.000000" customWidth="1" style="%d"/>`+
+		`<col min="6" max="6" width="9.140625" style="%d"/>`+
+		`<col min="7" max="7" width="9.140625" style="%d"/>`+
+		`<col min="9" max="9" width="30.000000" customWidth="1"/></cols>`, styleID, cellStyleID, styleID))
+	f, err = OpenReader(&buf)
+	assert.NoError(t, err)
+	for col, expected := range map[string]float64{"B": 20, "E": 20, "F": defaultColWidth, "I": 30} {
+		width, err := f.GetColWidth("Sheet1", col)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, width, col)
+	}
+}
+
 func TestStreamTable(t *testing.T) {
 	file := NewFile()
 	streamWriter, err := file.NewStreamWriter("Sheet1")
@@ -178,6 +238,42 @@ func TestStreamTable(t *testing.T) {
 	assert.EqualError(t, streamWriter.AddTable("A1", "B", `{}`), `cannot convert cell "B" to coordinates: invalid cell name "B"`)
 }
 
+func TestStreamAddComment(t *testing.T) {
+	file := NewFile()
+	assert.NoError(t, file.AddComment("Sheet1", "A1", `{"author":"Excelize: ","text":"Sheet1 comment."}`))
+	file.NewSheet("Sheet2")
+	streamWriter, err := file.NewStreamWriter("Sheet2")
+	assert.NoError(t, err)
+	assert.NoError(t, streamWriter.SetRow("A1", []interface{}{"A", "B"}))
+	// comments may be added to the rows already written
+	assert.NoError(t, streamWriter.AddComment("B1", Comment{Author: "Excelize: ", Text: "This is a comment."}))
+	assert.NoError(t, streamWriter.AddComment("C3", Comment{}))
+	assert.EqualError(t, streamWriter.AddComment("A", Comment{}), `cannot convert cell "A" to coordinates: invalid cell name "A"`)
+	assert.NoError(t, streamWriter.SetRow("A2", []interface{}{"C"}))
+	assert.NoError(t, streamWriter.Flush())
+
+	var buf bytes.Buffer
+	assert.NoError(t, file.Write(&buf))
+	sheet := readZipEntry(t, buf.Bytes(), "xl/worksheets/sheet2.xml")
+	assert.Contains(t, sheet, `relationships:id="rId1"></legacyDrawing></worksheet>`)
+	rels := readZipEntry(t, buf.Bytes(), "xl/worksheets/_rels/sheet2.xml.rels")
+	assert.Contains(t, rels, `Target="../drawings/vmlDrawing2.vml"`)
+	assert.Contains(t, rels, `Target="../comments2.xml"`)
+
+	f, err := OpenReader(&buf)
+	assert.NoError(t, err)
+	comments := f.GetComments()
+	assert.Equal(t, []Comment{{Author: "Excelize: ", Ref: "A1", Text: "Excelize: Sheet1 comment."}}, comments["Sheet1"])
+	assert.Equal(t, []Comment{
+		{Author: "Excelize: ", Ref: "B1", Text: "Excelize: This is a comment."},
+		{Author: "Author:", AuthorID: 1, Ref: "C3", Text: "Author: "},
+	}, comments["Sheet2"])
+	rows, err := f.GetRows("Sheet2")
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{{"A", "B"}, {"C"}}, rows)
+	assert.NoError(t, f.Close())
+}
+
 func TestStreamMergeCells(t *testing.T) {
 	file := NewFile()
 	streamWriter, err := file.NewStreamWriter("Sheet1")
@@ -190,6 +286,312 @@ func TestStreamMergeCells(t *testing.T) {
 	assert.NoError(t, file.SaveAs(filepath.Join("test", "TestStreamMergeCells.xlsx")))
 }
 
+func TestStreamPanesAutoFilter(t *testing.T) {
+	file := NewFile()
+	streamWriter, err := file.NewStreamWriter("Sheet1")
+	assert.NoError(t, err)
+	assert.NoError(t, streamWriter.SetColWidth(1, 6, 12))
+	assert.NoError(t, streamWriter.SetPanes(`{"freeze":true,"split":false,"x_split":0,"y_split":1,"top_left_cell":"A2","active_pane":"bottomLeft"}`))
+	assert.NoError(t, streamWriter.SetRow("A1", []interface{}{"A", "B", "C", "D", "E", "F"}))
+	assert.EqualError(t, streamWriter.SetPanes(`{"freeze":false,"split":false}`), ErrStreamSetPanes.Error())
+	for r := 2; r <= 10; r++ {
+		assert.NoError(t, streamWriter.SetRow(fmt.Sprintf("A%d", r), []interface{}{r, r, r, r, r, r}))
+	}
+	assert.NoError(t, streamWriter.AutoFilter("A1", "F1", ""))
+	assert.EqualError(t, streamWriter.AutoFilter("A", "F1", ""), `cannot convert cell "A" to coordinates: invalid cell name "A"`)
+	assert.NoError(t, streamWriter.MergeCell("A12", "F12"))
+	assert.NoError(t, streamWriter.Flush())
+
+	var buf bytes.Buffer
+	assert.NoError(t, file.Write(&buf))
+	sheet := readZipEntry(t, buf.Bytes(), "xl/worksheets/sheet1.xml")
+	// Test the elements are written in schema order.
+	var last int
+	for _, elem := range []string{"<sheetViews>", "<cols>", "<sheetData>", "<autoFilter ", "<mergeCells "} {
+		idx := strings.Index(sheet, elem)
+		assert.Greater(t, idx, last, elem)
+		last = idx
+	}
+
+	f, err := OpenReader(&buf)
+	assert.NoError(t, err)
+	ws, err := f.workSheetReader("Sheet1")
+	assert.NoError(t, err)
+	pane := ws.SheetViews.SheetView[0].Pane
+	if assert.NotNil(t, pane) {
+		assert.Equal(t, "frozen", pane.State)
+		assert.Equal(t, "A2", pane.TopLeftCell)
+		assert.Equal(t, float64(1), pane.YSplit)
+	}
+	if assert.NotNil(t, ws.AutoFilter) {
+		assert.Equal(t, "$A$1:$F$1", ws.AutoFilter.Ref)
+	}
+	width, err := f.GetColWidth("Sheet1", "F")
+	assert.NoError(t, err)
+	assert.Equal(t, float64(12), width)
+}
+
+func TestStreamFlushColWidth(t *testing.T) {
+	file := NewFile()
+	streamWriter, err := file.NewStreamWriter("Sheet1")
+	assert.NoError(t, err)
+	assert.NoError(t, streamWriter.SetColWidth(2, 3, 20))
+	assert.NoError(t, streamWriter.Flush())
+	var buf bytes.Buffer
+	assert.NoError(t, file.Write(&buf))
+	f, err := OpenReader(&buf)
+	assert.NoError(t, err)
+	width, err := f.GetColWidth("Sheet1", "C")
+	assert.NoError(t, err)
+	assert.Equal(t, float64(20), width)
+}
+
+func TestStreamRichText(t *testing.T) {
+	file := NewFile()
+	streamWriter, err := file.NewStreamWriter("Sheet1")
+	assert.NoError(t, err)
+	runs := []RichTextRun{
+		{Text: "bold ", Font: &Font{Bold: true, Color: "2354e8", Family: "Times New Roman", Size: 12}},
+		{Text: "& plain"},
+	}
+	assert.NoError(t, streamWriter.SetRow("A1", []interface{}{runs, Cell{StyleID: 1, Value: runs[1:]}}))
+	// Test set rich text with exceeds the maximum number of characters.
+	assert.EqualError(t, streamWriter.SetRow("A2", []interface{}{[]RichTextRun{{Text: strings.Repeat("s", TotalCellChars+1)}}}), ErrCellCharsLength.Error())
+	assert.NoError(t, streamWriter.Flush())
+
+	var buf bytes.Buffer
+	assert.NoError(t, file.Write(&buf))
+	assert.Contains(t, readZipEntry(t, buf.Bytes(), "xl/worksheets/sheet1.xml"), `<c r="A1" t="inlineStr"><is><r><rPr><rFont val="Times New Roman"></rFont><b></b>`)
+	f, err := OpenReader(&buf)
+	assert.NoError(t, err)
+	richText, err := f.GetCellRichText("Sheet1", "A1")
+	assert.NoError(t, err)
+	assert.Equal(t, []RichTextRun{
+		{Text: "bold ", Font: &Font{Bold: true, Color: "2354E8", Family: "Times New Roman", Size: 12, Underline: "none"}},
+		{Text: "& plain"},
+	}, richText)
+	richText, err = f.GetCellRichText("Sheet1", "B1")
+	assert.NoError(t, err)
+	assert.Equal(t, []RichTextRun{{Text: "& plain"}}, richText)
+	val, err := f.GetCellValue("Sheet1", "A1")
+	assert.NoError(t, err)
+	assert.Equal(t, "bold & plain", val)
+}
+
+func TestStreamNumFmt(t *testing.T) {
+	file := NewFile()
+	streamWriter, err := file.NewStreamWriter("Sheet1")
+	assert.NoError(t, err)
+	for r := 1; r <= 3; r++ {
+		assert.NoError(t, streamWriter.SetRow(fmt.Sprintf("A%d", r), []interface{}{Cell{Value: 0.5, NumFmt: "0.00%"}, &Cell{Value: 1, NumFmt: "0.00%", StyleID: 1}}))
+	}
+	assert.NoError(t, streamWriter.Flush())
+	styleID, err := file.numFmtStyleID("0.00%")
+	assert.NoError(t, err)
+	for _, cell := range []string{"A1", "B1", "A3", "B3"} {
+		style, err := file.GetCellStyle("Sheet1", cell)
+		assert.NoError(t, err)
+		assert.Equal(t, styleID, style)
+	}
+}
+
+func TestStreamCellHyperlink(t *testing.T) {
+	file := NewFile()
+	streamWriter, err := file.NewStreamWriter("Sheet1")
+	assert.NoError(t, err)
+	assert.NoError(t, streamWriter.SetRow("A1", []interface{}{
+		Cell{Hyperlink: &CellHyperlink{Link: "https://github.com/xuri/excelize", Display: "Excelize", Tooltip: "Excelize on GitHub"}},
+		&Cell{Value: "Go to A40", Hyperlink: &CellHyperlink{Link: "Sheet1!A40", LinkType: "Location"}},
+	}))
+	assert.EqualError(t, streamWriter.SetRow("A2", []interface{}{Cell{Hyperlink: &CellHyperlink{Link: "Sheet1!A40", LinkType: "None"}}}), `invalid link type "None"`)
+	assert.NoError(t, streamWriter.Flush())
+	assert.NoError(t, file.SaveAs(filepath.Join("test", "TestStreamCellHyperlink.xlsx")))
+
+	file, err = OpenFile(filepath.Join("test", "TestStreamCellHyperlink.xlsx"))
+	assert.NoError(t, err)
+	for cell, expected := range map[string][2]string{"A1": {"Excelize", "https://github.com/xuri/excelize"}, "B1": {"Go to A40", "Sheet1!A40"}} {
+		val, err := file.GetCellValue("Sheet1", cell)
+		assert.NoError(t, err)
+		assert.Equal(t, expected[0], val)
+		ok, target, err := file.GetCellHyperLink("Sheet1", cell)
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, expected[1], target)
+	}
+	ws, err := file.workSheetReader("Sheet1")
+	assert.NoError(t, err)
+	assert.Equal(t, "Excelize on GitHub", ws.Hyperlinks.Hyperlink[0].Tooltip)
+}
+
+func TestStreamSharedFormula(t *testing.T) {
+	file := NewFile()
+	streamWriter, err := file.NewStreamWriter("Sheet1")
+	assert.NoError(t, err)
+	formulaType, ref := STCellFormulaTypeShared, "C1:C5"
+	assert.NoError(t, streamWriter.SetRow("A1", []interface{}{1, 2, Cell{Formula: "A1+B1", FormulaOpts: &FormulaOpts{Type: &formulaType, Ref: &ref}}}))
+	for r := 2; r <= 5; r++ {
+		assert.NoError(t, streamWriter.SetRow(fmt.Sprintf("A%d", r), []interface{}{r, r * 2, nil}))
+	}
+	// Test set shared formula without reference range.
+	assert.EqualError(t, streamWriter.SetRow("A6", []interface{}{Cell{Formula: "A1", FormulaOpts: &FormulaOpts{Type: &formulaType}}}), ErrParameterRequired.Error())
+	// Test set shared formula on the cell which isn't the master cell of the range.
+	assert.EqualError(t, streamWriter.SetRow("A7", []interface{}{Cell{Formula: "A1", FormulaOpts: &FormulaOpts{Type: &formulaType, Ref: &ref}}}), ErrParameterInvalid.Error())
+	// Test set shared formula with illegal reference range.
+	invalidRef := "A:C5"
+	assert.EqualError(t, streamWriter.SetRow("A8", []interface{}{Cell{Formula: "A1", FormulaOpts: &FormulaOpts{Type: &formulaType, Ref: &invalidRef}}}), `cannot convert cell "A" to coordinates: invalid cell name "A"`)
+	assert.NoError(t, streamWriter.Flush())
+	assert.NoError(t, file.SaveAs(filepath.Join("test", "TestStreamSharedFormula.xlsx")))
+
+	file, err = OpenFile(filepath.Join("test", "TestStreamSharedFormula.xlsx"))
+	assert.NoError(t, err)
+	for r := 1; r <= 5; r++ {
+		formula, err := file.GetCellFormula("Sheet1", fmt.Sprintf("C%d", r))
+		assert.NoError(t, err)
+		assert.Equal(t, fmt.Sprintf("A%d+B%d", r, r), formula)
+	}
+	result, err := file.CalcCellValue("Sheet1", "C3")
+	assert.NoError(t, err)
+	assert.Equal(t, "9", result)
+
+	// Test the cells with a value in the range of a shared formula, and the
+	// escaped attributes of an array formula.
+	file = NewFile()
+	streamWriter, err = file.NewStreamWriter("Sheet1")
+	assert.NoError(t, err)
+	arrayType, arrayRef := STCellFormulaTypeArray, `A1:A2"`
+	assert.NoError(t, streamWriter.SetRow("A1", []interface{}{1, 2, Cell{Formula: "A1+B1", FormulaOpts: &FormulaOpts{Type: &formulaType, Ref: &ref}}, Cell{Formula: "A1:A2", FormulaOpts: &FormulaOpts{Type: &arrayType, Ref: &arrayRef}}}))
+	assert.NoError(t, streamWriter.SetRow("A2", []interface{}{3, 4, 10}))
+	assert.NoError(t, streamWriter.SetRow("A3", []interface{}{5, 6, Cell{StyleID: 0}}))
+	assert.NoError(t, streamWriter.Flush())
+	var buf bytes.Buffer
+	assert.NoError(t, file.Write(&buf))
+	sheet := readZipEntry(t, buf.Bytes(), "xl/worksheets/sheet1.xml")
+	assert.Contains(t, sheet, `<f t="array" ref="A1:A2&#34;">A1:A2</f>`)
+	file, err = OpenReader(&buf)
+	assert.NoError(t, err)
+	for cell, expected := range map[string]string{"C2": "", "C3": "A3+B3"} {
+		formula, err := file.GetCellFormula("Sheet1", cell)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, formula, cell)
+	}
+	value, err := file.GetCellValue("Sheet1", "C2")
+	assert.NoError(t, err)
+	assert.Equal(t, "10", value)
+}
+
+func BenchmarkStreamRows(b *testing.B) {
+	f, _ := OpenFile(filepath.Join("test", "Book1.xlsx"))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		sr, _ := f.StreamRows("Sheet2")
+		for sr.Next() {
+			_ = sr.Row()
+		}
+		if err := sr.Close(); err != nil {
+			b.Error(err)
+		}
+	}
+	if err := f.Close(); err != nil {
+		b.Error(err)
+	}
+}
+
+func BenchmarkGetRows(b *testing.B) {
+	f, _ := OpenFile(filepath.Join("test", "Book1.xlsx"))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := f.GetRows("Sheet2"); err != nil {
+			b.Error(err)
+		}
+	}
+	if err := f.Close(); err != nil {
+		b.Error(err)
+	}
+}
+
+func TestStreamRows(t *testing.T) {
+	f, err := OpenFile(filepath.Join("test", "Book1.xlsx"))
+	assert.NoError(t, err)
+	expected, err := f.GetRows("Sheet2")
+	assert.NoError(t, err)
+	sr, err := f.StreamRows("Sheet2")
+	assert.NoError(t, err)
+	var rowNum int
+	for sr.Next() {
+		for rowNum++; rowNum < sr.CurrentRow(); rowNum++ {
+			assert.Empty(t, trimSliceSpace(expected[rowNum-1]))
+		}
+		var values []string
+		for _, cell := range sr.Row() {
+			value, _ := cell.Value.(string)
+			values = append(values, value)
+		}
+		assert.Equal(t, trimSliceSpace(expected[rowNum-1]), trimSliceSpace(values))
+	}
+	assert.NoError(t, sr.Err())
+	assert.NoError(t, sr.Close())
+
+	// Test stream rows with formula and the cells without reference.
+	file := NewFile()
+	dw, err := file.NewDirectWriter("Sheet2", 8192)
+	assert.NoError(t, err)
+	_, err = dw.AddRow([]Cell{{Value: 1}, {Value: "foo", StyleID: 1}, {Formula: "A1*2"}})
+	assert.NoError(t, err)
+	assert.NoError(t, dw.Close())
+	var buf bytes.Buffer
+	_, err = file.WriteTo(&buf)
+	assert.NoError(t, err)
+	f, err = OpenReader(&buf)
+	assert.NoError(t, err)
+	sr, err = f.StreamRows("Sheet2")
+	assert.NoError(t, err)
+	assert.True(t, sr.Next())
+	assert.Equal(t, 1, sr.CurrentRow())
+	assert.Equal(t, []Cell{{Value: "1"}, {Value: "foo", StyleID: 1}, {Value: "", Formula: "A1*2"}}, sr.Row())
+	assert.False(t, sr.Next())
+	assert.NoError(t, sr.Err())
+	assert.NoError(t, sr.Close())
+
+	// Test stream rows with the cached result of the formula.
+	file = NewFile()
+	percent, err := file.NewStyle(&Style{NumFmt: 9})
+	assert.NoError(t, err)
+	dw, err = file.NewDirectWriter("Sheet1", 8192)
+	assert.NoError(t, err)
+	_, err = dw.AddRow([]Cell{{Value: 1}, {Formula: "A1/4", Value: 0.25, StyleID: percent}, {Formula: `"x"&A1`, Value: "x1"}})
+	assert.NoError(t, err)
+	assert.NoError(t, dw.Close())
+	buf.Reset()
+	_, err = file.WriteTo(&buf)
+	assert.NoError(t, err)
+	f, err = OpenReader(&buf)
+	assert.NoError(t, err)
+	sr, err = f.StreamRows("Sheet1")
+	assert.NoError(t, err)
+	assert.True(t, sr.Next())
+	row := sr.Row()
+	assert.Equal(t, Cell{Value: "1"}, row[0])
+	assert.Equal(t, "A1/4", row[1].Formula)
+	assert.Equal(t, "25%", row[1].Value)
+	assert.Equal(t, "0.25", row[1].CachedValue)
+	assert.Equal(t, Cell{Formula: `"x"&A1`, Value: "x1", CachedValue: "x1"}, row[2])
+	assert.NoError(t, sr.Close())
+
+	// Test stream rows on not exists worksheet.
+	_, err = f.StreamRows("SheetN")
+	assert.EqualError(t, err, "sheet SheetN is not exist")
+
+	// Test stream rows with invalid cell reference.
+	f = NewFile()
+	f.Sheet.Delete("xl/worksheets/sheet1.xml")
+	f.Pkg.Store("xl/worksheets/sheet1.xml", []byte(`<worksheet><sheetData><row r="1"><c r="A"><v>1</v></c></row></sheetData></worksheet>`))
+	f.checked = nil
+	sr, err = f.StreamRows("Sheet1")
+	assert.NoError(t, err)
+	assert.False(t, sr.Next())
+	assert.EqualError(t, sr.Err(), `cannot convert cell "A" to coordinates: invalid cell name "A"`)
+}
+
 func TestNewStreamWriter(t *testing.T) {
 	// Test error exceptions
 	file := NewFile()
@@ -229,3 +631,47 @@ func TestSetCellValFunc(t *testing.T) {
 	assert.NoError(t, setCellValFunc(c, nil))
 	assert.NoError(t, setCellValFunc(c, complex64(5+10i)))
 }
+
+func TestSetCellValFuncNonFinite(t *testing.T) {
+	for _, val := range []interface{}{math.NaN(), math.Inf(1), math.Inf(-1), float32(math.Inf(1)), float32(math.NaN())} {
+		c := xlsxC{}
+		assert.EqualError(t, setCellValFunc(&c, val), ErrNonFiniteNumber.Error(), val)
+		assert.Equal(t, xlsxC{T: "e", V: "#NUM!"}, c, val)
+	}
+	for _, opts := range []Options{{}, {RejectNonFinite: true}} {
+		file := NewFile(opts)
+		streamWriter, err := file.NewStreamWriter("Sheet1")
+		assert.NoError(t, err)
+		err = streamWriter.SetRow("A1", []interface{}{1.5, math.NaN(), math.Inf(1), math.Inf(-1)})
+		if opts.RejectNonFinite {
+			assert.EqualError(t, err, ErrNonFiniteNumber.Error())
+			continue
+		}
+		assert.NoError(t, err)
+		assert.NoError(t, streamWriter.Flush())
+		rows, err := file.GetRows("Sheet1")
+		assert.NoError(t, err)
+		assert.Equal(t, [][]string{{"1.5", "#NUM!", "#NUM!", "#NUM!"}}, rows)
+	}
+}
+
+func TestSetCellValFuncDuration(t *testing.T) {
+	for _, c := range []struct {
+		val      time.Duration
+		expected string
+	}{
+		{0, "0"},
+		{time.Second, "0.000011574074074074073"},
+		{time.Minute, "0.0006944444444444445"},
+		{90 * time.Minute, "0.0625"},
+		{12 * time.Hour, "0.5"},
+		{24 * time.Hour, "1"},
+		{36 * time.Hour, "1.5"},
+		{-6 * time.Hour, "-0.25"},
+	} {
+		cell := &xlsxC{}
+		assert.NoError(t, setCellValFunc(cell, c.val))
+		assert.Equal(t, "", cell.T, c.val)
+		assert.Equal(t, c.expected, cell.V, c.val)
+	}
+}
@@ -0,0 +1,219 @@
+// Copyright 2016 - 2021 The excelize Authors. All rights reserved. Use of
+// this source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+//
+// Package excelize providing a set of functions that allow you to write to
+// and read from XLSX / XLSM / XLTM files. Supports reading and writing
+// spreadsheet documents generated by Microsoft Excel™ 2007 and later. Supports
+// complex components by high compatibility, and provided streaming API for
+// generating or reading data from a worksheet with huge amounts of data. This
+// library needs Go version 1.15 or later.
+
+package excelize
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// structRowField directly maps a field of a struct type to a cell of the row
+// returned by StructToRow.
+type structRowField struct {
+	index  []int
+	col    int
+	numFmt string
+}
+
+// structRowType defined the cached fields and the number of columns of a
+// struct type for a tag name.
+type structRowType struct {
+	fields []structRowField
+	cols   int
+}
+
+// structRowKey is the key of the cache of the struct types.
+type structRowKey struct {
+	typ reflect.Type
+	tag string
+}
+
+// structRowTypes is the cache of the fields of the struct types converted by
+// StructToRow, so that the struct tags are parsed once per type.
+var structRowTypes sync.Map
+
+var (
+	timeType = reflect.TypeOf(time.Time{})
+	cellType = reflect.TypeOf(Cell{})
+)
+
+// StructToRow provides a function to convert the exported fields of the given
+// struct, or pointer to struct, to a row of cells which can be added by
+// DirectWriter.AddRow or StreamWriter.SetRow. The fields are mapped to the
+// columns in order, unless a column is given by the struct tag of the given
+// tag name, which is "excel" by default. The tag has the form
+// "col,fmt=code", where col is the column name of the field, such as "C",
+// or empty for the column after the previous field, and the optional number
+// format code is set as the Cell.NumFmt of the cell. Since the format code
+// may contain commas, the fmt option must be the last one. A field with the
+// tag "-" is skipped. The fields of nested structs are flattened from the
+// column of the nested struct field, except for the time.Time fields, which
+// are written as dates, and the Cell fields, which are written as is. A nil
+// pointer is written as an empty cell. For example, export a slice of
+// structs:
+//
+//    type Order struct {
+//        ID      int       `excel:"A"`
+//        Note    string    `excel:"-"`
+//        Created time.Time `excel:"B,fmt=yyyy-mm-dd hh:mm"`
+//        Amount  float64   `excel:"D,fmt=#,##0.00"`
+//    }
+//    for _, order := range orders {
+//        row, err := excelize.StructToRow(order, "")
+//        if err != nil {
+//            fmt.Println(err)
+//        }
+//        if _, err := dw.AddRow(row); err != nil {
+//            fmt.Println(err)
+//        }
+//    }
+//
+func StructToRow(v interface{}, tagName string) ([]Cell, error) {
+	val := reflect.ValueOf(v)
+	for val.Kind() == reflect.Ptr && !val.IsNil() {
+		val = val.Elem()
+	}
+	if val.Kind() != reflect.Struct {
+		return nil, ErrParameterInvalid
+	}
+	if tagName == "" {
+		tagName = "excel"
+	}
+	st, err := getStructRowType(val.Type(), tagName)
+	if err != nil {
+		return nil, err
+	}
+	row := make([]Cell, st.cols)
+	for _, field := range st.fields {
+		fv, ok := structFieldValue(val, field.index)
+		if !ok {
+			continue
+		}
+		cell := &row[field.col-1]
+		if fv.Type() == cellType {
+			*cell = fv.Interface().(Cell)
+		} else {
+			cell.Value = fv.Interface()
+		}
+		if cell.NumFmt == "" {
+			cell.NumFmt = field.numFmt
+		}
+	}
+	return row, nil
+}
+
+// getStructRowType returns the cached fields of the given struct type for
+// the given tag name, which are parsed on first use.
+func getStructRowType(typ reflect.Type, tagName string) (*structRowType, error) {
+	key := structRowKey{typ: typ, tag: tagName}
+	if st, ok := structRowTypes.Load(key); ok {
+		return st.(*structRowType), nil
+	}
+	st := &structRowType{}
+	if _, err := st.parse(typ, tagName, nil, 1, map[int]bool{}, map[reflect.Type]bool{}); err != nil {
+		return nil, err
+	}
+	structRowTypes.Store(key, st)
+	return st, nil
+}
+
+// parse appends the fields of the given struct type from the given column,
+// and returns the column after the last field. The recursive struct types
+// can't be flattened and return ErrParameterInvalid.
+func (st *structRowType) parse(typ reflect.Type, tagName string, index []int, next int, cols map[int]bool, nested map[reflect.Type]bool) (int, error) {
+	if nested[typ] {
+		return next, ErrParameterInvalid
+	}
+	nested[typ] = true
+	defer delete(nested, typ)
+	for i := 0; i < typ.NumField(); i++ {
+		sf := typ.Field(i)
+		if sf.PkgPath != "" {
+			continue
+		}
+		tag := sf.Tag.Get(tagName)
+		if tag == "-" {
+			continue
+		}
+		col, numFmt, err := parseStructRowTag(tag)
+		if err != nil {
+			return next, err
+		}
+		if col > 0 {
+			next = col
+		}
+		fieldIndex := append(append([]int{}, index...), i)
+		ft := sf.Type
+		for ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if ft.Kind() == reflect.Struct && ft != timeType && ft != cellType {
+			if next, err = st.parse(ft, tagName, fieldIndex, next, cols, nested); err != nil {
+				return next, err
+			}
+			continue
+		}
+		if next > TotalColumns {
+			return next, ErrColumnNumber
+		}
+		if cols[next] {
+			return next, ErrParameterInvalid
+		}
+		cols[next] = true
+		st.fields = append(st.fields, structRowField{index: fieldIndex, col: next, numFmt: numFmt})
+		if next > st.cols {
+			st.cols = next
+		}
+		next++
+	}
+	return next, nil
+}
+
+// parseStructRowTag parses the column number and the number format code of
+// the given struct tag.
+func parseStructRowTag(tag string) (col int, numFmt string, err error) {
+	name := tag
+	if i := strings.IndexByte(tag, ','); i >= 0 {
+		name, tag = tag[:i], tag[i+1:]
+		if !strings.HasPrefix(tag, "fmt=") {
+			return 0, "", ErrParameterInvalid
+		}
+		numFmt = strings.TrimPrefix(tag, "fmt=")
+	}
+	if name != "" {
+		col, err = ColumnNameToNumber(name)
+	}
+	return col, numFmt, err
+}
+
+// structFieldValue returns the value of the field of the given struct value
+// by the given index sequence, or false if a pointer on the way is nil.
+func structFieldValue(val reflect.Value, index []int) (reflect.Value, bool) {
+	for _, i := range index {
+		for val.Kind() == reflect.Ptr {
+			if val.IsNil() {
+				return val, false
+			}
+			val = val.Elem()
+		}
+		val = val.Field(i)
+	}
+	for val.Kind() == reflect.Ptr {
+		if val.IsNil() {
+			return val, false
+		}
+		val = val.Elem()
+	}
+	return val, true
+}
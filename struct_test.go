@@ -0,0 +1,121 @@
+package excelize
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type structRowAddress struct {
+	City    string
+	Country string `xlsx:"-"`
+}
+
+type structRowOrder struct {
+	ID       int
+	Note     string    `excel:"-"`
+	Created  time.Time `excel:",fmt=yyyy-mm-dd hh:mm"`
+	Shipped  *time.Time
+	Amount   float64          `excel:"F,fmt=#,##0.00"`
+	Address  structRowAddress `excel:"H"`
+	Billing  *structRowAddress
+	Status   Cell
+	internal string
+}
+
+func TestStructToRow(t *testing.T) {
+	created := time.Date(2021, 12, 31, 8, 30, 0, 0, time.UTC)
+	order := structRowOrder{
+		ID:       1,
+		Note:     "skipped",
+		Created:  created,
+		Amount:   1234.5,
+		Address:  structRowAddress{City: "Oslo", Country: "Norway"},
+		Status:   Cell{StyleID: 1, Value: "open"},
+		internal: "skipped",
+	}
+	row, err := StructToRow(order, "")
+	require.NoError(t, err)
+	assert.Equal(t, []Cell{
+		{Value: 1},
+		{Value: created, NumFmt: "yyyy-mm-dd hh:mm"},
+		{},
+		{},
+		{},
+		{Value: 1234.5, NumFmt: "#,##0.00"},
+		{},
+		{Value: "Oslo"},
+		{Value: "Norway"},
+		{},
+		{},
+		{StyleID: 1, Value: "open"},
+	}, row)
+
+	// the pointers are dereferenced
+	order.Shipped, order.Billing = &created, &structRowAddress{City: "Bergen"}
+	row, err = StructToRow(&order, "")
+	require.NoError(t, err)
+	assert.Equal(t, Cell{Value: created}, row[2])
+	assert.Equal(t, []Cell{{Value: "Bergen"}, {Value: ""}}, row[9:11])
+
+	// the tags of the given tag name are used
+	row, err = StructToRow(structRowAddress{City: "Oslo", Country: "Norway"}, "xlsx")
+	require.NoError(t, err)
+	assert.Equal(t, []Cell{{Value: "Oslo"}}, row)
+
+	// the rows are written by the DirectWriter
+	f := NewFile()
+	dw, err := f.NewDirectWriter("Sheet1", 1<<20)
+	require.NoError(t, err)
+	row, err = StructToRow(order, "")
+	require.NoError(t, err)
+	_, err = dw.AddRow(row)
+	require.NoError(t, err)
+	require.NoError(t, dw.Close())
+	var buf bytes.Buffer
+	_, err = f.WriteTo(&buf)
+	require.NoError(t, err)
+	f, err = OpenReader(&buf)
+	require.NoError(t, err)
+	rows, err := f.GetRows("Sheet1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1", "2021-12-31 08:30", "44561.3541666667", "", "", "1234.5", "", "Oslo", "Norway", "Bergen", "", "open"}, rows[0])
+	require.NoError(t, f.Close())
+
+	for _, v := range []interface{}{nil, 1, (*structRowOrder)(nil), []structRowOrder{}} {
+		_, err = StructToRow(v, "")
+		assert.EqualError(t, err, ErrParameterInvalid.Error())
+	}
+	for _, c := range []struct {
+		v   interface{}
+		err string
+	}{
+		{struct {
+			A int `excel:"A,width=10"`
+		}{}, ErrParameterInvalid.Error()},
+		{struct {
+			A int `excel:"1"`
+		}{}, newInvalidColumnNameError("1").Error()},
+		{struct {
+			A int `excel:"B"`
+			B int `excel:"B"`
+		}{}, ErrParameterInvalid.Error()},
+		{struct {
+			A int `excel:"XFD"`
+			B int
+		}{}, ErrColumnNumber.Error()},
+		{struct {
+			A *structRowNode
+		}{}, ErrParameterInvalid.Error()},
+	} {
+		_, err = StructToRow(c.v, "")
+		assert.EqualError(t, err, c.err)
+	}
+}
+
+type structRowNode struct {
+	Next *structRowNode
+}
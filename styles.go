@@ -1097,11 +1097,44 @@ func (f *File) styleSheetWriter() {
 }
 
 // sharedStringsWriter provides a function to save xl/sharedStrings.xml after
-// serialize structure.
+// serialize structure. An empty shared string table isn't referenced by any
+// cell, for example when the cells are written by direct writers, so it will
+// be removed from the package with its content type and relationship.
 func (f *File) sharedStringsWriter() {
-	if f.SharedStrings != nil {
-		output, _ := xml.Marshal(f.SharedStrings)
-		f.saveFileList("xl/sharedStrings.xml", f.replaceNameSpaceBytes("xl/sharedStrings.xml", output))
+	if f.SharedStrings == nil {
+		return
+	}
+	if len(f.SharedStrings.SI) == 0 {
+		f.deleteSharedStrings()
+		return
+	}
+	output, _ := xml.Marshal(f.SharedStrings)
+	f.saveFileList("xl/sharedStrings.xml", f.replaceNameSpaceBytes("xl/sharedStrings.xml", output))
+}
+
+// deleteSharedStrings provides a function to remove the shared string table
+// with its content type and relationship from the package.
+func (f *File) deleteSharedStrings() {
+	f.SharedStrings = nil
+	f.Pkg.Delete("xl/sharedStrings.xml")
+	content := f.contentTypesReader()
+	content.Lock()
+	for k, v := range content.Overrides {
+		if v.PartName == "/xl/sharedStrings.xml" {
+			content.Overrides = append(content.Overrides[:k], content.Overrides[k+1:]...)
+			break
+		}
+	}
+	content.Unlock()
+	if rels := f.relsReader(f.getWorkbookRelsPath()); rels != nil {
+		rels.Lock()
+		for k, v := range rels.Relationships {
+			if v.Type == SourceRelationshipSharedStrings {
+				rels.Relationships = append(rels.Relationships[:k], rels.Relationships[k+1:]...)
+				break
+			}
+		}
+		rels.Unlock()
 	}
 }
 
@@ -1115,6 +1148,12 @@ func parseFormatStyleSet(style interface{}) (*Style, error) {
 		err = json.Unmarshal([]byte(v), &fs)
 	case *Style:
 		fs = *v
+		// the font defaults are set on the copy of the font, so that the
+		// given style may be shared by concurrent calls
+		if v.Font != nil {
+			font := *v.Font
+			fs.Font = &font
+		}
 	default:
 		err = ErrParameterInvalid
 	}
@@ -1133,7 +1172,10 @@ func parseFormatStyleSet(style interface{}) (*Style, error) {
 }
 
 // NewStyle provides a function to create the style for cells by given JSON or
-// structure pointer. Note that the color field uses RGB color code.
+// structure pointer. Note that the color field uses RGB color code. It is safe
+// for concurrent use by multiple writers, the existing style ID is returned
+// for an identical style, so that concurrent calls creating the same style
+// result in a single style record.
 //
 // The following shows the border styles sorted by excelize index number:
 //
@@ -2118,6 +2160,22 @@ func (f *File) getStyleID(ss *xlsxStyleSheet, style *Style) (styleID int) {
 	return
 }
 
+// numFmtStyleID provides a function to get the style ID of the style with
+// the given custom number format code, which is used by the Cell.NumFmt of
+// the stream writers. The style is created on first use, and the mapping is
+// cached, so it is safe for concurrent use by multiple writers.
+func (f *File) numFmtStyleID(code string) (int, error) {
+	if styleID, ok := f.numFmtStyles.Load(code); ok {
+		return styleID.(int), nil
+	}
+	styleID, err := f.NewStyle(&Style{CustomNumFmt: &code})
+	if err != nil {
+		return styleID, err
+	}
+	f.numFmtStyles.Store(code, styleID)
+	return styleID, err
+}
+
 // NewConditionalStyle provides a function to create style for conditional
 // format by given style format. The parameters are the same as function
 // NewStyle(). Note that the color field uses RGB color code and only support
@@ -2229,12 +2287,12 @@ func (f *File) newFont(style *Style) *xlsxFont {
 // If given number format code is not exist, will return -1.
 func getNumFmtID(styleSheet *xlsxStyleSheet, style *Style) (numFmtID int) {
 	numFmtID = -1
-	if styleSheet.NumFmts == nil {
-		return
-	}
 	if _, ok := builtInNumFmt[style.NumFmt]; ok {
 		return style.NumFmt
 	}
+	if styleSheet.NumFmts == nil {
+		return
+	}
 	if fmtCode, ok := currencyNumFmt[style.NumFmt]; ok {
 		for _, numFmt := range styleSheet.NumFmts.NumFmt {
 			if numFmt.FormatCode == fmtCode {
@@ -5,6 +5,7 @@ import (
 	"math"
 	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -254,6 +255,45 @@ func TestNewStyle(t *testing.T) {
 	assert.Equal(t, [][]string{{"1.23E+00", "1.23E+00"}}, rows)
 }
 
+func TestNewStyleConcurrency(t *testing.T) {
+	f := NewFile()
+	styles := []*Style{
+		{Font: &Font{Bold: true, Color: "#FF0000"}, Fill: Fill{Type: "pattern", Color: []string{"#E0EBF5"}, Pattern: 1}, NumFmt: 4},
+		{NumFmt: 14},
+		{Border: []Border{{Type: "left", Color: "0000FF", Style: 3}}},
+	}
+	styleIDs := make([][]int, 100)
+	numFmtIDs := make([]int, len(styleIDs))
+	var wg sync.WaitGroup
+	for i := range styleIDs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for _, style := range styles {
+				styleID, err := f.NewStyle(style)
+				assert.NoError(t, err)
+				styleIDs[i] = append(styleIDs[i], styleID)
+			}
+			var err error
+			numFmtIDs[i], err = f.numFmtStyleID("0.00%")
+			assert.NoError(t, err)
+		}(i)
+	}
+	wg.Wait()
+	// the identical styles created concurrently result in a single record
+	assert.Equal(t, []int{1, 2, 3}, styleIDs[0])
+	for i := range styleIDs {
+		assert.Equal(t, styleIDs[0], styleIDs[i])
+		assert.Equal(t, numFmtIDs[0], numFmtIDs[i])
+	}
+	assert.Len(t, f.Styles.CellXfs.Xf, 5)
+	assert.Equal(t, 5, f.Styles.CellXfs.Count)
+	assert.Len(t, f.Styles.Fonts.Font, 2)
+	assert.Len(t, f.Styles.Fills.Fill, 3)
+	assert.Len(t, f.Styles.Borders.Border, 2)
+	assert.Len(t, f.Styles.NumFmts.NumFmt, 1)
+}
+
 func TestGetDefaultFont(t *testing.T) {
 	f := NewFile()
 	s := f.GetDefaultFont()
@@ -297,7 +297,16 @@ func (f *File) AutoFilter(sheet, hcell, vcell, format string) error {
 	formatSet, _ := parseAutoFilterSet(format)
 	cellStart, _ := CoordinatesToCellName(hcol, hrow, true)
 	cellEnd, _ := CoordinatesToCellName(vcol, vrow, true)
-	ref, filterDB := cellStart+":"+cellEnd, "_xlnm._FilterDatabase"
+	ref := cellStart + ":" + cellEnd
+	f.setFilterDatabase(sheet, ref)
+	refRange := vcol - hcol
+	return f.autoFilter(sheet, ref, refRange, hcol, formatSet)
+}
+
+// setFilterDatabase provides a function to set the hidden defined name of the
+// auto filter range of the given worksheet.
+func (f *File) setFilterDatabase(sheet, ref string) {
+	filterDB := "_xlnm._FilterDatabase"
 	wb := f.workbookReader()
 	sheetID := f.GetSheetIndex(sheet)
 	filterRange := fmt.Sprintf("%s!%s", sheet, ref)
@@ -324,8 +333,6 @@ func (f *File) AutoFilter(sheet, hcell, vcell, format string) error {
 			wb.DefinedNames.DefinedName = append(wb.DefinedNames.DefinedName, d)
 		}
 	}
-	refRange := vcol - hcol
-	return f.autoFilter(sheet, ref, refRange, hcol, formatSet)
 }
 
 // autoFilter provides a function to extract the tokens from the filter
@@ -356,21 +363,32 @@ func (f *File) autoFilter(sheet, ref string, refRange, col int, formatSet *forma
 		return fmt.Errorf("incorrect index of column '%s'", formatSet.Column)
 	}
 
-	filter.FilterColumn = append(filter.FilterColumn, &xlsxFilterColumn{
-		ColID: offset,
-	})
+	filterColumn, err := f.parseFilterColumn(offset, formatSet.Expression)
+	if err != nil {
+		return err
+	}
+	filter.FilterColumn = append(filter.FilterColumn, filterColumn)
+	ws.AutoFilter = filter
+	return nil
+}
+
+// parseFilterColumn provides a function to parse the filter expression of
+// the column of the given offset in the auto filter range.
+func (f *File) parseFilterColumn(offset int, expression string) (*xlsxFilterColumn, error) {
+	filter := &xlsxAutoFilter{
+		FilterColumn: []*xlsxFilterColumn{{ColID: offset}},
+	}
 	re := regexp.MustCompile(`"(?:[^"]|"")*"|\S+`)
-	token := re.FindAllString(formatSet.Expression, -1)
+	token := re.FindAllString(expression, -1)
 	if len(token) != 3 && len(token) != 7 {
-		return fmt.Errorf("incorrect number of tokens in criteria '%s'", formatSet.Expression)
+		return nil, fmt.Errorf("incorrect number of tokens in criteria '%s'", expression)
 	}
-	expressions, tokens, err := f.parseFilterExpression(formatSet.Expression, token)
+	expressions, tokens, err := f.parseFilterExpression(expression, token)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	f.writeAutoFilter(filter, expressions, tokens)
-	ws.AutoFilter = filter
-	return nil
+	return filter.FilterColumn[0], nil
 }
 
 // writeAutoFilter provides a function to check for single or double custom
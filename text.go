@@ -0,0 +1,235 @@
+// Copyright 2016 - 2021 The excelize Authors. All rights reserved. Use of
+// this source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+//
+// Package excelize providing a set of functions that allow you to write to
+// and read from XLSX / XLSM / XLTM files. Supports reading and writing
+// spreadsheet documents generated by Microsoft Excel™ 2007 and later. Supports
+// complex components by high compatibility, and provided streaming API for
+// generating or reading data from a worksheet with huge amounts of data. This
+// library needs Go version 1.15 or later.
+
+package excelize
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// defaultTextDateFormat defined the default layout of the date values written
+// by the TextWriter.
+const defaultTextDateFormat = "2006-01-02 15:04:05"
+
+// TextWriterOptions defined the options of the TextWriter.
+//
+// Comma specifies the field delimiter, the default is ','. Use '\t' for
+// tab-separated values.
+//
+// QuoteAll specifies if all fields are quoted, otherwise only the fields
+// which contain the delimiter, a double quote, a carriage return or a line
+// feed, or which begin with a space are quoted. The double quotes in quoted
+// fields are doubled.
+//
+// UseCRLF specifies if the records are terminated by \r\n instead of \n.
+//
+// DateFormat specifies the Go layout of the time.Time values, the default is
+// "2006-01-02 15:04:05".
+//
+// Widths specifies the widths in characters of the columns of fixed-width
+// text. If it is set, the fields are padded with spaces or truncated to the
+// width of their column instead of being delimited and quoted, and the rows
+// can't have more values than the columns.
+type TextWriterOptions struct {
+	Comma      rune
+	QuoteAll   bool
+	UseCRLF    bool
+	DateFormat string
+	Widths     []int
+}
+
+// TextWriter is a sibling of the DirectWriter which writes rows of cells as
+// delimited or fixed-width text instead of a worksheet, so that the same rows
+// can be exported to an xlsx, CSV or TSV file by switching the writer. The
+// values are formatted like the cell values of the DirectWriter: numbers in
+// the shortest representation, booleans as TRUE and FALSE, NaN and infinite
+// numbers as #NUM!, and formula cells without value as the formula prefixed
+// with '='. The styles are not applied.
+type TextWriter struct {
+	w       *bufio.Writer
+	opts    TextWriterOptions
+	comma   string
+	newline string
+	record  []string
+	field   []byte
+}
+
+// NewTextWriter provides a function to create a TextWriter which writes to
+// the given writer with the given options. For example, export the rows as
+// tab-separated values:
+//
+//    tw, err := excelize.NewTextWriter(w, excelize.TextWriterOptions{Comma: '\t'})
+//    if err != nil {
+//        fmt.Println(err)
+//    }
+//    if _, err := tw.AddRow([]excelize.Cell{{Value: "Name"}, {Value: 1.5}}); err != nil {
+//        fmt.Println(err)
+//    }
+//    if err := tw.Close(); err != nil {
+//        fmt.Println(err)
+//    }
+//
+func NewTextWriter(w io.Writer, opts TextWriterOptions) (*TextWriter, error) {
+	if opts.Comma == 0 {
+		opts.Comma = ','
+	}
+	if opts.Comma == '"' || opts.Comma == '\r' || opts.Comma == '\n' || !utf8.ValidRune(opts.Comma) {
+		return nil, ErrParameterInvalid
+	}
+	for _, width := range opts.Widths {
+		if width < 1 {
+			return nil, ErrParameterInvalid
+		}
+	}
+	if opts.DateFormat == "" {
+		opts.DateFormat = defaultTextDateFormat
+	}
+	tw := &TextWriter{w: bufio.NewWriter(w), opts: opts, comma: string(opts.Comma), newline: "\n"}
+	if opts.UseCRLF {
+		tw.newline = "\r\n"
+	}
+	return tw, nil
+}
+
+// AddRow writes a row of the given values as a record, the RowOpts are
+// accepted for the compatibility with DirectWriter.AddRow and ignored. It
+// returns the number of bytes currently in the write buffer, which is
+// flushed to the underlying writer when it is full.
+func (tw *TextWriter) AddRow(values []Cell, opts ...RowOpts) (buffered int, err error) {
+	if len(tw.opts.Widths) > 0 && len(values) > len(tw.opts.Widths) {
+		return tw.w.Buffered(), ErrColumnNumber
+	}
+	// the values are formatted before writing, so that the record isn't
+	// written partially on error
+	tw.record = tw.record[:0]
+	for _, val := range values {
+		field, err := tw.value(val)
+		if err != nil {
+			return tw.w.Buffered(), err
+		}
+		tw.record = append(tw.record, field)
+	}
+	for i, field := range tw.record {
+		if len(tw.opts.Widths) > 0 {
+			tw.appendFixedWidth(field, tw.opts.Widths[i])
+			continue
+		}
+		if i > 0 {
+			_, _ = tw.w.WriteString(tw.comma)
+		}
+		tw.appendField(field)
+	}
+	_, err = tw.w.WriteString(tw.newline)
+	return tw.w.Buffered(), err
+}
+
+// value provides a function to format the value of the given cell as text.
+func (tw *TextWriter) value(val Cell) (string, error) {
+	if val.RawValue != nil {
+		return string(val.RawValue), nil
+	}
+	value := val.Hyperlink.value(val)
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case []byte:
+		return string(v), nil
+	case time.Time:
+		return v.Format(tw.opts.DateFormat), nil
+	case bool:
+		if v {
+			return "TRUE", nil
+		}
+		return "FALSE", nil
+	case []RichTextRun:
+		var text strings.Builder
+		for _, run := range v {
+			text.WriteString(run.Text)
+		}
+		return text.String(), nil
+	case nil:
+		if val.Formula != "" {
+			return "=" + val.Formula, nil
+		}
+		return "", nil
+	}
+	var c xlsxC
+	if err := setCellValFunc(&c, value); err != nil && err != ErrNonFiniteNumber {
+		return "", err
+	}
+	return c.V, nil
+}
+
+// appendField writes the given field of delimited text, quoted if required.
+func (tw *TextWriter) appendField(field string) {
+	if !tw.opts.QuoteAll && !tw.fieldNeedsQuotes(field) {
+		_, _ = tw.w.WriteString(field)
+		return
+	}
+	_ = tw.w.WriteByte('"')
+	for {
+		i := strings.IndexByte(field, '"')
+		if i < 0 {
+			break
+		}
+		_, _ = tw.w.WriteString(field[:i+1])
+		_ = tw.w.WriteByte('"')
+		field = field[i+1:]
+	}
+	_, _ = tw.w.WriteString(field)
+	_ = tw.w.WriteByte('"')
+}
+
+// fieldNeedsQuotes reports whether the given field of delimited text must be
+// quoted.
+func (tw *TextWriter) fieldNeedsQuotes(field string) bool {
+	if field == "" {
+		return false
+	}
+	return field[0] == ' ' || strings.ContainsAny(field, "\"\r\n") || strings.ContainsRune(field, tw.opts.Comma)
+}
+
+// appendFixedWidth writes the given field padded with spaces or truncated to
+// the given width in characters, the line breaks of the field are replaced
+// with spaces to keep the record on a single line.
+func (tw *TextWriter) appendFixedWidth(field string, width int) {
+	var rb [utf8.UTFMax]byte
+	tw.field = tw.field[:0]
+	for _, r := range field {
+		if width == 0 {
+			break
+		}
+		if r == '\r' || r == '\n' {
+			r = ' '
+		}
+		tw.field = append(tw.field, rb[:utf8.EncodeRune(rb[:], r)]...)
+		width--
+	}
+	for ; width > 0; width-- {
+		tw.field = append(tw.field, ' ')
+	}
+	_, _ = tw.w.Write(tw.field)
+}
+
+// Flush writes the buffered records to the underlying writer.
+func (tw *TextWriter) Flush() error {
+	return tw.w.Flush()
+}
+
+// Close ends the writing process and flushes the buffered records to the
+// underlying writer. The underlying writer is not closed.
+func (tw *TextWriter) Close() error {
+	return tw.w.Flush()
+}
@@ -0,0 +1,103 @@
+package excelize
+
+import (
+	"bytes"
+	"encoding/csv"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTextWriter(t *testing.T) {
+	row := []Cell{
+		{Value: "plain"},
+		{Value: "a,b"},
+		{Value: "line\nbreak"},
+		{Value: `say "hi"`},
+		{Value: " leading"},
+		{Value: 1.5},
+		{Value: -42},
+		{Value: true},
+		{Value: time.Date(2021, 12, 31, 8, 30, 0, 0, time.UTC)},
+		{Value: math.NaN()},
+		{Formula: "SUM(A1:A2)"},
+		{Formula: "1+1", Value: 2},
+		{RawValue: []byte("3.25")},
+		{Value: []RichTextRun{{Text: "rich "}, {Text: "text"}}},
+		{Hyperlink: &CellHyperlink{Link: "https://github.com", Display: "link"}},
+		{},
+	}
+	var out bytes.Buffer
+	tw, err := NewTextWriter(&out, TextWriterOptions{})
+	require.NoError(t, err)
+	_, err = tw.AddRow(row, RowOpts{Height: 30})
+	require.NoError(t, err)
+	buffered, err := tw.AddRow([]Cell{{Value: "second"}})
+	require.NoError(t, err)
+	assert.Equal(t, 0, out.Len())
+	assert.NotZero(t, buffered)
+	require.NoError(t, tw.Close())
+	assert.Equal(t, "plain,\"a,b\",\"line\nbreak\",\"say \"\"hi\"\"\",\" leading\",1.5,-42,TRUE,2021-12-31 08:30:00,#NUM!,=SUM(A1:A2),2,3.25,rich text,link,\nsecond\n", out.String())
+
+	// the records are read back by a CSV reader
+	cr := csv.NewReader(strings.NewReader(out.String()))
+	cr.FieldsPerRecord = -1
+	records, err := cr.ReadAll()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"plain", "a,b", "line\nbreak", `say "hi"`, " leading"}, records[0][:5])
+	assert.Equal(t, []string{"second"}, records[1])
+
+	// tab-separated values with CRLF, quoting all fields and a date format
+	out.Reset()
+	tw, err = NewTextWriter(&out, TextWriterOptions{Comma: '\t', QuoteAll: true, UseCRLF: true, DateFormat: "02/01/2006"})
+	require.NoError(t, err)
+	_, err = tw.AddRow([]Cell{{Value: "a\tb"}, {Value: "c,d"}, {Value: time.Date(2021, 12, 31, 0, 0, 0, 0, time.UTC)}})
+	require.NoError(t, err)
+	require.NoError(t, tw.Flush())
+	assert.Equal(t, "\"a\tb\"\t\"c,d\"\t\"31/12/2021\"\r\n", out.String())
+
+	out.Reset()
+	tw, err = NewTextWriter(&out, TextWriterOptions{Comma: '\t'})
+	require.NoError(t, err)
+	_, err = tw.AddRow([]Cell{{Value: "a\tb"}, {Value: "c,d"}, {Value: ""}})
+	require.NoError(t, err)
+	require.NoError(t, tw.Close())
+	assert.Equal(t, "\"a\tb\"\tc,d\t\n", out.String())
+
+	// fixed-width text
+	out.Reset()
+	tw, err = NewTextWriter(&out, TextWriterOptions{Widths: []int{4, 6, 3}})
+	require.NoError(t, err)
+	_, err = tw.AddRow([]Cell{{Value: "ab"}, {Value: 12345678}, {Value: "é\nü"}})
+	require.NoError(t, err)
+	_, err = tw.AddRow([]Cell{{Value: "abcd"}})
+	require.NoError(t, err)
+	_, err = tw.AddRow(make([]Cell, 4))
+	assert.EqualError(t, err, ErrColumnNumber.Error())
+	require.NoError(t, tw.Close())
+	assert.Equal(t, "ab  123456é ü\nabcd\n", out.String())
+
+	for _, opts := range []TextWriterOptions{{Comma: '"'}, {Comma: '\n'}, {Comma: -1}, {Widths: []int{1, 0}}} {
+		_, err = NewTextWriter(&out, opts)
+		assert.EqualError(t, err, ErrParameterInvalid.Error())
+	}
+
+	tw, err = NewTextWriter(&out, TextWriterOptions{})
+	require.NoError(t, err)
+	out.Reset()
+	_, err = tw.AddRow([]Cell{{Value: "a"}, {Value: ErrorValue("#SPILL!")}})
+	assert.EqualError(t, err, newInvalidErrorValue("#SPILL!").Error())
+	require.NoError(t, tw.Close())
+	assert.Empty(t, out.String())
+
+	// the error of the underlying writer is returned
+	tw, err = NewTextWriter(&failingWriter{}, TextWriterOptions{})
+	require.NoError(t, err)
+	_, err = tw.AddRow([]Cell{{Value: strings.Repeat("a", 8192)}})
+	assert.EqualError(t, err, errFailingWriter.Error())
+	assert.EqualError(t, tw.Close(), errFailingWriter.Error())
+}
@@ -15,8 +15,40 @@ var (
 	escNL   = []byte("&#xA;")
 	escCR   = []byte("&#xD;")
 	escFFFD = []byte("\uFFFD") // Unicode replacement character
+	// the underscore of a literal _xHHHH_ escape sequence, see bstrMarshal
+	escUnderscore = []byte("_x005F_")
 )
 
+// hexDigits are the digits of the _xHHHH_ escapes of the control characters.
+const hexDigits = "0123456789ABCDEF"
+
+// xmlNeedsEscape reports whether a byte may need to be escaped, that is all
+// bytes except the printable ASCII characters other than the special XML
+// characters.
+var xmlNeedsEscape = func() (table [256]bool) {
+	for i := range table {
+		table[i] = i < 0x20 || i >= 0x7F
+	}
+	for _, c := range `"'&<>` {
+		table[c] = true
+	}
+	return
+}()
+
+// isBstrLiteral reports whether the given string starts with a sequence in
+// the _xHHHH_ escape format of the characters, with 4 hexadecimal digits.
+func isBstrLiteral(s string) bool {
+	if len(s) < 7 || s[0] != '_' || s[1] != 'x' || s[6] != '_' {
+		return false
+	}
+	for _, c := range []byte(s[2:6]) {
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F') {
+			return false
+		}
+	}
+	return true
+}
+
 // copied from stdlib xml pkg
 func isInCharacterRange(r rune) (inrange bool) {
 	return r == 0x09 ||
@@ -29,9 +61,30 @@ func isInCharacterRange(r rune) (inrange bool) {
 
 // copied and modified from stdlib xml.EscapeText()
 func appendEscapedString(dst []byte, s string, escapeNewline bool) []byte {
+	return appendEscapedText(dst, s, escapeNewline, ControlCharReplace)
+}
+
+// appendEscapedText appends the given string escaped like appendEscapedString,
+// with the characters which are not allowed in XML handled by the given mode.
+// In the ControlCharEscape mode the underscore of a literal _xHHHH_ sequence
+// is escaped as _x005F_ like bstrMarshal does, so that the literal isn't
+// decoded as an escaped character.
+func appendEscapedText(dst []byte, s string, escapeNewline bool, mode ControlCharMode) []byte {
+	// the leading printable ASCII characters which need no escaping are
+	// appended as is, without decoding them, that is the whole string in the
+	// common case, in the escape mode up to the underscore of a literal _xHHHH_
+	// escape sequence
+	escapeLiterals := mode == ControlCharEscape
+	i := 0
+	for i < len(s) && !xmlNeedsEscape[s[i]] && !(escapeLiterals && isBstrLiteral(s[i:])) {
+		i++
+	}
+	if i == len(s) {
+		return append(dst, s...)
+	}
 	var esc []byte
 	last := 0
-	for i := 0; i < len(s); {
+	for i < len(s) {
 		r, width := utf8.DecodeRuneInString(s[i:])
 		i += width
 		switch r {
@@ -54,9 +107,22 @@ func appendEscapedString(dst []byte, s string, escapeNewline bool) []byte {
 			esc = escNL
 		case '\r':
 			esc = escCR
+		case '_':
+			if !escapeLiterals || !isBstrLiteral(s[i-width:]) {
+				continue
+			}
+			esc = escUnderscore
 		default:
 			if !isInCharacterRange(r) || (r == 0xFFFD && width == 1) {
 				esc = escFFFD
+				if mode == ControlCharStrip {
+					esc = nil
+				} else if mode == ControlCharEscape && r < 0x20 {
+					dst = append(dst, s[last:i-width]...)
+					dst = append(dst, '_', 'x', '0', '0', hexDigits[r>>4], hexDigits[r&0xF], '_')
+					last = i
+					continue
+				}
 				break
 			}
 			continue
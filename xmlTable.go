@@ -206,6 +206,13 @@ type formatTable struct {
 	ShowColumnStripes bool   `json:"show_column_stripes"`
 }
 
+// AutoFilterOptions directly maps the filter criteria of a column of an
+// autofilter, see File.AutoFilter for the filter expressions.
+type AutoFilterOptions struct {
+	Column     string
+	Expression string
+}
+
 // formatAutoFilter directly maps the auto filter settings.
 type formatAutoFilter struct {
 	Column     string `json:"column"`
@@ -0,0 +1,30 @@
+// Copyright 2016 - 2021 The excelize Authors. All rights reserved. Use of
+// this source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+//
+// Package excelize providing a set of functions that allow you to write to
+// and read from XLSX / XLSM / XLTM files. Supports reading and writing
+// spreadsheet documents generated by Microsoft Excel™ 2007 and later. Supports
+// complex components by high compatibility, and provided streaming API for
+// generating or reading data from a worksheet with huge amounts of data. This
+// library needs Go version 1.15 or later.
+
+//go:build go1.17
+// +build go1.17
+
+package excelize
+
+import (
+	"archive/zip"
+	"io"
+)
+
+// zipCreateRawSupported defined whether pre-compressed entries can be written
+// to the zip archive.
+const zipCreateRawSupported = true
+
+// zipCreateRaw adds a pre-compressed entry to the zip archive by given file
+// header.
+func zipCreateRaw(zw *zip.Writer, fh *zip.FileHeader) (io.Writer, error) {
+	return zw.CreateRaw(fh)
+}